@@ -0,0 +1,191 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/u-root/u-root/pkg/efivarfs"
+	"system-transparency.org/stboot/host"
+)
+
+var errDiffers = errors.New("host configurations differ")
+
+// openEFIVars opens the efivarfs mounted at path. Tests replace it.
+var openEFIVars = func(path string) (efivarfs.EFIVar, error) {
+	return efivarfs.NewPath(path)
+}
+
+// efivar manages the host configuration EFI variable.
+func efivar(args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: efivar write|read|diff|delete [flags]", errUsage)
+	}
+
+	flags := flag.NewFlagSet("efivar "+args[0], flag.ContinueOnError)
+	path := flags.String("efivarfs", efivarfs.DefaultVarFS, "mount point of efivarfs")
+	in := flags.String("in", "", "host configuration or signed envelope, for write and diff")
+
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	vars, err := openEFIVars(*path)
+	if err != nil {
+		return err
+	}
+
+	readIn := func() ([]byte, error) {
+		if *in == "" {
+			return nil, fmt.Errorf("%w: -in is required", errMissingFlag)
+		}
+
+		return os.ReadFile(*in)
+	}
+
+	switch args[0] {
+	case "write":
+		data, err := readIn()
+		if err != nil {
+			return err
+		}
+
+		if err := host.WriteConfigEFIVar(vars, data); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(stdout, "wrote %s to %s\n", *in, host.HostConfigEFIVarName)
+
+		return nil
+	case "read":
+		data, attrs, err := host.ReadConfigEFIVar(vars)
+		if err != nil {
+			return err
+		}
+
+		if attrs != host.HostConfigEFIVarAttrs {
+			fmt.Fprintf(os.Stderr, "hostcfg: unexpected attributes %#x, want %#x\n", attrs, host.HostConfigEFIVarAttrs)
+		}
+
+		_, err = stdout.Write(data)
+
+		return err
+	case "diff":
+		data, err := readIn()
+		if err != nil {
+			return err
+		}
+
+		current, _, err := host.ReadConfigEFIVar(vars)
+		if err != nil {
+			return err
+		}
+
+		return diffConfigs(current, data, stdout)
+	case "delete":
+		if err := host.RemoveConfigEFIVar(vars); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(stdout, "deleted %s\n", host.HostConfigEFIVarName)
+
+		return nil
+	default:
+		return fmt.Errorf("%w: unknown efivar command %q", errUsage, args[0])
+	}
+}
+
+// diffConfigs compares two host configurations field by field. Signed
+// envelopes are compared by their host configuration and signature.
+func diffConfigs(a, b []byte, stdout io.Writer) error {
+	var fields [2]map[string]string
+
+	for i, data := range [][]byte{a, b} {
+		var err error
+
+		if fields[i], err = configFields(data); err != nil {
+			return err
+		}
+	}
+
+	union := make(map[string]bool, len(fields[0]))
+	for _, f := range fields {
+		for k := range f {
+			union[k] = true
+		}
+	}
+
+	keys := make([]string, 0, len(union))
+	for k := range union {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	var differs bool
+
+	for _, key := range keys {
+		a, inA := fields[0][key]
+		b, inB := fields[1][key]
+
+		if inA && inB && a == b {
+			continue
+		}
+
+		differs = true
+
+		if inA {
+			fmt.Fprintf(stdout, "- %s: %s\n", key, a)
+		}
+
+		if inB {
+			fmt.Fprintf(stdout, "+ %s: %s\n", key, b)
+		}
+	}
+
+	if differs {
+		return errDiffers
+	}
+
+	return nil
+}
+
+func configFields(data []byte) (map[string]string, error) {
+	out := make(map[string]string)
+
+	if host.IsConfigEnvelope(data) {
+		env, err := host.ConfigEnvelopeFromBytes(data)
+		if err != nil {
+			return nil, err
+		}
+
+		out["signature"] = base64.StdEncoding.EncodeToString(env.Signature)
+		data = env.Config
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	for k, v := range raw {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, v); err != nil {
+			return nil, err
+		}
+
+		out[k] = buf.String()
+	}
+
+	return out, nil
+}
@@ -0,0 +1,67 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Command hostcfg prepares host configurations for stboot.
+//
+// Usage:
+//
+//	hostcfg sign   -in FILE -out FILE (-key FILE | -command CMD)
+//	hostcfg verify -in FILE -pubkey FILE
+//	hostcfg efivar write|diff -in FILE [-efivarfs DIR]
+//	hostcfg efivar read|delete [-efivarfs DIR]
+//
+// The efivar commands manage the host configuration EFI variable read by
+// stboot. Configurations are validated before writing. The key is a PEM
+// encoded PKCS#8 Ed25519 private key, the public key a PEM encoded PKIX
+// Ed25519 public key as named in the trust policy.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"system-transparency.org/stboot/stlog"
+)
+
+var (
+	errUsage       = errors.New("usage: hostcfg sign|verify|efivar [flags]")
+	errMissingFlag = errors.New("missing flag")
+)
+
+type command func(args []string, stdout io.Writer) error
+
+func main() {
+	stlog.SetLevel(stlog.ErrorLevel)
+
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "hostcfg: %v\n", err)
+
+		if errors.Is(err, errDiffers) {
+			os.Exit(1)
+		}
+
+		os.Exit(2) //nolint:gomnd
+	}
+}
+
+func run(args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	commands := map[string]command{
+		"sign":   sign,
+		"verify": verify,
+		"efivar": efivar,
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return fmt.Errorf("%w: unknown command %q", errUsage, args[0])
+	}
+
+	return cmd(args[1:], stdout)
+}
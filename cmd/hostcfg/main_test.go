@@ -0,0 +1,162 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/efivarfs"
+	"system-transparency.org/stboot/host"
+	"system-transparency.org/stboot/internal/efivartest"
+	"system-transparency.org/stboot/ospkg/signing"
+)
+
+const testConfig = `{
+	"network_mode": "dhcp",
+	"host_ip": null,
+	"gateway": null,
+	"dns": null,
+	"network_interfaces": null,
+	"ospkg_pointer": "https://example.org/ospkg.json",
+	"identity": null,
+	"authentication": null,
+	"bonding_mode": null,
+	"bond_name": null
+}`
+
+func writeFile(t *testing.T, path string, data []byte) string {
+	t.Helper()
+
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestSignVerify(t *testing.T) {
+	dir := t.TempDir()
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pubDER, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := writeFile(t, filepath.Join(dir, "key.pem"), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER}))
+	pubKey := writeFile(t, filepath.Join(dir, "pub.pem"), pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}))
+	in := writeFile(t, filepath.Join(dir, "host_configuration.json"), []byte(testConfig))
+	out := filepath.Join(dir, "host_configuration.signed.json")
+
+	var buf bytes.Buffer
+
+	if err := run([]string{"sign", "-in", in, "-out", out, "-pubkey", pubKey, "-command", " "}, &buf); !errors.Is(err, signing.ErrEmptyCommand) {
+		t.Fatalf("sign with blank command: got %v, want %v", err, signing.ErrEmptyCommand)
+	}
+
+	if err := run([]string{"sign", "-in", in, "-out", out, "-key", key}, &buf); err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+
+	if err := run([]string{"verify", "-in", out, "-pubkey", pubKey}, &buf); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+
+	otherPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherDER, err := x509.MarshalPKIXPublicKey(otherPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := writeFile(t, filepath.Join(dir, "other.pem"), pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: otherDER}))
+
+	if err := run([]string{"verify", "-in", out, "-pubkey", other}, &buf); !errors.Is(err, host.ErrInvalidSignature) {
+		t.Errorf("verify with other key: got %v, want %v", err, host.ErrInvalidSignature)
+	}
+}
+
+func TestEFIVar(t *testing.T) {
+	dir := t.TempDir()
+	vars := efivartest.Dir(t.TempDir())
+
+	openEFIVars = func(string) (efivarfs.EFIVar, error) { return vars, nil }
+
+	in := writeFile(t, filepath.Join(dir, "a.json"), []byte(testConfig))
+	changed := writeFile(t, filepath.Join(dir, "b.json"),
+		[]byte(strings.Replace(testConfig, `"identity": null`, `"identity": "box"`, 1)))
+	invalid := writeFile(t, filepath.Join(dir, "invalid.json"), []byte(`{"network_mode": "dhcp"}`))
+
+	var out bytes.Buffer
+
+	if err := run([]string{"efivar", "write", "-in", invalid}, &out); !errors.Is(err, host.ErrInvalidConfig) {
+		t.Errorf("write invalid: got %v, want %v", err, host.ErrInvalidConfig)
+	}
+
+	if err := run([]string{"efivar", "write", "-in", in}, &out); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	out.Reset()
+
+	if err := run([]string{"efivar", "read"}, &out); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	if out.String() != testConfig {
+		t.Errorf("read: got %s", out.String())
+	}
+
+	if err := run([]string{"efivar", "diff", "-in", in}, &out); err != nil {
+		t.Errorf("diff identical: %v", err)
+	}
+
+	out.Reset()
+
+	if err := run([]string{"efivar", "diff", "-in", changed}, &out); !errors.Is(err, errDiffers) {
+		t.Fatalf("diff: got %v, want %v", err, errDiffers)
+	}
+
+	if want := "- identity: null\n+ identity: \"box\"\n"; out.String() != want {
+		t.Errorf("diff: got %q, want %q", out.String(), want)
+	}
+
+	if err := run([]string{"efivar", "delete"}, &out); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	if err := run([]string{"efivar", "read"}, &out); !errors.Is(err, host.ErrEFIVarNotExist) {
+		t.Errorf("read deleted: got %v, want %v", err, host.ErrEFIVarNotExist)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	for _, args := range [][]string{{}, {"unknown"}} {
+		if err := run(args, &bytes.Buffer{}); !errors.Is(err, errUsage) {
+			t.Errorf("%v: got %v, want %v", args, err, errUsage)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"system-transparency.org/stboot/host"
+	"system-transparency.org/stboot/ospkg/signing"
+)
+
+var (
+	errSigningBackend = errors.New("exactly one of -key or -command must be set")
+	errKeyType        = errors.New("want an Ed25519 key")
+)
+
+func sign(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("sign", flag.ContinueOnError)
+	in := flags.String("in", "", "host configuration JSON")
+	out := flags.String("out", "", "signed host configuration envelope")
+	keyFile := flags.String("key", "", "PEM encoded PKCS#8 Ed25519 private key")
+	pubKeyFile := flags.String("pubkey", "", "PEM encoded Ed25519 public key, required with -command")
+	command := flags.String("command", "", "external signing program and its arguments, see package signing")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *in == "" || *out == "" {
+		return fmt.Errorf("%w: -in and -out are required", errMissingFlag)
+	}
+
+	var signer crypto.Signer
+
+	switch {
+	case *keyFile != "" && *command == "":
+		key, err := readPEM(*keyFile)
+		if err != nil {
+			return err
+		}
+
+		priv, err := x509.ParsePKCS8PrivateKey(key)
+		if err != nil {
+			return err
+		}
+
+		edKey, ok := priv.(ed25519.PrivateKey)
+		if !ok {
+			return fmt.Errorf("%s: %w", *keyFile, errKeyType)
+		}
+
+		signer = edKey
+	case *command != "" && *keyFile == "":
+		if *pubKeyFile == "" {
+			return fmt.Errorf("%w: -pubkey is required with -command", errMissingFlag)
+		}
+
+		pub, err := readPublicKey(*pubKeyFile)
+		if err != nil {
+			return err
+		}
+
+		if signer, err = signing.ParseCommand(pub, *command); err != nil {
+			return err
+		}
+	default:
+		return errSigningBackend
+	}
+
+	config, err := os.ReadFile(*in)
+	if err != nil {
+		return err
+	}
+
+	env, err := host.SignConfig(config, signer)
+	if err != nil {
+		return err
+	}
+
+	envBytes, err := json.MarshalIndent(env, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(*out, envBytes, 0o600); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "signed %s to %s\n", *in, *out)
+
+	return nil
+}
+
+func verify(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("verify", flag.ContinueOnError)
+	in := flags.String("in", "", "signed host configuration envelope")
+	pubKeyFile := flags.String("pubkey", "", "PEM encoded Ed25519 public key")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *in == "" || *pubKeyFile == "" {
+		return fmt.Errorf("%w: -in and -pubkey are required", errMissingFlag)
+	}
+
+	pub, err := readPublicKey(*pubKeyFile)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(*in)
+	if err != nil {
+		return err
+	}
+
+	env, err := host.ConfigEnvelopeFromBytes(data)
+	if err != nil {
+		return err
+	}
+
+	if err := env.Verify(pub); err != nil {
+		return err
+	}
+
+	var cfg host.Config
+	if err := json.Unmarshal(env.Config, &cfg); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "%s: signature valid\n", *in)
+
+	return nil
+}
+
+func readPEM(name string) ([]byte, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data found", name)
+	}
+
+	return block.Bytes, nil
+}
+
+func readPublicKey(name string) (ed25519.PublicKey, error) {
+	der, err := readPEM(name)
+	if err != nil {
+		return nil, err
+	}
+
+	pub, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, err
+	}
+
+	edPub, ok := pub.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", name, errKeyType)
+	}
+
+	return edPub, nil
+}
@@ -0,0 +1,55 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"system-transparency.org/stboot/ospkg"
+)
+
+var errMissingFlag = errors.New("missing flag")
+
+func create(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("create", flag.ContinueOnError)
+	out := flags.String("out", "", "path of the OS package to create, .zip and .json are written")
+	label := flags.String("label", "", "short description of the OS")
+	pkgURL := flags.String("url", "", "URL of the archive, recorded in the descriptor")
+	kernel := flags.String("kernel", "", "path to the Linux kernel")
+	initramfs := flags.String("initramfs", "", "path to the initramfs")
+	cmdline := flags.String("cmdline", "", "kernel command line")
+	addressed := flags.Bool("content-addressed", false, "record the archive's SHA-256 in the descriptor")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *out == "" {
+		return fmt.Errorf("%w: -out", errMissingFlag)
+	}
+
+	osp, err := ospkg.CreateOSPackage(*label, *pkgURL, *kernel, *initramfs, *cmdline)
+	if err != nil {
+		return err
+	}
+
+	if *addressed {
+		if err := osp.AddArchiveHash(); err != nil {
+			return err
+		}
+	}
+
+	if err := store(osp, *out); err != nil {
+		return err
+	}
+
+	descriptor, archive := files(*out)
+	fmt.Fprintf(stdout, "created %s and %s\n", archive, descriptor)
+
+	return nil
+}
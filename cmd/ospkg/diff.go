@@ -0,0 +1,144 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"system-transparency.org/stboot/ospkg"
+)
+
+// diff compares the reports of two OS packages field by field. Archive entries
+// are matched by name, signatures by position.
+func diff(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("diff", flag.ContinueOnError)
+	rootFile := flags.String("root", "", "optional signing root certificate to verify signatures against")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 2 { //nolint:gomnd
+		return fmt.Errorf("%w: diff expects two OS packages", errUsage)
+	}
+
+	var fields [2]map[string]string
+
+	for i, name := range flags.Args() {
+		info, err := loadInfo(name, *rootFile)
+		if err != nil {
+			return err
+		}
+
+		fields[i], err = infoFields(info)
+		if err != nil {
+			return err
+		}
+	}
+
+	union := make(map[string]bool, len(fields[0]))
+	for _, f := range fields {
+		for k := range f {
+			union[k] = true
+		}
+	}
+
+	keys := make([]string, 0, len(union))
+	for k := range union {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	var differs bool
+
+	for _, key := range keys {
+		a, inA := fields[0][key]
+		b, inB := fields[1][key]
+
+		if inA && inB && a == b {
+			continue
+		}
+
+		differs = true
+
+		if inA {
+			fmt.Fprintf(stdout, "- %s: %s\n", key, a)
+		}
+
+		if inB {
+			fmt.Fprintf(stdout, "+ %s: %s\n", key, b)
+		}
+	}
+
+	if differs {
+		return errDiffers
+	}
+
+	return nil
+}
+
+// infoFields flattens info into a map of dotted JSON paths to values.
+func infoFields(info *ospkg.Info) (map[string]string, error) {
+	files := make(map[string]ospkg.FileInfo, len(info.Archive.Files))
+	for _, f := range info.Archive.Files {
+		files[f.Name] = f
+	}
+
+	withoutFiles := *info
+	withoutFiles.Archive.Files = nil
+
+	report := struct {
+		ospkg.Info
+		Files map[string]ospkg.FileInfo `json:"files"`
+	}{
+		Info:  withoutFiles,
+		Files: files,
+	}
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		return nil, err
+	}
+
+	var tree interface{}
+	if err := json.Unmarshal(data, &tree); err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]string)
+	flatten("", tree, out)
+
+	return out, nil
+}
+
+func flatten(prefix string, node interface{}, out map[string]string) {
+	join := func(key string) string {
+		if prefix == "" {
+			return key
+		}
+
+		return prefix + "." + key
+	}
+
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for k, v := range n {
+			flatten(join(k), v, out)
+		}
+	case []interface{}:
+		for i, v := range n {
+			flatten(join(strconv.Itoa(i)), v, out)
+		}
+	default:
+		b, _ := json.Marshal(n)
+		out[prefix] = string(b)
+	}
+}
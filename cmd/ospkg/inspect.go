@@ -0,0 +1,71 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"system-transparency.org/stboot/opts"
+	"system-transparency.org/stboot/ospkg"
+)
+
+func inspect(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("inspect", flag.ContinueOnError)
+	name := flags.String("ospkg", "", "path of the OS package (.zip or .json)")
+	rootFile := flags.String("root", "", "optional signing root certificate to verify signatures against")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *name == "" {
+		return fmt.Errorf("%w: -ospkg", errMissingFlag)
+	}
+
+	info, err := loadInfo(*name, *rootFile)
+	if err != nil {
+		return err
+	}
+
+	out, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "%s\n", out)
+
+	return nil
+}
+
+func loadInfo(name, rootFile string) (*ospkg.Info, error) {
+	var root *x509.Certificate
+
+	if rootFile != "" {
+		rootSrc, err := os.Open(rootFile)
+		if err != nil {
+			return nil, err
+		}
+		defer rootSrc.Close()
+
+		stOptions, err := opts.NewOpts(opts.WithSigningRootCert(rootSrc))
+		if err != nil {
+			return nil, err
+		}
+
+		root = stOptions.SigningRoot
+	}
+
+	osp, err := load(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return osp.Info(root)
+}
@@ -0,0 +1,125 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Command ospkg creates, signs and checks OS packages. It uses the same code
+// as stboot, so a package passing "ospkg verify" passes stboot's verification.
+//
+// Usage:
+//
+//	ospkg create  -out NAME -kernel FILE -initramfs FILE [-label L] [-cmdline C] [-url URL] [-content-addressed]
+//	ospkg sign    -ospkg NAME -cert FILE (-key FILE | -pkcs11-module LIB -pkcs11-id ID | -command CMD)
+//	ospkg verify  -ospkg NAME -root FILE [-threshold N | -trust-policy FILE]
+//	ospkg inspect -ospkg NAME [-root FILE]
+//	ospkg diff    [-root FILE] NAME NAME
+//	ospkg schema  [-version N] FORMAT | -list | -out DIR
+//
+// NAME is the path of either the archive (.zip) or the descriptor (.json).
+// Both files are expected next to each other. FORMAT is one of descriptor,
+// manifest, host_config or trust_policy.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"system-transparency.org/stboot/ospkg"
+	"system-transparency.org/stboot/stlog"
+)
+
+var (
+	errUsage    = errors.New("usage: ospkg create|sign|verify|inspect|diff|schema [flags]")
+	errDiffers  = errors.New("OS packages differ")
+	errRejected = errors.New("OS package rejected")
+)
+
+type command func(args []string, stdout io.Writer) error
+
+func main() {
+	stlog.SetLevel(stlog.ErrorLevel)
+
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "ospkg: %v\n", err)
+
+		if errors.Is(err, errDiffers) {
+			os.Exit(1)
+		}
+
+		os.Exit(2) //nolint:gomnd
+	}
+}
+
+func run(args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	commands := map[string]command{
+		"create":  create,
+		"sign":    sign,
+		"verify":  verify,
+		"inspect": inspect,
+		"diff":    diff,
+		"schema":  printSchema,
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return fmt.Errorf("%w: unknown command %q", errUsage, args[0])
+	}
+
+	return cmd(args[1:], stdout)
+}
+
+// files returns the descriptor and archive path of the OS package named by name.
+//
+//nolint:nonamedreturns
+func files(name string) (descriptor, archive string) {
+	base := strings.TrimSuffix(name, filepath.Ext(name))
+
+	return base + ospkg.DescriptorExt, base + ospkg.OSPackageExt
+}
+
+// load reads the OS package named by name the same way stboot does.
+func load(name string) (*ospkg.OSPackage, error) {
+	descriptorFile, archiveFile := files(name)
+
+	archive, err := os.ReadFile(archiveFile)
+	if err != nil {
+		return nil, err
+	}
+
+	descriptor, err := os.ReadFile(descriptorFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return ospkg.NewOSPackage(archive, descriptor)
+}
+
+// store writes the OS package to the files named by name.
+func store(osp *ospkg.OSPackage, name string) error {
+	descriptorFile, archiveFile := files(name)
+
+	archive, err := osp.ArchiveBytes()
+	if err != nil {
+		return err
+	}
+
+	descriptor, err := osp.DescriptorBytes()
+	if err != nil {
+		return err
+	}
+
+	const perm = 0o644
+
+	if err := os.WriteFile(archiveFile, archive, perm); err != nil {
+		return err
+	}
+
+	return os.WriteFile(descriptorFile, descriptor, perm)
+}
@@ -0,0 +1,253 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"system-transparency.org/stboot/ospkg"
+	"system-transparency.org/stboot/ospkg/signing"
+)
+
+func writePEM(t *testing.T, path, typ string, der []byte) string {
+	t.Helper()
+
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// keys writes a root certificate and a signing key and certificate issued
+// by it to dir.
+//
+//nolint:nonamedreturns
+func keys(t *testing.T, dir string) (root, cert, key string) {
+	t.Helper()
+
+	rootPub, rootPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rootTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "root"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+
+	rootDER, err := x509.CreateCertificate(rand.Reader, rootTmpl, rootTmpl, rootPub, rootPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certTmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "signer"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	certDER, err := x509.CreateCertificate(rand.Reader, certTmpl, rootTmpl, pub, rootPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root = writePEM(t, filepath.Join(dir, "root.pem"), "CERTIFICATE", rootDER)
+	cert = writePEM(t, filepath.Join(dir, "cert.pem"), "CERTIFICATE", certDER)
+	key = writePEM(t, filepath.Join(dir, "key.pem"), "PRIVATE KEY", keyDER)
+
+	return root, cert, key
+}
+
+func mkPackage(t *testing.T, dir, name, cmdline string) string {
+	t.Helper()
+
+	kernel := filepath.Join(dir, "vmlinuz")
+	initramfs := filepath.Join(dir, "initramfs.cpio")
+
+	for _, f := range []string{kernel, initramfs} {
+		if err := os.WriteFile(f, []byte(filepath.Base(f)), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out := filepath.Join(dir, name+".zip")
+
+	err := run([]string{"create", "-out", out, "-label", "test", "-kernel", kernel,
+		"-initramfs", initramfs, "-cmdline", cmdline, "-url", "https://example.org/" + name + ".zip"}, &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	return out
+}
+
+func TestWorkflow(t *testing.T) {
+	dir := t.TempDir()
+	root, cert, key := keys(t, dir)
+	pkg := mkPackage(t, dir, "ospkg", "console=ttyS0")
+
+	var out bytes.Buffer
+
+	// not signed yet
+	if err := run([]string{"verify", "-ospkg", pkg, "-root", root}, &out); !errors.Is(err, errRejected) {
+		t.Fatalf("verify unsigned: got %v, want %v", err, errRejected)
+	}
+
+	if err := run([]string{"sign", "-ospkg", pkg, "-cert", cert, "-command", " "}, &out); !errors.Is(err, signing.ErrEmptyCommand) {
+		t.Fatalf("sign with blank command: got %v, want %v", err, signing.ErrEmptyCommand)
+	}
+
+	if err := run([]string{"sign", "-ospkg", pkg, "-cert", cert, "-key", key}, &out); err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+
+	if err := run([]string{"verify", "-ospkg", pkg, "-root", root}, &out); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+
+	policy := filepath.Join(dir, "trust_policy.json")
+	if err := os.WriteFile(policy, []byte(`{"ospkg_signature_threshold": 2, "ospkg_fetch_method": "network"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := run([]string{"verify", "-ospkg", pkg, "-root", root, "-trust-policy", policy}, &out); !errors.Is(err, errRejected) {
+		t.Fatalf("verify with threshold 2: got %v, want %v", err, errRejected)
+	}
+
+	out.Reset()
+
+	if err := run([]string{"inspect", "-ospkg", pkg, "-root", root}, &out); err != nil {
+		t.Fatalf("inspect: %v", err)
+	}
+
+	var info ospkg.Info
+	if err := json.Unmarshal(out.Bytes(), &info); err != nil {
+		t.Fatalf("inspect output: %v", err)
+	}
+
+	if info.Descriptor.SignaturesValid != 1 {
+		t.Errorf("got %d valid signatures, want 1", info.Descriptor.SignaturesValid)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	dir := t.TempDir()
+	a := mkPackage(t, dir, "a", "console=ttyS0")
+	b := mkPackage(t, dir, "b", "console=ttyS1")
+	c := mkPackage(t, dir, "c", "console=ttyS0")
+
+	var out bytes.Buffer
+
+	if err := run([]string{"diff", a, b}, &out); !errors.Is(err, errDiffers) {
+		t.Fatalf("got %v, want %v", err, errDiffers)
+	}
+
+	for _, want := range []string{"- manifest.cmdline: \"console=ttyS0\"", "+ manifest.cmdline: \"console=ttyS1\""} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output misses %q:\n%s", want, out.String())
+		}
+	}
+
+	out.Reset()
+
+	// a and c only differ in the package URL.
+	if err := run([]string{"diff", a, c}, &out); !errors.Is(err, errDiffers) {
+		t.Fatalf("got %v, want %v", err, errDiffers)
+	}
+
+	if strings.Contains(out.String(), "archive.sha256") {
+		t.Errorf("archives expected to be identical:\n%s", out.String())
+	}
+
+	if err := run([]string{"diff", a, a}, &out); err != nil {
+		t.Errorf("diff of identical packages: %v", err)
+	}
+}
+
+func TestContentAddressed(t *testing.T) {
+	dir := t.TempDir()
+	kernel := filepath.Join(dir, "vmlinuz")
+
+	if err := os.WriteFile(kernel, []byte("kernel"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(dir, "ospkg.zip")
+	if err := run([]string{"create", "-out", out, "-kernel", kernel, "-initramfs", kernel, "-content-addressed"}, &bytes.Buffer{}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := run([]string{"inspect", "-ospkg", out}, &buf); err != nil {
+		t.Fatalf("inspect: %v", err)
+	}
+
+	var info ospkg.Info
+	if err := json.Unmarshal(buf.Bytes(), &info); err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Descriptor.PkgSHA256 == "" || info.Descriptor.PkgSHA256 != info.Archive.SHA256 {
+		t.Errorf("got descriptor hash %q, want %q", info.Descriptor.PkgSHA256, info.Archive.SHA256)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	for _, args := range [][]string{{}, {"unknown"}} {
+		if err := run(args, &bytes.Buffer{}); !errors.Is(err, errUsage) {
+			t.Errorf("%v: got %v, want %v", args, err, errUsage)
+		}
+	}
+}
+
+func TestSchema(t *testing.T) {
+	var out bytes.Buffer
+
+	if err := run([]string{"schema", "descriptor"}, &out); err != nil {
+		t.Fatalf("schema: %v", err)
+	}
+
+	if !json.Valid(out.Bytes()) {
+		t.Errorf("invalid schema:\n%s", out.String())
+	}
+
+	dir := t.TempDir()
+	if err := run([]string{"schema", "-out", dir}, &out); err != nil {
+		t.Fatalf("schema -out: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "trust_policy.v1.json")); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"system-transparency.org/stboot/schema"
+)
+
+// printSchema prints the JSON Schema of a format, or writes the schemas of
+// all formats and versions to a directory.
+func printSchema(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("schema", flag.ContinueOnError)
+	version := flags.Int("version", -1, "format version, defaults to the latest")
+	list := flags.Bool("list", false, "list formats and versions")
+	outDir := flags.String("out", "", "write all schemas to this directory")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	switch {
+	case *list:
+		for _, f := range schema.Formats() {
+			v, _ := schema.Versions(f)
+			fmt.Fprintf(stdout, "%s %v\n", f, v)
+		}
+
+		return nil
+	case *outDir != "":
+		return writeSchemas(*outDir)
+	case flags.NArg() != 1:
+		return fmt.Errorf("%w: schema [-version N] FORMAT", errUsage)
+	}
+
+	format := schema.Format(flags.Arg(0))
+
+	var (
+		data []byte
+		err  error
+	)
+
+	if *version < 0 {
+		data, err = schema.Latest(format)
+	} else {
+		data, err = schema.Get(format, *version)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	_, err = stdout.Write(data)
+
+	return err
+}
+
+func writeSchemas(dir string) error {
+	for _, f := range schema.Formats() {
+		versions, _ := schema.Versions(f)
+
+		for _, v := range versions {
+			data, err := schema.Get(f, v)
+			if err != nil {
+				return err
+			}
+
+			const perm = 0o644
+
+			dst := filepath.Join(dir, fmt.Sprintf("%s.v%d.json", f, v))
+			if err := os.WriteFile(dst, data, perm); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
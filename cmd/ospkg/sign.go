@@ -0,0 +1,102 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"system-transparency.org/stboot/ospkg/signing"
+)
+
+var errSigningBackend = errors.New("exactly one of -key, -pkcs11-module or -command must be set")
+
+func sign(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("sign", flag.ContinueOnError)
+	name := flags.String("ospkg", "", "path of the OS package (.zip or .json)")
+	certFile := flags.String("cert", "", "PEM encoded signing certificate")
+	keyFile := flags.String("key", "", "PEM encoded PKCS#8 private key")
+	module := flags.String("pkcs11-module", "", "PKCS#11 module holding the private key")
+	token := flags.String("pkcs11-token", "", "label of the PKCS#11 token")
+	keyID := flags.String("pkcs11-id", "", "hex encoded id of the private key in the PKCS#11 token")
+	pinEnv := flags.String("pkcs11-pin-env", "PKCS11_PIN", "environment variable holding the PKCS#11 user PIN")
+	command := flags.String("command", "", "external signing program and its arguments, see package signing")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *name == "" || *certFile == "" {
+		return fmt.Errorf("%w: -ospkg and -cert are required", errMissingFlag)
+	}
+
+	osp, err := load(*name)
+	if err != nil {
+		return err
+	}
+
+	certPEM, err := os.ReadFile(*certFile)
+	if err != nil {
+		return err
+	}
+
+	certBlock, _ := pem.Decode(certPEM)
+	if certBlock == nil {
+		return fmt.Errorf("%s: no PEM data found", *certFile)
+	}
+
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return err
+	}
+
+	var signer crypto.Signer
+
+	switch {
+	case *keyFile != "" && *module == "" && *command == "":
+		keyPEM, err := os.ReadFile(*keyFile)
+		if err != nil {
+			return err
+		}
+
+		keyBlock, _ := pem.Decode(keyPEM)
+		if keyBlock == nil {
+			return fmt.Errorf("%s: no PEM data found", *keyFile)
+		}
+
+		if err := osp.Sign(keyBlock, certBlock); err != nil {
+			return err
+		}
+	case *module != "" && *keyFile == "" && *command == "":
+		signer = signing.NewPKCS11(cert.PublicKey, *module, *token, *keyID, os.Getenv(*pinEnv))
+	case *command != "" && *keyFile == "" && *module == "":
+		if signer, err = signing.ParseCommand(cert.PublicKey, *command); err != nil {
+			return err
+		}
+	default:
+		return errSigningBackend
+	}
+
+	if signer != nil {
+		if err := osp.SignWith(signer, certBlock); err != nil {
+			return err
+		}
+	}
+
+	if err := store(osp, *name); err != nil {
+		return err
+	}
+
+	descriptor, _ := files(*name)
+	fmt.Fprintf(stdout, "signed %s with %s\n", descriptor, cert.Subject)
+
+	return nil
+}
@@ -0,0 +1,85 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"system-transparency.org/stboot/opts"
+)
+
+// verify checks an OS package exactly like stboot does before booting it.
+func verify(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("verify", flag.ContinueOnError)
+	name := flags.String("ospkg", "", "path of the OS package (.zip or .json)")
+	rootFile := flags.String("root", "", "PEM encoded signing root certificate, as ospkg_signing_root.pem")
+	policyFile := flags.String("trust-policy", "", "trust policy to take the signature threshold from")
+	threshold := flags.Int("threshold", 1, "number of valid signatures required, ignored with -trust-policy")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *name == "" || *rootFile == "" {
+		return fmt.Errorf("%w: -ospkg and -root are required", errMissingFlag)
+	}
+
+	rootSrc, err := os.Open(*rootFile)
+	if err != nil {
+		return err
+	}
+	defer rootSrc.Close()
+
+	loaders := []opts.Loader{opts.WithSigningRootCert(rootSrc)}
+
+	if *policyFile != "" {
+		policySrc, err := os.Open(*policyFile)
+		if err != nil {
+			return err
+		}
+		defer policySrc.Close()
+
+		loaders = append(loaders, opts.WithTrustPolicy(policySrc))
+	}
+
+	stOptions, err := opts.NewOpts(loaders...)
+	if err != nil {
+		return err
+	}
+
+	if *policyFile != "" {
+		*threshold = stOptions.TrustPolicy.SignatureThreshold
+	}
+
+	osp, err := load(*name)
+	if err != nil {
+		return err
+	}
+
+	found, valid, err := osp.Verify(stOptions.SigningRoot)
+	if err != nil {
+		return err
+	}
+
+	if valid < *threshold {
+		return fmt.Errorf("%w: not enough valid signatures: %d found, %d valid, %d required", errRejected, found, valid, *threshold)
+	}
+
+	img, err := osp.LinuxImage()
+	if err != nil {
+		return fmt.Errorf("%w: %v", errRejected, err)
+	}
+
+	if img.Kernel == nil {
+		return fmt.Errorf("%w: no kernel, image not usable", errRejected)
+	}
+
+	fmt.Fprintf(stdout, "OS package passed verification: %d found, %d valid, %d required\n", found, valid, *threshold)
+
+	return nil
+}
@@ -5,19 +5,20 @@ module system-transparency.org/stboot
 go 1.19
 
 require (
+	github.com/google/go-tpm v0.3.3
+	github.com/insomniacslk/dhcp v0.0.0-20211209223715-7d93572ebe8e
 	github.com/stretchr/testify v1.7.0
 	github.com/u-root/u-root v0.10.0
 	github.com/vishvananda/netlink v1.1.1-0.20211118161826-650dca95af54
+	github.com/vishvananda/netns v0.0.0-20210104183010-2eb08e3e575f
 	golang.org/x/sys v0.0.0-20220610221304-9f5ed59c137d
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/dustin/go-humanize v1.0.0 // indirect
-	github.com/google/go-tpm v0.3.3 // indirect
 	github.com/google/goexpect v0.0.0-20210330220015-096e5d1cbd97 // indirect
 	github.com/google/uuid v1.3.0 // indirect
-	github.com/insomniacslk/dhcp v0.0.0-20211209223715-7d93572ebe8e // indirect
 	github.com/klauspost/compress v1.10.6 // indirect
 	github.com/kr/pretty v0.3.0 // indirect
 	github.com/mdlayher/ethernet v0.0.0-20190606142754-0394541c37b7 // indirect
@@ -28,7 +29,6 @@ require (
 	github.com/rogpeppe/go-internal v1.8.1-0.20210923151022-86f73c517451 // indirect
 	github.com/u-root/uio v0.0.0-20220204230159-dac05f7d2cb4 // indirect
 	github.com/ulikunitz/xz v0.5.8 // indirect
-	github.com/vishvananda/netns v0.0.0-20210104183010-2eb08e3e575f // indirect
 	golang.org/x/net v0.0.0-20211015210444-4f30a5c0130f // indirect
 	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c // indirect
 )
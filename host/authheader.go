@@ -0,0 +1,74 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package host
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// AuthScheme is how the authentication secret is sent in an HTTP header.
+type AuthScheme string
+
+// With AuthBearer, the secret is sent as bearer token. With AuthHMACSHA256,
+// the secret is the key of an HMAC-SHA256 over the request and a timestamp,
+// so it is never sent itself.
+const (
+	AuthBearer     AuthScheme = "bearer"
+	AuthHMACSHA256 AuthScheme = "hmac-sha256"
+)
+
+// DefaultAuthHeaderName is used if AuthHeader.Name is empty.
+const DefaultAuthHeaderName = "Authorization"
+
+// AuthHeader sends the authentication field of the host configuration in an
+// HTTP header instead of substituting $AUTH in URLs. The header is sent to
+// the hosts of the OS package pointer and the OS package stores only.
+type AuthHeader struct {
+	Scheme AuthScheme `json:"scheme"`
+	Name   string     `json:"name,omitempty"`
+}
+
+// HeaderName returns the name of the header carrying the credential.
+func (a *AuthHeader) HeaderName() string {
+	if a.Name == "" {
+		return DefaultAuthHeaderName
+	}
+
+	return a.Name
+}
+
+var (
+	headerToken = regexp.MustCompile("^[!#$%&'*+.^_`|~0-9A-Za-z-]+$")
+	token68     = regexp.MustCompile(`^[0-9A-Za-z._~+/-]+=*$`)
+)
+
+func checkAuthHeader(cfg *Config) error {
+	a := cfg.AuthHeader
+	if a == nil {
+		return nil
+	}
+
+	switch a.Scheme {
+	case AuthBearer, AuthHMACSHA256:
+	default:
+		return fmt.Errorf("%w: unknown scheme %q", ErrInvalidAuthHeader, a.Scheme)
+	}
+
+	if a.Name != "" && !headerToken.MatchString(a.Name) {
+		return fmt.Errorf("%w: invalid header name %q", ErrInvalidAuthHeader, a.Name)
+	}
+
+	if cfg.Auth == nil || !token68.MatchString(*cfg.Auth) {
+		return fmt.Errorf("%w: authentication must be a non-empty token", ErrInvalidAuthHeader)
+	}
+
+	if cfg.OSPkgPointer != nil && strings.Contains(*cfg.OSPkgPointer, "$AUTH") {
+		return fmt.Errorf("%w: $AUTH must not be used in URLs", ErrInvalidAuthHeader)
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package host
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestConfigAuthHeader(t *testing.T) {
+	config := func(header, auth, pointer string) string {
+		return `{
+			"auth_header":` + header + `,
+			"network_mode":"dhcp",
+			"host_ip":null,
+			"gateway":null,
+			"dns":null,
+			"ospkg_pointer":"` + pointer + `",
+			"identity":null,
+			"authentication":` + auth + `,
+			"network_interfaces":null,
+			"bonding_mode":null,
+			"bond_name":null}`
+	}
+
+	validtests := []struct {
+		name   string
+		header string
+	}{
+		{"Bearer", `{"scheme":"bearer"}`},
+		{"HMAC", `{"scheme":"hmac-sha256"}`},
+		{"Custom name", `{"scheme":"bearer", "name":"X-Stboot-Auth"}`},
+	}
+
+	for _, tt := range validtests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg Config
+			if err := json.Unmarshal([]byte(config(tt.header, `"c2VjcmV0"`, "https://server.com")), &cfg); err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := json.Marshal(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var again Config
+			if err := json.Unmarshal(data, &again); err != nil {
+				t.Fatalf("round trip %s: %v", data, err)
+			}
+		})
+	}
+
+	invalidtests := []struct {
+		name    string
+		header  string
+		auth    string
+		pointer string
+	}{
+		{"Unknown scheme", `{"scheme":"basic"}`, `"c2VjcmV0"`, "https://server.com"},
+		{"Bad name", `{"scheme":"bearer", "name":"X Auth"}`, `"c2VjcmV0"`, "https://server.com"},
+		{"No authentication", `{"scheme":"bearer"}`, `null`, "https://server.com"},
+		{"Authentication with space", `{"scheme":"bearer"}`, `"sec ret"`, "https://server.com"},
+		{"AUTH in URL", `{"scheme":"bearer"}`, `"secret"`, "https://server.com/$AUTH/os"},
+	}
+
+	for _, tt := range invalidtests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg Config
+
+			err := json.Unmarshal([]byte(config(tt.header, tt.auth, tt.pointer)), &cfg)
+			if !errors.Is(err, ErrInvalidAuthHeader) {
+				t.Errorf("got %v, want %v", err, ErrInvalidAuthHeader)
+			}
+		})
+	}
+}
@@ -0,0 +1,255 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package host
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpmutil"
+)
+
+// Sources of client certificates and keys.
+const (
+	SourceFile   = "file"
+	SourceEFIVar = "efivar"
+	SourceTPM    = "tpm"
+)
+
+// Persistent TPM 2.0 object handles.
+const (
+	tpmPersistentFirst = 0x81000000
+	tpmPersistentLast  = 0x81ffffff
+)
+
+// ClientCert is the TLS client certificate presented to OS package servers,
+// so they can authenticate the device.
+//
+// Certificate and Key name the source of the PEM encoded certificate chain,
+// leaf first, and private key: "file:<path>" or "efivar:<name>-<guid>". Key
+// may also be "tpm:<handle>", a persistent TPM 2.0 signing key without
+// authorization, e.g. "tpm:0x81000100". ECDSA is recommended for TPM keys,
+// since some TPMs create RSA-PSS signatures with a salt length TLS 1.3 does
+// not accept.
+type ClientCert struct {
+	Certificate string `json:"certificate"`
+	Key         string `json:"key"`
+}
+
+// Load reads the certificate chain and the key.
+func (c *ClientCert) Load() (tls.Certificate, error) {
+	certPEM, err := readSource(c.Certificate)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("client certificate: %w", err)
+	}
+
+	scheme, ref, _ := strings.Cut(c.Key, ":")
+	if scheme != SourceTPM {
+		keyPEM, err := readSource(c.Key)
+		if err != nil {
+			return tls.Certificate{}, fmt.Errorf("client key: %w", err)
+		}
+
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return tls.Certificate{}, err
+		}
+
+		if cert.Leaf == nil {
+			cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0])
+		}
+
+		return cert, err
+	}
+
+	var cert tls.Certificate
+
+	for block, rest := pem.Decode(certPEM); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type == "CERTIFICATE" {
+			cert.Certificate = append(cert.Certificate, block.Bytes)
+		}
+	}
+
+	if len(cert.Certificate) == 0 {
+		return tls.Certificate{}, errors.New("client certificate: no certificate found")
+	}
+
+	if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+		return tls.Certificate{}, fmt.Errorf("client certificate: %w", err)
+	}
+
+	handle, _ := parseTPMHandle(ref)
+
+	signer, err := newTPMSigner(handle)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("client key: %w", err)
+	}
+
+	pub, ok := cert.Leaf.PublicKey.(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !pub.Equal(signer.Public()) {
+		return tls.Certificate{}, errors.New("client key: TPM key does not match the certificate")
+	}
+
+	cert.PrivateKey = signer
+
+	return cert, nil
+}
+
+// readSource reads a file or an EFI variable.
+func readSource(src string) ([]byte, error) {
+	scheme, ref, _ := strings.Cut(src, ":")
+
+	switch scheme {
+	case SourceFile:
+		return os.ReadFile(ref)
+	case SourceEFIVar:
+		r, err := readEFIVar(ref)
+		if err != nil {
+			return nil, err
+		}
+
+		return io.ReadAll(r)
+	default:
+		return nil, fmt.Errorf("unsupported source %q", src)
+	}
+}
+
+func parseTPMHandle(s string) (tpmutil.Handle, error) {
+	h, err := strconv.ParseUint(s, 0, 32)
+	if err != nil {
+		return 0, err
+	}
+
+	if h < tpmPersistentFirst || h > tpmPersistentLast {
+		return 0, fmt.Errorf("%#x is not a persistent handle", h)
+	}
+
+	return tpmutil.Handle(h), nil
+}
+
+func checkClientCert(cfg *Config) error {
+	c := cfg.ClientCert
+	if c == nil {
+		return nil
+	}
+
+	if err := checkSource(c.Certificate, false); err != nil {
+		return fmt.Errorf("%w: certificate: %v", ErrInvalidClientCert, err)
+	}
+
+	if err := checkSource(c.Key, true); err != nil {
+		return fmt.Errorf("%w: key: %v", ErrInvalidClientCert, err)
+	}
+
+	return nil
+}
+
+func checkSource(src string, allowTPM bool) error {
+	scheme, ref, _ := strings.Cut(src, ":")
+
+	switch {
+	case ref == "":
+		return fmt.Errorf("%q has no reference", src)
+	case scheme == SourceFile || scheme == SourceEFIVar:
+		return nil
+	case scheme == SourceTPM && allowTPM:
+		_, err := parseTPMHandle(ref)
+
+		return err
+	default:
+		return fmt.Errorf("unsupported source %q", src)
+	}
+}
+
+// tpmSigner signs with a persistent TPM 2.0 key. The TPM is opened for each
+// signature, so it can be shared with measurements.
+type tpmSigner struct {
+	handle tpmutil.Handle
+	public crypto.PublicKey
+}
+
+func newTPMSigner(handle tpmutil.Handle) (*tpmSigner, error) {
+	rwc, err := tpm2.OpenTPM()
+	if err != nil {
+		return nil, err
+	}
+	defer rwc.Close()
+
+	pub, _, _, err := tpm2.ReadPublic(rwc, handle)
+	if err != nil {
+		return nil, err
+	}
+
+	if pub.Attributes&tpm2.FlagSign == 0 {
+		return nil, fmt.Errorf("TPM key %#x is not a signing key", handle)
+	}
+
+	key, err := pub.Key()
+	if err != nil {
+		return nil, err
+	}
+
+	return &tpmSigner{handle: handle, public: key}, nil
+}
+
+// Public implements crypto.Signer.
+func (s *tpmSigner) Public() crypto.PublicKey {
+	return s.public
+}
+
+// Sign implements crypto.Signer.
+func (s *tpmSigner) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	hash, err := tpm2.HashToAlgorithm(opts.HashFunc())
+	if err != nil {
+		return nil, err
+	}
+
+	scheme := &tpm2.SigScheme{Hash: hash}
+
+	switch s.public.(type) {
+	case *ecdsa.PublicKey:
+		scheme.Alg = tpm2.AlgECDSA
+	case *rsa.PublicKey:
+		scheme.Alg = tpm2.AlgRSASSA
+		if _, ok := opts.(*rsa.PSSOptions); ok {
+			scheme.Alg = tpm2.AlgRSAPSS
+		}
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", s.public)
+	}
+
+	rwc, err := tpm2.OpenTPM()
+	if err != nil {
+		return nil, err
+	}
+	defer rwc.Close()
+
+	sig, err := tpm2.Sign(rwc, s.handle, "", digest, nil, scheme)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case sig.ECC != nil:
+		return asn1.Marshal(struct{ R, S *big.Int }{sig.ECC.R, sig.ECC.S})
+	case sig.RSA != nil:
+		return sig.RSA.Signature, nil
+	default:
+		return nil, errors.New("TPM returned no signature")
+	}
+}
@@ -0,0 +1,152 @@
+package host
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestConfigClientCert(t *testing.T) {
+	const common = `
+		"network_mode":"dhcp",
+		"host_ip":null,
+		"gateway":null,
+		"dns":null,
+		"ospkg_pointer":"http://server.com",
+		"identity":null,
+		"authentication":null,
+		"network_interfaces":null,
+		"bonding_mode":null,
+		"bond_name":null`
+
+	validtests := []struct {
+		name string
+		cert string
+	}{
+		{"Files", `{"certificate":"file:/etc/stboot/client.pem", "key":"file:/etc/stboot/client.key"}`},
+		{"EFI variables", `{"certificate":"efivar:STClientCert-f401f2c1-b005-4be0-8cee-f2e5945bcbe7", "key":"efivar:STClientKey-f401f2c1-b005-4be0-8cee-f2e5945bcbe7"}`},
+		{"TPM", `{"certificate":"file:/etc/stboot/client.pem", "key":"tpm:0x81000100"}`},
+	}
+
+	invalidtests := []struct {
+		name string
+		cert string
+	}{
+		{"Missing key", `{"certificate":"file:/etc/stboot/client.pem"}`},
+		{"No scheme", `{"certificate":"/etc/stboot/client.pem", "key":"file:/etc/stboot/client.key"}`},
+		{"Empty path", `{"certificate":"file:", "key":"file:/etc/stboot/client.key"}`},
+		{"TPM certificate", `{"certificate":"tpm:0x81000100", "key":"tpm:0x81000100"}`},
+		{"Transient handle", `{"certificate":"file:/etc/stboot/client.pem", "key":"tpm:0x80000001"}`},
+		{"Bad handle", `{"certificate":"file:/etc/stboot/client.pem", "key":"tpm:ek"}`},
+	}
+
+	for _, tt := range validtests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg Config
+			if err := json.Unmarshal([]byte(`{"tls_client_cert":`+tt.cert+`,`+common+`}`), &cfg); err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := json.Marshal(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var again Config
+			if err := json.Unmarshal(data, &again); err != nil {
+				t.Fatalf("round trip %s: %v", data, err)
+			}
+		})
+	}
+
+	for _, tt := range invalidtests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg Config
+
+			err := json.Unmarshal([]byte(`{"tls_client_cert":`+tt.cert+`,`+common+`}`), &cfg)
+			if err == nil {
+				t.Error("expect an error")
+			}
+
+			if tt.name != "Missing key" && !errors.Is(err, ErrInvalidClientCert) {
+				t.Errorf("got %v, want %v", err, ErrInvalidClientCert)
+			}
+		})
+	}
+}
+
+// writeClientCert writes a self-signed certificate and its key as PEM files.
+func writeClientCert(t *testing.T, dir, name string) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile := filepath.Join(dir, name+".pem")
+	keyFile := filepath.Join(dir, name+".key")
+
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return certFile, keyFile
+}
+
+func TestClientCertLoad(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeClientCert(t, dir, "device")
+	_, otherKey := writeClientCert(t, dir, "other")
+
+	c := ClientCert{Certificate: "file:" + certFile, Key: "file:" + keyFile}
+
+	cert, err := c.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cert.Leaf == nil || cert.Leaf.Subject.CommonName != "device" {
+		t.Errorf("got leaf %v, want subject device", cert.Leaf)
+	}
+
+	for name, c := range map[string]ClientCert{
+		"mismatched key": {Certificate: "file:" + certFile, Key: "file:" + otherKey},
+		"missing file":   {Certificate: "file:" + filepath.Join(dir, "missing"), Key: "file:" + keyFile},
+	} {
+		if _, err := c.Load(); err == nil {
+			t.Errorf("%s: expect an error", name)
+		}
+	}
+}
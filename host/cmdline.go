@@ -0,0 +1,272 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package host
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"system-transparency.org/stboot/internal/jsonutil"
+	"system-transparency.org/stboot/sterror"
+)
+
+// Kernel command line parameters read by the cmdline host configuration
+// source.
+//
+// CmdlineConfigParam carries a complete host configuration as base64 encoded
+// JSON, standard or URL-safe, with or without padding. Individual fields are
+// set by CmdlineParamPrefix followed by the JSON key, e.g.
+// stboot.ospkg_pointer=https://example.org/os.json. They take precedence over
+// the fields of CmdlineConfigParam and missing fields are null.
+//
+// List fields take a comma separated list. Network interfaces are given as
+// NAME@MAC, e.g. stboot.network_interfaces=eth0@00:11:22:33:44:55. The value
+// "null" unsets a field.
+const (
+	CmdlinePath        = "/proc/cmdline"
+	CmdlineParamPrefix = "stboot."
+	CmdlineConfigParam = CmdlineParamPrefix + "host_config"
+)
+
+// Errors which may be raised and wrapped in this package.
+var (
+	ErrNoConfigParams     = errors.New("no stboot host configuration parameters")
+	ErrInvalidConfigParam = errors.New("invalid stboot host configuration parameter")
+)
+
+type cmdline struct {
+	path string
+}
+
+var _ configLoader = &cmdline{}
+
+func (c *cmdline) probe() (io.Reader, error) {
+	const operation = sterror.Op("read kernel cmdline")
+
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		return nil, sterror.E(ErrScope, operation, err.Error())
+	}
+
+	cfg, err := ConfigFromCmdline(string(data))
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewReader(cfg), nil
+}
+
+func (c *cmdline) info() string {
+	return fmt.Sprintf("Probing kernel command line at %s", c.path)
+}
+
+// ConfigFromCmdline returns the host configuration JSON set by the stboot
+// parameters of the kernel command line cmdline. If there are none,
+// ErrNoConfigParams is returned.
+func ConfigFromCmdline(cmdline string) ([]byte, error) {
+	return configFromParams(splitCmdline(cmdline))
+}
+
+// configFromParams returns the host configuration JSON set by the stboot
+// parameters in params. Other parameters are ignored.
+func configFromParams(params []string) ([]byte, error) {
+	var blob []byte
+
+	fields := make(map[string]json.RawMessage)
+
+	for _, param := range params {
+		key, value, _ := strings.Cut(param, "=")
+		if !strings.HasPrefix(key, CmdlineParamPrefix) {
+			continue
+		}
+
+		if key == CmdlineConfigParam {
+			data, err := decodeBase64(value)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s: %v", ErrInvalidConfigParam, key, err)
+			}
+
+			blob = data
+
+			continue
+		}
+
+		name := strings.TrimPrefix(key, CmdlineParamPrefix)
+
+		raw, err := cmdlineValue(name, value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidConfigParam, key, err)
+		}
+
+		fields[name] = raw
+	}
+
+	switch {
+	case blob == nil && len(fields) == 0:
+		return nil, ErrNoConfigParams
+	case len(fields) == 0:
+		return blob, nil
+	case blob != nil && IsConfigEnvelope(blob):
+		return nil, fmt.Errorf("%w: signed %s cannot be combined with single fields",
+			ErrInvalidConfigParam, CmdlineConfigParam)
+	}
+
+	merged := make(map[string]json.RawMessage)
+
+	for _, name := range jsonutil.Required(Config{}) {
+		merged[name] = json.RawMessage(jsonutil.Null)
+	}
+
+	if blob != nil {
+		if err := json.Unmarshal(blob, &merged); err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidConfigParam, CmdlineConfigParam, err)
+		}
+	}
+
+	for name, raw := range fields {
+		merged[name] = raw
+	}
+
+	return json.Marshal(merged)
+}
+
+// cmdlineValue converts the kernel command line value of the host
+// configuration field name to JSON.
+func cmdlineValue(name, value string) (json.RawMessage, error) {
+	known := false
+
+	for _, n := range jsonutil.Names(Config{}) {
+		if n == name {
+			known = true
+
+			break
+		}
+	}
+
+	if !known {
+		return nil, fmt.Errorf("unknown host configuration field %q", name)
+	}
+
+	if value == jsonutil.Null {
+		return json.RawMessage(jsonutil.Null), nil
+	}
+
+	switch name {
+	case "dns", "additional_host_ips", "ospkg_stores":
+		return json.Marshal(strings.Split(value, ","))
+	case "network_interfaces":
+		type iface struct {
+			Name string `json:"interface_name"`
+			MAC  string `json:"mac_address"`
+		}
+
+		var ifaces []iface
+
+		for _, item := range strings.Split(value, ",") {
+			name, mac, ok := strings.Cut(item, "@")
+			if !ok {
+				return nil, fmt.Errorf("want NAME@MAC, got %q", item)
+			}
+
+			ifaces = append(ifaces, iface{Name: name, MAC: mac})
+		}
+
+		return json.Marshal(ifaces)
+	case "routes", "link_selectors":
+		return nil, fmt.Errorf("%s is only supported in %s", name, CmdlineConfigParam)
+	case "proxy":
+		// Credentials may be given as user info of the URL.
+		u, err := url.Parse(value)
+		if err != nil {
+			return nil, err
+		}
+
+		p := Proxy{URL: u}
+		if u.User != nil {
+			p.Username = u.User.Username()
+			p.Password, _ = u.User.Password()
+			u.User = nil
+		}
+
+		return json.Marshal(p)
+	case "tls_client_cert":
+		cert, key, ok := strings.Cut(value, ",")
+		if !ok {
+			return nil, fmt.Errorf("want CERTIFICATE,KEY, got %q", value)
+		}
+
+		return json.Marshal(ClientCert{Certificate: cert, Key: key})
+	case "auth_header":
+		scheme, headerName, _ := strings.Cut(value, ",")
+
+		return json.Marshal(AuthHeader{Scheme: AuthScheme(scheme), Name: headerName})
+	case "vlan_id", "link_timeout_seconds":
+		n, err := strconv.ParseUint(value, 10, 31)
+		if err != nil {
+			return nil, err
+		}
+
+		return json.Marshal(n)
+	default:
+		return json.Marshal(value)
+	}
+}
+
+func decodeBase64(s string) ([]byte, error) {
+	for _, enc := range []*base64.Encoding{
+		base64.StdEncoding,
+		base64.RawStdEncoding,
+		base64.URLEncoding,
+		base64.RawURLEncoding,
+	} {
+		if data, err := enc.DecodeString(s); err == nil {
+			return data, nil
+		}
+	}
+
+	return nil, errors.New("invalid base64 data")
+}
+
+// splitCmdline splits the kernel command line into parameters. Like the
+// kernel, it allows double quotes to protect spaces.
+func splitCmdline(cmdline string) []string {
+	var (
+		params []string
+		param  strings.Builder
+		quoted bool
+		inside bool
+	)
+
+	for _, r := range cmdline {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			inside = true
+		case !quoted && (r == ' ' || r == '\t' || r == '\n'):
+			if inside {
+				params = append(params, param.String())
+				param.Reset()
+				inside = false
+			}
+		default:
+			param.WriteRune(r)
+			inside = true
+		}
+	}
+
+	if inside {
+		params = append(params, param.String())
+	}
+
+	return params
+}
@@ -38,20 +38,50 @@ var (
 	ErrInvalidID                = errors.New("invalid ID string, min 1 char, allowed chars are [a-z,A-Z,0-9,-,_]")
 	ErrMissingAuth              = errors.New("field Auth must be set when a URL contains '$AUTH'")
 	ErrInvalidAuth              = errors.New("invalid auth string, min 1 char, allowed chars are [a-z,A-Z,0-9,-,_]")
+	ErrUnsupportedVersion       = errors.New("unsupported host configuration version")
+	ErrInvalidOSPkgStore        = errors.New("OS package store must be an http or https URL")
+	ErrIPFamilyMismatch         = errors.New("host IP and gateway must be of the same address family")
+	ErrInvalidIPv6Mode          = errors.New("IPv6 network mode must be slaac, dhcpv6 or static alongside an IPv4 network mode")
+	ErrMissingIPv6Addr          = errors.New("field IPv6 address must be set when IPv6 mode static is set")
+	ErrMissingIPv6Gateway       = errors.New("IPv6 gateway must be set when IPv6 mode static is set")
+	ErrUnexpectedIPv6Static     = errors.New("IPv6 address and gateway require IPv6 mode static")
+	ErrInvalidVLANID            = errors.New("VLAN ID must be between 1 and 4094")
+	ErrUnexpectedStaticOptions  = errors.New("additional host IPs and routes require static IP mode")
+	ErrInvalidRoute             = errors.New("invalid route")
+	ErrInvalidProxy             = errors.New("invalid proxy")
+	ErrInvalidLinkSelector      = errors.New("invalid link selector")
+	ErrLinkSelectorsConflict    = errors.New("link selectors and network interfaces are mutually exclusive")
+	ErrInvalidLinkTimeout       = errors.New("link timeout must not be negative")
+	ErrInvalidClientCert        = errors.New("invalid TLS client certificate")
+	ErrInvalidAuthHeader        = errors.New("invalid authentication header")
 )
 
+// ConfigVersion is the version of the host configuration format. The JSON key
+// "version" is optional and, if present, must match ConfigVersion.
+const ConfigVersion int = 0
+
 // IPAddrMode sets the method for network setup.
 type IPAddrMode int
 
+// IPStatic takes an IPv4 or IPv6 address and gateway. IPDynamic uses DHCPv4,
+// IPSLAAC stateless address autoconfiguration and IPDHCPv6 DHCPv6 with the
+// default route taken from router advertisements.
 const (
 	IPUnset IPAddrMode = iota
 	IPStatic
 	IPDynamic
+	IPSLAAC
+	IPDHCPv6
 )
 
 // String implements fmt.Stringer.
 func (i IPAddrMode) String() string {
-	return [...]string{"unset", "static", "dhcp"}[i]
+	return [...]string{"unset", "static", "dhcp", "slaac", "dhcpv6"}[i]
+}
+
+// IsIPv6 returns true for the IPv6 only modes IPSLAAC and IPDHCPv6.
+func (i IPAddrMode) IsIPv6() bool {
+	return i == IPSLAAC || i == IPDHCPv6
 }
 
 // MarshalJSON implements json.Marshaler.
@@ -76,6 +106,8 @@ func (i *IPAddrMode) UnmarshalJSON(data []byte) error {
 		toID := map[string]IPAddrMode{
 			"static": IPStatic,
 			"dhcp":   IPDynamic,
+			"slaac":  IPSLAAC,
+			"dhcpv6": IPDHCPv6,
 		}
 		mode, ok := toID[str]
 		if !ok {
@@ -188,7 +220,7 @@ func (n *NetworkInterface) UnmarshalJSON(data []byte) error {
 		InterfaceName string `json:"interface_name"`
 		MACAddress    string `json:"mac_address"`
 	}{}
-	if err := json.Unmarshal(data, &aux); err != nil {
+	if err := jsonutil.UnmarshalStrict(data, &aux); err != nil {
 		return err
 	}
 
@@ -203,18 +235,114 @@ func (n *NetworkInterface) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// Route is a static route to Destination. Without Gateway, Destination is
+// reached directly on the link. Link names the interface to use and defaults
+// to the configured one.
+type Route struct {
+	Destination *net.IPNet
+	Gateway     net.IP
+	Metric      uint32
+	Link        string
+}
+
+type route struct {
+	Destination string `json:"destination"`
+	Gateway     *netIP `json:"gateway,omitempty"`
+	Metric      uint32 `json:"metric,omitempty"`
+	Link        string `json:"link,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (r Route) MarshalJSON() ([]byte, error) {
+	aux := route{
+		Metric: r.Metric,
+		Link:   r.Link,
+	}
+
+	if r.Destination != nil {
+		aux.Destination = r.Destination.String()
+	}
+
+	if r.Gateway != nil {
+		aux.Gateway = (*netIP)(&r.Gateway)
+	}
+
+	return json.Marshal(aux)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (r *Route) UnmarshalJSON(data []byte) error {
+	var aux route
+	if err := jsonutil.UnmarshalStrict(data, &aux); err != nil {
+		return err
+	}
+
+	_, dst, err := net.ParseCIDR(aux.Destination)
+	if err != nil {
+		return &json.UnmarshalTypeError{
+			Value: fmt.Sprintf("string %q", aux.Destination),
+			Type:  reflect.TypeOf(dst),
+		}
+	}
+
+	*r = Route{
+		Destination: dst,
+		Metric:      aux.Metric,
+		Link:        aux.Link,
+	}
+
+	if aux.Gateway != nil {
+		r.Gateway = net.IP(*aux.Gateway)
+	}
+
+	return nil
+}
+
 // Config stores host specific configuration.
+//
+// OSPkgStores optionally lists base URLs of content-addressed stores. OS
+// packages, whose descriptor names the archive by its SHA-256, are fetched
+// from <store>/<hex encoded SHA-256>.
+//
+// IPAddrMode configures a single stack, IPv4 or IPv6. For dual-stack, it sets
+// up IPv4 and the optional IPv6AddrMode sets up IPv6 on the same interface.
+// HostIPv6 and DefaultGatewayV6 are used with IPv6AddrMode static.
+//
+// AdditionalHostIPs and Routes extend static IP mode. The additional addresses
+// are configured next to HostIP and the routes are added after the default
+// route, e.g. to reach a management subnet.
+//
+// LinkSelectors optionally choose the network interfaces to try, in order,
+// instead of NetworkInterfaces. LinkTimeoutSeconds bounds waiting for carrier,
+// see DefaultLinkTimeout.
+//
+// Proxy optionally routes downloads through an HTTP proxy.
+//
+// VLANID optionally tags all traffic with an 802.1Q VLAN ID. The VLAN
+// interface is created on top of the selected network interface or bond.
 type Config struct {
-	IPAddrMode        *IPAddrMode          `json:"network_mode"`
-	HostIP            *netlink.Addr        `json:"host_ip"`
-	DefaultGateway    *net.IP              `json:"gateway"`
-	DNSServer         *[]*net.IP           `json:"dns"`
-	NetworkInterfaces *[]*NetworkInterface `json:"network_interfaces"`
-	OSPkgPointer      *string              `json:"ospkg_pointer"`
-	ID                *string              `json:"identity"`
-	Auth              *string              `json:"authentication"`
-	BondingMode       BondingMode          `json:"bonding_mode"`
-	BondName          *string              `json:"bond_name"`
+	IPAddrMode         *IPAddrMode          `json:"network_mode"`
+	HostIP             *netlink.Addr        `json:"host_ip"`
+	DefaultGateway     *net.IP              `json:"gateway"`
+	AdditionalHostIPs  *[]*netlink.Addr     `json:"additional_host_ips,omitempty"`
+	Routes             *[]*Route            `json:"routes,omitempty"`
+	IPv6AddrMode       *IPAddrMode          `json:"network_mode_ipv6,omitempty"`
+	HostIPv6           *netlink.Addr        `json:"host_ipv6,omitempty"`
+	DefaultGatewayV6   *net.IP              `json:"gateway_ipv6,omitempty"`
+	DNSServer          *[]*net.IP           `json:"dns"`
+	NetworkInterfaces  *[]*NetworkInterface `json:"network_interfaces"`
+	LinkSelectors      *[]*LinkSelector     `json:"link_selectors,omitempty"`
+	LinkTimeoutSeconds *int                 `json:"link_timeout_seconds,omitempty"`
+	OSPkgPointer       *string              `json:"ospkg_pointer"`
+	ID                 *string              `json:"identity"`
+	Auth               *string              `json:"authentication"`
+	BondingMode        BondingMode          `json:"bonding_mode"`
+	BondName           *string              `json:"bond_name"`
+	VLANID             *uint16              `json:"vlan_id,omitempty"`
+	Proxy              *Proxy               `json:"proxy,omitempty"`
+	ClientCert         *ClientCert          `json:"tls_client_cert,omitempty"`
+	AuthHeader         *AuthHeader          `json:"auth_header,omitempty"`
+	OSPkgStores        *[]string            `json:"ospkg_stores,omitempty"`
 }
 
 // NewConfig returns a new Config from template. It is not save to further use template.
@@ -227,31 +355,61 @@ func NewConfig(template Config) (Config, error) {
 }
 
 type config struct {
-	IPAddrMode        *IPAddrMode          `json:"network_mode"`
-	HostIP            *netlinkAddr         `json:"host_ip"`
-	DefaultGateway    *netIP               `json:"gateway"`
-	DNSServer         *[]*netIP            `json:"dns"`
-	NetworkInterfaces *[]*NetworkInterface `json:"network_interfaces"`
-	OSPkgPointer      *string              `json:"ospkg_pointer"`
-	ID                *string              `json:"identity"`
-	Auth              *string              `json:"authentication"`
-	BondingMode       BondingMode          `json:"bonding_mode"`
-	BondName          *string              `json:"bond_name"`
+	Version            int                  `json:"version,omitempty"`
+	IPAddrMode         *IPAddrMode          `json:"network_mode"`
+	HostIP             *netlinkAddr         `json:"host_ip"`
+	DefaultGateway     *netIP               `json:"gateway"`
+	AdditionalHostIPs  *[]*netlinkAddr      `json:"additional_host_ips,omitempty"`
+	Routes             *[]*Route            `json:"routes,omitempty"`
+	IPv6AddrMode       *IPAddrMode          `json:"network_mode_ipv6,omitempty"`
+	HostIPv6           *netlinkAddr         `json:"host_ipv6,omitempty"`
+	DefaultGatewayV6   *netIP               `json:"gateway_ipv6,omitempty"`
+	DNSServer          *[]*netIP            `json:"dns"`
+	NetworkInterfaces  *[]*NetworkInterface `json:"network_interfaces"`
+	LinkSelectors      *[]*LinkSelector     `json:"link_selectors,omitempty"`
+	LinkTimeoutSeconds *int                 `json:"link_timeout_seconds,omitempty"`
+	OSPkgPointer       *string              `json:"ospkg_pointer"`
+	ID                 *string              `json:"identity"`
+	Auth               *string              `json:"authentication"`
+	BondingMode        BondingMode          `json:"bonding_mode"`
+	BondName           *string              `json:"bond_name"`
+	VLANID             *uint16              `json:"vlan_id,omitempty"`
+	Proxy              *Proxy               `json:"proxy,omitempty"`
+	ClientCert         *ClientCert          `json:"tls_client_cert,omitempty"`
+	AuthHeader         *AuthHeader          `json:"auth_header,omitempty"`
+	OSPkgStores        *[]string            `json:"ospkg_stores,omitempty"`
+
+	// Keys of earlier releases, accepted for compatibility and ignored.
+	ProvisioningURLs json.RawMessage `json:"provisioning_urls,omitempty"`
+	Timestamp        json.RawMessage `json:"timestamp,omitempty"`
+	NetworkInterface json.RawMessage `json:"network_interface,omitempty"`
 }
 
 // MarshalJSON implements json.Marshaler.
 func (c Config) MarshalJSON() ([]byte, error) {
 	alias := config{
-		IPAddrMode:        c.IPAddrMode,
-		HostIP:            (*netlinkAddr)(c.HostIP),
-		DefaultGateway:    (*netIP)(c.DefaultGateway),
-		DNSServer:         ips2alias(c.DNSServer),
-		OSPkgPointer:      c.OSPkgPointer,
-		ID:                c.ID,
-		Auth:              c.Auth,
-		NetworkInterfaces: c.NetworkInterfaces,
-		BondingMode:       c.BondingMode,
-		BondName:          c.BondName,
+		IPAddrMode:         c.IPAddrMode,
+		HostIP:             (*netlinkAddr)(c.HostIP),
+		DefaultGateway:     (*netIP)(c.DefaultGateway),
+		AdditionalHostIPs:  addrs2alias(c.AdditionalHostIPs),
+		Routes:             c.Routes,
+		IPv6AddrMode:       c.IPv6AddrMode,
+		HostIPv6:           (*netlinkAddr)(c.HostIPv6),
+		DefaultGatewayV6:   (*netIP)(c.DefaultGatewayV6),
+		DNSServer:          ips2alias(c.DNSServer),
+		OSPkgPointer:       c.OSPkgPointer,
+		ID:                 c.ID,
+		Auth:               c.Auth,
+		NetworkInterfaces:  c.NetworkInterfaces,
+		LinkSelectors:      c.LinkSelectors,
+		LinkTimeoutSeconds: c.LinkTimeoutSeconds,
+		BondingMode:        c.BondingMode,
+		BondName:           c.BondName,
+		VLANID:             c.VLANID,
+		Proxy:              c.Proxy,
+		ClientCert:         c.ClientCert,
+		AuthHeader:         c.AuthHeader,
+		OSPkgStores:        c.OSPkgStores,
 	}
 
 	return json.Marshal(alias)
@@ -259,14 +417,26 @@ func (c Config) MarshalJSON() ([]byte, error) {
 
 // UnmarshalJSON implements json.Unmarshaler.
 //
-// All fields of Config need to be present in JSON.
+// All fields of Config need to be present in JSON, except the ones tagged
+// omitempty. Unknown keys and trailing data are rejected. The keys
+// provisioning_urls, timestamp and network_interface of earlier releases are
+// ignored.
 func (c *Config) UnmarshalJSON(data []byte) error {
 	var jsonMap map[string]interface{}
 	if err := json.Unmarshal(data, &jsonMap); err != nil {
 		return err
 	}
 
-	tags := jsonutil.Tags(c)
+	version, err := jsonutil.Version(data)
+	if err != nil {
+		return err
+	}
+
+	if version != ConfigVersion {
+		return fmt.Errorf("%w: %d, expected %d", ErrUnsupportedVersion, version, ConfigVersion)
+	}
+
+	tags := jsonutil.Required(c)
 	for _, tag := range tags {
 		if _, ok := jsonMap[tag]; !ok {
 			stlog.Debug("All fields of host config are expected to be set or unset. Missing json key %q", tag)
@@ -276,20 +446,32 @@ func (c *Config) UnmarshalJSON(data []byte) error {
 	}
 
 	alias := config{}
-	if err := json.Unmarshal(data, &alias); err != nil {
+	if err := jsonutil.UnmarshalStrict(data, &alias); err != nil {
 		return err
 	}
 
 	c.IPAddrMode = alias.IPAddrMode
 	c.HostIP = (*netlink.Addr)(alias.HostIP)
 	c.DefaultGateway = (*net.IP)(alias.DefaultGateway)
+	c.AdditionalHostIPs = alias2addrs(alias.AdditionalHostIPs)
+	c.Routes = alias.Routes
+	c.IPv6AddrMode = alias.IPv6AddrMode
+	c.HostIPv6 = (*netlink.Addr)(alias.HostIPv6)
+	c.DefaultGatewayV6 = (*net.IP)(alias.DefaultGatewayV6)
 	c.DNSServer = alias2ips(alias.DNSServer)
 	c.OSPkgPointer = alias.OSPkgPointer
 	c.ID = alias.ID
 	c.Auth = alias.Auth
 	c.NetworkInterfaces = alias.NetworkInterfaces
+	c.LinkSelectors = alias.LinkSelectors
+	c.LinkTimeoutSeconds = alias.LinkTimeoutSeconds
 	c.BondingMode = alias.BondingMode
 	c.BondName = alias.BondName
+	c.VLANID = alias.VLANID
+	c.Proxy = alias.Proxy
+	c.ClientCert = alias.ClientCert
+	c.AuthHeader = alias.AuthHeader
+	c.OSPkgStores = alias.OSPkgStores
 
 	if err := c.validate(); err != nil {
 		*c = Config{}
@@ -305,11 +487,19 @@ func (c *Config) validate() error {
 		checkIPAddrMode,
 		checkHostIP,
 		checkGateway,
+		checkIPv6,
+		checkStaticOptions,
 		checkNetworkInterfaces,
+		checkLinkSelection,
 		checkOSPkgPointer,
 		checkID,
 		checkAuth,
+		checkAuthHeader,
 		checkBonding,
+		checkVLANID,
+		checkProxy,
+		checkClientCert,
+		checkOSPkgStores,
 	}
 
 	for _, f := range validationSet {
@@ -345,6 +535,79 @@ func checkGateway(cfg *Config) error {
 	return nil
 }
 
+func checkIPv6(cfg *Config) error {
+	if *cfg.IPAddrMode == IPStatic && cfg.HostIP != nil && cfg.DefaultGateway != nil {
+		if (cfg.HostIP.IP.To4() == nil) != (cfg.DefaultGateway.To4() == nil) {
+			return ErrIPFamilyMismatch
+		}
+	}
+
+	if cfg.IPv6AddrMode == nil || *cfg.IPv6AddrMode == IPUnset {
+		if cfg.HostIPv6 != nil || cfg.DefaultGatewayV6 != nil {
+			return ErrUnexpectedIPv6Static
+		}
+
+		return nil
+	}
+
+	ipv4 := *cfg.IPAddrMode == IPDynamic ||
+		*cfg.IPAddrMode == IPStatic && cfg.HostIP != nil && cfg.HostIP.IP.To4() != nil
+	if !ipv4 {
+		return ErrInvalidIPv6Mode
+	}
+
+	switch *cfg.IPv6AddrMode {
+	case IPSLAAC, IPDHCPv6:
+		if cfg.HostIPv6 != nil || cfg.DefaultGatewayV6 != nil {
+			return ErrUnexpectedIPv6Static
+		}
+	case IPStatic:
+		if cfg.HostIPv6 == nil || cfg.HostIPv6.IP.To4() != nil {
+			return ErrMissingIPv6Addr
+		}
+
+		if cfg.DefaultGatewayV6 == nil || cfg.DefaultGatewayV6.To4() != nil {
+			return ErrMissingIPv6Gateway
+		}
+	default:
+		return ErrInvalidIPv6Mode
+	}
+
+	return nil
+}
+
+func checkStaticOptions(cfg *Config) error {
+	if cfg.AdditionalHostIPs == nil && cfg.Routes == nil {
+		return nil
+	}
+
+	if *cfg.IPAddrMode != IPStatic {
+		return ErrUnexpectedStaticOptions
+	}
+
+	if cfg.AdditionalHostIPs != nil {
+		for _, addr := range *cfg.AdditionalHostIPs {
+			if addr == nil {
+				return ErrMissingIPAddr
+			}
+		}
+	}
+
+	if cfg.Routes != nil {
+		for _, r := range *cfg.Routes {
+			if r == nil || r.Destination == nil {
+				return fmt.Errorf("%w: missing destination", ErrInvalidRoute)
+			}
+
+			if r.Gateway != nil && (r.Gateway.To4() == nil) != (r.Destination.IP.To4() == nil) {
+				return fmt.Errorf("%w: %s: %v", ErrInvalidRoute, r.Destination, ErrIPFamilyMismatch)
+			}
+		}
+	}
+
+	return nil
+}
+
 func checkNetworkInterfaces(cfg *Config) error {
 	if cfg.NetworkInterfaces != nil {
 		if len(*cfg.NetworkInterfaces) == 0 {
@@ -421,6 +684,31 @@ func checkBonding(cfg *Config) error {
 	return nil
 }
 
+func checkVLANID(cfg *Config) error {
+	const maxVLANID = 4094
+
+	if cfg.VLANID != nil && (*cfg.VLANID == 0 || *cfg.VLANID > maxVLANID) {
+		return fmt.Errorf("%w: %d", ErrInvalidVLANID, *cfg.VLANID)
+	}
+
+	return nil
+}
+
+func checkOSPkgStores(cfg *Config) error {
+	if cfg.OSPkgStores == nil {
+		return nil
+	}
+
+	for _, store := range *cfg.OSPkgStores {
+		u, err := url.Parse(store)
+		if err != nil || u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+			return fmt.Errorf("%w: %q", ErrInvalidOSPkgStore, store)
+		}
+	}
+
+	return nil
+}
+
 func hasAllowedChars(str string) bool {
 	const maxLen = 64
 	if len(str) > maxLen {
@@ -464,6 +752,32 @@ func alias2ips(input *[]*netIP) *[]*net.IP {
 	return &ret
 }
 
+func addrs2alias(input *[]*netlink.Addr) *[]*netlinkAddr {
+	if input == nil {
+		return nil
+	}
+
+	ret := make([]*netlinkAddr, len(*input))
+	for i := range ret {
+		ret[i] = (*netlinkAddr)((*input)[i])
+	}
+
+	return &ret
+}
+
+func alias2addrs(input *[]*netlinkAddr) *[]*netlink.Addr {
+	if input == nil {
+		return nil
+	}
+
+	ret := make([]*netlink.Addr, len(*input))
+	for i := range ret {
+		ret[i] = (*netlink.Addr)((*input)[i])
+	}
+
+	return &ret
+}
+
 type netlinkAddr netlink.Addr
 
 func (n netlinkAddr) MarshalJSON() ([]byte, error) {
@@ -39,6 +39,16 @@ func TestIPAddrModeString(t *testing.T) {
 			mode: IPDynamic,
 			want: "dhcp",
 		},
+		{
+			name: "String for 'IPSLAAC'",
+			mode: IPSLAAC,
+			want: "slaac",
+		},
+		{
+			name: "String for 'IPDHCPv6'",
+			mode: IPDHCPv6,
+			want: "dhcpv6",
+		},
 	}
 
 	for _, tt := range tests {
@@ -726,6 +736,82 @@ func TestConfigUnmarshalJSON(t *testing.T) {
 			},
 			errType: nil,
 		},
+		{
+			name: "OS package stores",
+			json: `{
+				"network_mode":"dhcp",
+				"host_ip":null,
+				"gateway":null,
+				"dns":null,
+				"ospkg_pointer":"http://server.com",
+				"identity":null,
+				"authentication":null,
+				"network_interfaces":null,
+				"bonding_mode":null,
+				"bond_name":null,
+				"ospkg_stores":["https://cas.example/sha256", "http://mirror.example/"]
+			}`,
+			want: Config{
+				IPAddrMode:   ipam2ipam(t, IPDynamic),
+				OSPkgPointer: s2s(t, "http://server.com"),
+				OSPkgStores:  &[]string{"https://cas.example/sha256", "http://mirror.example/"},
+			},
+			errType: nil,
+		},
+		{
+			name: "Bad OS package store",
+			json: `{
+				"network_mode":"dhcp",
+				"host_ip":null,
+				"gateway":null,
+				"dns":null,
+				"ospkg_pointer":"http://server.com",
+				"identity":null,
+				"authentication":null,
+				"network_interfaces":null,
+				"bonding_mode":null,
+				"bond_name":null,
+				"ospkg_stores":["cas.example/sha256"]
+			}`,
+			want:    Config{},
+			errType: ErrInvalidOSPkgStore,
+		},
+		{
+			name: "Unknown field",
+			json: `{
+				"network_mode":"dhcp",
+				"host_ip":null,
+				"gateway":null,
+				"dns":null,
+				"ospkg_pointer":"http://server.com",
+				"identity":null,
+				"authentication":null,
+				"ospkg_url":"http://server.com",
+				"network_interfaces":null,
+				"bonding_mode":null,
+				"bond_name":null
+			}`,
+			want:    Config{},
+			errType: jsonutil.ErrUnknownField,
+		},
+		{
+			name: "Unsupported version",
+			json: `{
+				"version": 1,
+				"network_mode":"dhcp",
+				"host_ip":null,
+				"gateway":null,
+				"dns":null,
+				"ospkg_pointer":"http://server.com",
+				"identity":null,
+				"authentication":null,
+				"network_interfaces":null,
+				"bonding_mode":null,
+				"bond_name":null
+			}`,
+			want:    Config{},
+			errType: ErrUnsupportedVersion,
+		},
 		{
 			name: "Bad bonding field",
 			json: `{
@@ -819,6 +905,263 @@ func TestConfigUnmarshalJSON(t *testing.T) {
 	}
 }
 
+func TestConfigIPv6(t *testing.T) {
+	const common = `
+		"dns":null,
+		"ospkg_pointer":"http://server.com",
+		"identity":null,
+		"authentication":null,
+		"network_interfaces":null,
+		"bonding_mode":null,
+		"bond_name":null`
+
+	tests := []struct {
+		name    string
+		json    string
+		wantErr error
+	}{
+		{
+			name: "SLAAC",
+			json: `{"network_mode":"slaac", "host_ip":null, "gateway":null,` + common + `}`,
+		},
+		{
+			name: "DHCPv6",
+			json: `{"network_mode":"dhcpv6", "host_ip":null, "gateway":null,` + common + `}`,
+		},
+		{
+			name: "Static IPv6",
+			json: `{"network_mode":"static", "host_ip":"2001:db8::2/64", "gateway":"2001:db8::1",` + common + `}`,
+		},
+		{
+			name: "Dual-stack DHCP and SLAAC",
+			json: `{"network_mode":"dhcp", "network_mode_ipv6":"slaac", "host_ip":null, "gateway":null,` + common + `}`,
+		},
+		{
+			name: "Dual-stack static",
+			json: `{"network_mode":"static", "host_ip":"10.0.0.2/24", "gateway":"10.0.0.1",
+				"network_mode_ipv6":"static", "host_ipv6":"2001:db8::2/64", "gateway_ipv6":"2001:db8::1",` + common + `}`,
+		},
+		{
+			name:    "Static address family mismatch",
+			json:    `{"network_mode":"static", "host_ip":"2001:db8::2/64", "gateway":"10.0.0.1",` + common + `}`,
+			wantErr: ErrIPFamilyMismatch,
+		},
+		{
+			name:    "IPv6 mode alongside IPv6 network mode",
+			json:    `{"network_mode":"slaac", "network_mode_ipv6":"dhcpv6", "host_ip":null, "gateway":null,` + common + `}`,
+			wantErr: ErrInvalidIPv6Mode,
+		},
+		{
+			name:    "IPv6 mode dhcp",
+			json:    `{"network_mode":"dhcp", "network_mode_ipv6":"dhcp", "host_ip":null, "gateway":null,` + common + `}`,
+			wantErr: ErrInvalidIPv6Mode,
+		},
+		{
+			name: "Static IPv6 without address",
+			json: `{"network_mode":"dhcp", "network_mode_ipv6":"static", "gateway_ipv6":"2001:db8::1",
+				"host_ip":null, "gateway":null,` + common + `}`,
+			wantErr: ErrMissingIPv6Addr,
+		},
+		{
+			name: "Static IPv6 with IPv4 gateway",
+			json: `{"network_mode":"dhcp", "network_mode_ipv6":"static", "host_ipv6":"2001:db8::2/64",
+				"gateway_ipv6":"10.0.0.1", "host_ip":null, "gateway":null,` + common + `}`,
+			wantErr: ErrMissingIPv6Gateway,
+		},
+		{
+			name: "IPv6 address without static mode",
+			json: `{"network_mode":"dhcp", "network_mode_ipv6":"slaac", "host_ipv6":"2001:db8::2/64",
+				"host_ip":null, "gateway":null,` + common + `}`,
+			wantErr: ErrUnexpectedIPv6Static,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg Config
+
+			err := json.Unmarshal([]byte(tt.json), &cfg)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got %v, want %v", err, tt.wantErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			data, err := json.Marshal(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var again Config
+			if err := json.Unmarshal(data, &again); err != nil {
+				t.Errorf("round trip %s: %v", data, err)
+			}
+		})
+	}
+}
+
+func TestConfigStaticOptions(t *testing.T) {
+	const common = `
+		"dns":null,
+		"ospkg_pointer":"http://server.com",
+		"identity":null,
+		"authentication":null,
+		"network_interfaces":null,
+		"bonding_mode":null,
+		"bond_name":null`
+
+	const static = `"network_mode":"static", "host_ip":"192.0.2.2/24", "gateway":"192.0.2.1",`
+
+	tests := []struct {
+		name    string
+		json    string
+		wantErr error
+	}{
+		{
+			name: "Additional addresses",
+			json: `{` + static + `"additional_host_ips":["192.0.2.3/24", "2001:db8::2/64"],` + common + `}`,
+		},
+		{
+			name: "Routes",
+			json: `{` + static + `"routes":[
+				{"destination":"198.51.100.0/24", "gateway":"192.0.2.254", "metric":10},
+				{"destination":"203.0.113.0/24", "link":"eth1"}
+			],` + common + `}`,
+		},
+		{
+			name:    "Routes without static mode",
+			json:    `{"network_mode":"dhcp", "host_ip":null, "gateway":null, "routes":[{"destination":"198.51.100.0/24"}],` + common + `}`,
+			wantErr: ErrUnexpectedStaticOptions,
+		},
+		{
+			name:    "Additional addresses without static mode",
+			json:    `{"network_mode":"dhcp", "host_ip":null, "gateway":null, "additional_host_ips":["192.0.2.3/24"],` + common + `}`,
+			wantErr: ErrUnexpectedStaticOptions,
+		},
+		{
+			name:    "Route address family mismatch",
+			json:    `{` + static + `"routes":[{"destination":"2001:db8::/32", "gateway":"192.0.2.254"}],` + common + `}`,
+			wantErr: ErrInvalidRoute,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg Config
+
+			err := json.Unmarshal([]byte(tt.json), &cfg)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got %v, want %v", err, tt.wantErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			data, err := json.Marshal(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var again Config
+			if err := json.Unmarshal(data, &again); err != nil {
+				t.Fatalf("round trip %s: %v", data, err)
+			}
+
+			if !reflect.DeepEqual(again, cfg) {
+				t.Errorf("round trip: got %+v, want %+v", again, cfg)
+			}
+		})
+	}
+}
+
+func TestRouteUnmarshal(t *testing.T) {
+	invalid := []string{
+		`{}`,
+		`{"destination":"198.51.100.1"}`,
+		`{"destination":"198.51.100.0/24", "gateway":"router"}`,
+		`{"destination":"198.51.100.0/24", "via":"192.0.2.1"}`,
+	}
+
+	for _, data := range invalid {
+		var r Route
+		if err := json.Unmarshal([]byte(data), &r); err == nil {
+			t.Errorf("%s: expect an error", data)
+		}
+	}
+
+	var r Route
+	if err := json.Unmarshal([]byte(`{"destination":"198.51.100.7/24", "gateway":"192.0.2.1", "metric":5}`), &r); err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Destination.String() != "198.51.100.0/24" || !r.Gateway.Equal(net.ParseIP("192.0.2.1")) || r.Metric != 5 {
+		t.Errorf("got %+v", r)
+	}
+}
+
+func TestConfigVLANID(t *testing.T) {
+	const common = `
+		"network_mode":"dhcp",
+		"host_ip":null,
+		"gateway":null,
+		"dns":null,
+		"ospkg_pointer":"http://server.com",
+		"identity":null,
+		"authentication":null,
+		"network_interfaces":null,
+		"bonding_mode":null,
+		"bond_name":null`
+
+	tests := []struct {
+		name    string
+		json    string
+		want    *uint16
+		wantErr error
+	}{
+		{
+			name: "Unset",
+			json: `{` + common + `}`,
+		},
+		{
+			name: "Tagged",
+			json: `{"vlan_id":100,` + common + `}`,
+			want: uint16p(100),
+		},
+		{
+			name:    "Zero",
+			json:    `{"vlan_id":0,` + common + `}`,
+			wantErr: ErrInvalidVLANID,
+		},
+		{
+			name:    "Reserved",
+			json:    `{"vlan_id":4095,` + common + `}`,
+			wantErr: ErrInvalidVLANID,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg Config
+
+			err := json.Unmarshal([]byte(tt.json), &cfg)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got %v, want %v", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(cfg.VLANID, tt.want) {
+				t.Errorf("got %v, want %v", cfg.VLANID, tt.want)
+			}
+		})
+	}
+}
+
+func uint16p(v uint16) *uint16 {
+	return &v
+}
+
 func TestNetlinkAddrModeMarshal(t *testing.T) {
 	tests := []struct {
 		name string
@@ -1271,3 +1614,94 @@ func s2sNetworkInterfaces(t *testing.T, s [][2]string) *[]*NetworkInterface {
 
 	return &n
 }
+
+func TestConfigLinkSelectors(t *testing.T) {
+	const common = `
+		"network_mode":"dhcp",
+		"host_ip":null,
+		"gateway":null,
+		"dns":null,
+		"ospkg_pointer":"http://server.com",
+		"identity":null,
+		"authentication":null,
+		"bonding_mode":null,
+		"bond_name":null`
+
+	tests := []struct {
+		name    string
+		json    string
+		wantErr error
+	}{
+		{
+			name: "Selectors",
+			json: `{"network_interfaces":null, "link_selectors":[
+				{"mac_address":"00:11:22:33:44:55"},
+				{"name":"enp*", "driver":"ixgbe"},
+				{"pci_path":"0000:00:1f.6"}
+			], "link_timeout_seconds":5,` + common + `}`,
+		},
+		{
+			name:    "Together with network interfaces",
+			json:    `{"network_interfaces":[{"interface_name":"eth0", "mac_address":"00:11:22:33:44:55"}], "link_selectors":[{"name":"eth*"}],` + common + `}`,
+			wantErr: ErrLinkSelectorsConflict,
+		},
+		{
+			name:    "Empty selector",
+			json:    `{"network_interfaces":null, "link_selectors":[{}],` + common + `}`,
+			wantErr: ErrInvalidLinkSelector,
+		},
+		{
+			name:    "Bad glob",
+			json:    `{"network_interfaces":null, "link_selectors":[{"name":"eth["}],` + common + `}`,
+			wantErr: ErrInvalidLinkSelector,
+		},
+		{
+			name:    "Negative timeout",
+			json:    `{"network_interfaces":null, "link_timeout_seconds":-1,` + common + `}`,
+			wantErr: ErrInvalidLinkTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg Config
+
+			err := json.Unmarshal([]byte(tt.json), &cfg)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got %v, want %v", err, tt.wantErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			data, err := json.Marshal(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var again Config
+			if err := json.Unmarshal(data, &again); err != nil {
+				t.Fatalf("round trip %s: %v", data, err)
+			}
+
+			if !reflect.DeepEqual(again, cfg) {
+				t.Errorf("round trip: got %+v, want %+v", again, cfg)
+			}
+		})
+	}
+}
+
+func TestConfigLinkTimeout(t *testing.T) {
+	var cfg Config
+	if cfg.LinkTimeout() != DefaultLinkTimeout {
+		t.Errorf("got %v, want default %v", cfg.LinkTimeout(), DefaultLinkTimeout)
+	}
+
+	seconds := 0
+	cfg.LinkTimeoutSeconds = &seconds
+
+	if cfg.LinkTimeout() != 0 {
+		t.Errorf("got %v, want 0", cfg.LinkTimeout())
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 
 	"system-transparency.org/stboot/sterror"
 	"system-transparency.org/stboot/stlog"
@@ -18,7 +19,8 @@ const (
 
 // Errors which may be raised and wrapped in this package.
 var (
-	ErrConfigNotFound = errors.New("no host configuration found")
+	ErrConfigNotFound      = errors.New("no host configuration found")
+	ErrUnknownConfigSource = errors.New("unknown host configuration source")
 )
 
 // Sources used by ConfigAutodetect.
@@ -29,14 +31,75 @@ const (
 	HostConfigProvisionOSPKGName = "provision.zip"
 )
 
+// ConfigSource names a location probed by ConfigAutodetect.
+type ConfigSource string
+
+// Supported host configuration sources.
+const (
+	ConfigSourceInitramfs ConfigSource = "initramfs"
+	ConfigSourceEFIVar    ConfigSource = "efivar"
+	ConfigSourceCmdline   ConfigSource = "cmdline"
+	ConfigSourceSMBIOS    ConfigSource = "smbios"
+	ConfigSourceFWCfg     ConfigSource = "fw_cfg"
+	ConfigSourceLabel     ConfigSource = "label"
+)
+
+// DefaultConfigSources is the probing order used if none is given. The other
+// sources can be set by anyone with access to the console or the hardware,
+// so deployments have to opt in to them.
+var DefaultConfigSources = []ConfigSource{
+	ConfigSourceInitramfs,
+	ConfigSourceEFIVar,
+}
+
+// ParseConfigSources parses a comma separated list of ConfigSource names.
+func ParseConfigSources(list string) ([]ConfigSource, error) {
+	var sources []ConfigSource
+
+	for _, name := range strings.Split(list, ",") {
+		source := ConfigSource(strings.TrimSpace(name))
+		if _, err := source.loader(); err != nil {
+			return nil, err
+		}
+
+		sources = append(sources, source)
+	}
+
+	return sources, nil
+}
+
+func (s ConfigSource) loader() (configLoader, error) {
+	switch s {
+	case ConfigSourceInitramfs:
+		return &initramfs{}, nil
+	case ConfigSourceEFIVar:
+		return &efivar{}, nil
+	case ConfigSourceCmdline:
+		return &cmdline{path: CmdlinePath}, nil
+	case ConfigSourceSMBIOS:
+		return &smbios{sysfs: SysfsPath}, nil
+	case ConfigSourceFWCfg:
+		return &fwcfg{sysfs: SysfsPath}, nil
+	case ConfigSourceLabel:
+		return newLabel(), nil
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownConfigSource, s)
+	}
+}
+
 type configLoader interface {
 	probe() (io.Reader, error)
 	info() string
 }
 
-// ConfigAutodetect looks for a known host configuration name in following order:
+// ConfigAutodetect looks for a host configuration at the given sources in
+// order, or DefaultConfigSources if none are given:
 // - inside the initramfs at HostConfigInitrdPath
 // - at the efivar filesystem for HostConfigEFIVarName
+// - on the kernel command line, see CmdlineConfigParam
+// - in SMBIOS OEM strings, see SMBIOSEntriesDir
+// - in the QEMU fw_cfg item FWCfgConfigName
+// - on the block device labelled ConfigLabel
 //
 // If no host configuration is found, a special provisioning host config is created
 // and taken as return value. This config points to "ospkg/provision.zip"
@@ -45,13 +108,24 @@ type configLoader interface {
 // at a probed location. In case there is no match an ErrConfigNotFound is returned.
 //
 // Note: No validation is made on found configuration.
-func ConfigAutodetect() (io.Reader, error) {
-	var loadingOrder = []configLoader{
-		&initramfs{},
-		&efivar{},
-		&provision{},
+func ConfigAutodetect(sources ...ConfigSource) (io.Reader, error) {
+	if len(sources) == 0 {
+		sources = DefaultConfigSources
 	}
 
+	loadingOrder := make([]configLoader, 0, len(sources)+1)
+
+	for _, source := range sources {
+		loader, err := source.loader()
+		if err != nil {
+			return nil, sterror.E(ErrScope, ErrOpAutodetect, err)
+		}
+
+		loadingOrder = append(loadingOrder, loader)
+	}
+
+	loadingOrder = append(loadingOrder, &provision{})
+
 	stlog.Debug("Host configuration autodetect")
 
 	for _, loader := range loadingOrder {
@@ -114,14 +188,19 @@ type provision struct{}
 
 var _ configLoader = &provision{}
 
-func (p *provision) probe() (io.Reader, error) {
+// ProvisionConfig returns the host configuration used in provision mode.
+func ProvisionConfig() Config {
 	ipAddrMode := IPDynamic
 	osPkgPtr := HostConfigProvisionOSPKGName
 
-	cfg := Config{
+	return Config{
 		IPAddrMode:   &ipAddrMode,
 		OSPkgPointer: &osPkgPtr,
 	}
+}
+
+func (p *provision) probe() (io.Reader, error) {
+	cfg := ProvisionConfig()
 
 	cfgBytes, err := cfg.MarshalJSON()
 	if err != nil {
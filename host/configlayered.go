@@ -0,0 +1,193 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package host
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"system-transparency.org/stboot/internal/jsonutil"
+	"system-transparency.org/stboot/sterror"
+	"system-transparency.org/stboot/stlog"
+)
+
+// ConfigSourceProvision is the provenance of fields generated in provision
+// mode. It cannot be used as a source.
+const ConfigSourceProvision ConfigSource = "provision"
+
+// Operations used for raising Errors of this package.
+const (
+	ErrOpLayered sterror.Op = "config layered"
+)
+
+// Errors which may be raised and wrapped in this package.
+var (
+	ErrLayeredEnvelope = errors.New("no key to verify signed host configuration layer")
+)
+
+// LayeredConfig is a host configuration merged by ConfigLayered.
+type LayeredConfig struct {
+	// Config is the merged host configuration JSON.
+	Config []byte
+	// Provenance records the source of each set field.
+	Provenance ConfigProvenance
+	// Signed holds the fields set by a verified signed layer.
+	Signed map[string]bool
+}
+
+// ConfigProvenance maps the JSON keys of a host configuration to the source
+// that set them.
+type ConfigProvenance map[string]ConfigSource
+
+// String returns one "key: source" line per field, sorted by key.
+func (p ConfigProvenance) String() string {
+	keys := make([]string, 0, len(p))
+	for k := range p {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%s: %s\n", k, p[k])
+	}
+
+	return b.String()
+}
+
+// ConfigLayered reads the host configuration from all given sources, or
+// DefaultConfigSources if none are given, and merges them. Sources later in
+// the list take precedence, so earlier ones act as defaults. Layers may be
+// partial, a field that is missing or null in a layer does not override.
+//
+// Layers which are a ConfigEnvelope are verified against key before merging.
+// The returned LayeredConfig records the source of each set field, and
+// whether it is signed. If no source is found, the provision mode host
+// configuration is returned, see ConfigAutodetect.
+//
+// Note: No validation is made on the merged configuration.
+func ConfigLayered(key ed25519.PublicKey, sources ...ConfigSource) (*LayeredConfig, error) {
+	if len(sources) == 0 {
+		sources = DefaultConfigSources
+	}
+
+	loaders := make([]configLoader, 0, len(sources))
+
+	for _, source := range sources {
+		loader, err := source.loader()
+		if err != nil {
+			return nil, sterror.E(ErrScope, ErrOpLayered, err)
+		}
+
+		loaders = append(loaders, loader)
+	}
+
+	stlog.Debug("Host configuration layered")
+
+	layered, err := mergeLayers(sources, loaders, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(layered.Provenance) == 0 {
+		layered, err = mergeLayers([]ConfigSource{ConfigSourceProvision}, []configLoader{&provision{}}, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	stlog.Debug("Host configuration provenance:\n%s", layered.Provenance)
+
+	return layered, nil
+}
+
+func mergeLayers(sources []ConfigSource, loaders []configLoader, key ed25519.PublicKey) (*LayeredConfig, error) {
+	fields := make(map[string]json.RawMessage)
+	layered := &LayeredConfig{
+		Provenance: make(ConfigProvenance),
+		Signed:     make(map[string]bool),
+	}
+
+	for _, name := range jsonutil.Required(Config{}) {
+		fields[name] = json.RawMessage(jsonutil.Null)
+	}
+
+	for i, loader := range loaders {
+		stlog.Debug(loader.info())
+
+		r, err := loader.probe()
+		if err != nil {
+			stlog.Debug(err.Error())
+
+			continue
+		}
+
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, sterror.E(ErrScope, ErrOpLayered, fmt.Sprintf("%s: %v", sources[i], err))
+		}
+
+		signed := IsConfigEnvelope(data)
+		if signed {
+			if data, err = verifyLayer(data, key); err != nil {
+				return nil, sterror.E(ErrScope, ErrOpLayered, err, string(sources[i]))
+			}
+		}
+
+		var layer map[string]json.RawMessage
+		if err := json.Unmarshal(data, &layer); err != nil {
+			return nil, sterror.E(ErrScope, ErrOpLayered, fmt.Sprintf("%s: %v", sources[i], err))
+		}
+
+		for name, value := range layer {
+			if string(value) == jsonutil.Null {
+				continue
+			}
+
+			fields[name] = value
+			layered.Provenance[name] = sources[i]
+
+			if signed {
+				layered.Signed[name] = true
+			} else {
+				delete(layered.Signed, name)
+			}
+		}
+	}
+
+	merged, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	layered.Config = merged
+
+	return layered, nil
+}
+
+// verifyLayer returns the host configuration of the envelope data, if it is
+// signed by key.
+func verifyLayer(data []byte, key ed25519.PublicKey) ([]byte, error) {
+	if key == nil {
+		return nil, ErrLayeredEnvelope
+	}
+
+	env, err := ConfigEnvelopeFromBytes(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := env.Verify(key); err != nil {
+		return nil, err
+	}
+
+	return env.Config, nil
+}
@@ -0,0 +1,168 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package host
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type fakeLoader struct {
+	data string
+}
+
+func (f *fakeLoader) probe() (io.Reader, error) {
+	if f.data == "" {
+		return nil, os.ErrNotExist
+	}
+
+	return strings.NewReader(f.data), nil
+}
+
+func (f *fakeLoader) info() string {
+	return "fake"
+}
+
+func TestMergeLayers(t *testing.T) {
+	base := `{
+		"network_mode": "dhcp",
+		"host_ip": null,
+		"gateway": null,
+		"dns": ["9.9.9.9"],
+		"network_interfaces": null,
+		"ospkg_pointer": "https://example.org/$ID/os.json",
+		"identity": "default",
+		"authentication": null,
+		"bonding_mode": null,
+		"bond_name": null
+	}`
+
+	sources := []ConfigSource{ConfigSourceInitramfs, ConfigSourceEFIVar, ConfigSourceCmdline}
+	loaders := []configLoader{
+		&fakeLoader{data: base},
+		&fakeLoader{},
+		&fakeLoader{data: `{"identity": "host-a", "authentication": "secret", "dns": null}`},
+	}
+
+	layered, err := mergeLayers(sources, loaders, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(layered.Config, &cfg); err != nil {
+		t.Fatalf("invalid host config %s: %v", layered.Config, err)
+	}
+
+	if *cfg.ID != "host-a" || *cfg.Auth != "secret" || cfg.DNSServer == nil {
+		t.Errorf("unexpected merge result %s", layered.Config)
+	}
+
+	want := ConfigProvenance{
+		"network_mode":   ConfigSourceInitramfs,
+		"dns":            ConfigSourceInitramfs,
+		"ospkg_pointer":  ConfigSourceInitramfs,
+		"identity":       ConfigSourceCmdline,
+		"authentication": ConfigSourceCmdline,
+	}
+	if !reflect.DeepEqual(layered.Provenance, want) {
+		t.Errorf("got provenance\n%s\nwant\n%s", layered.Provenance, want)
+	}
+
+	if len(layered.Signed) != 0 {
+		t.Errorf("got signed fields %v, want none", layered.Signed)
+	}
+}
+
+func TestMergeLayersEnvelope(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env, err := SignConfig([]byte(`{
+		"network_mode": "dhcp",
+		"host_ip": null,
+		"gateway": null,
+		"dns": null,
+		"network_interfaces": null,
+		"ospkg_pointer": "https://example.org/os.json",
+		"identity": "signed",
+		"authentication": null,
+		"bonding_mode": null,
+		"bond_name": null
+	}`), priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed, err := json.Marshal(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env.Signature[0] ^= 0xff
+
+	tampered, err := json.Marshal(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sources := []ConfigSource{ConfigSourceEFIVar, ConfigSourceCmdline}
+	loaders := []configLoader{
+		&fakeLoader{data: string(signed)},
+		&fakeLoader{data: `{"identity": "unsigned"}`},
+	}
+
+	layered, err := mergeLayers(sources, loaders, pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]bool{"network_mode": true, "ospkg_pointer": true}
+	if !reflect.DeepEqual(layered.Signed, want) {
+		t.Errorf("got signed fields %v, want %v", layered.Signed, want)
+	}
+
+	if layered.Provenance["identity"] != ConfigSourceCmdline {
+		t.Errorf("got provenance\n%s", layered.Provenance)
+	}
+
+	if _, err := mergeLayers(sources, loaders, nil); !errors.Is(err, ErrLayeredEnvelope) {
+		t.Errorf("no key: got %v, want %v", err, ErrLayeredEnvelope)
+	}
+
+	_, err = mergeLayers(sources[:1], []configLoader{&fakeLoader{data: string(tampered)}}, pub)
+	if !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("tampered: got %v, want %v", err, ErrInvalidSignature)
+	}
+}
+
+func TestMergeLayersProvision(t *testing.T) {
+	layered, err := mergeLayers([]ConfigSource{ConfigSourceProvision}, []configLoader{&provision{}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(layered.Config, &cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(cfg, ProvisionConfig()) {
+		t.Errorf("got %s", layered.Config)
+	}
+
+	if layered.Provenance["ospkg_pointer"] != ConfigSourceProvision {
+		t.Errorf("got provenance\n%s", layered.Provenance)
+	}
+}
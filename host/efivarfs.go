@@ -1,12 +1,30 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
 package host
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
 
 	"github.com/u-root/u-root/pkg/efivarfs"
 	"system-transparency.org/stboot/sterror"
 )
 
+// HostConfigEFIVarAttrs are the attributes of the host configuration EFI
+// variable. It is non-volatile and readable at boot and run time.
+const HostConfigEFIVarAttrs = efivarfs.AttributeNonVolatile |
+	efivarfs.AttributeBootserviceAccess |
+	efivarfs.AttributeRuntimeAccess
+
+// Errors which may be raised and wrapped in this package.
+var (
+	ErrEFIVarNotExist = errors.New("host configuration EFI variable does not exist")
+)
+
 func readEFIVar(name string) (*bytes.Reader, error) {
 	const operation = sterror.Op("read EFI var")
 
@@ -22,3 +40,73 @@ func readEFIVar(name string) (*bytes.Reader, error) {
 
 	return r, nil
 }
+
+// ReadConfigEFIVar returns the contents of the host configuration EFI variable
+// HostConfigEFIVarName and its attributes.
+func ReadConfigEFIVar(vars efivarfs.EFIVar) ([]byte, efivarfs.VariableAttributes, error) {
+	const operation = sterror.Op("read host config EFI var")
+
+	attrs, r, err := efivarfs.SimpleReadVariable(vars, HostConfigEFIVarName)
+	if errors.Is(err, efivarfs.ErrVarNotExist) {
+		return nil, 0, sterror.E(ErrScope, operation, ErrEFIVarNotExist)
+	} else if err != nil {
+		return nil, 0, sterror.E(ErrScope, operation, err.Error())
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, 0, sterror.E(ErrScope, operation, err.Error())
+	}
+
+	return data, attrs, nil
+}
+
+// WriteConfigEFIVar validates the host configuration data and writes it to the
+// host configuration EFI variable HostConfigEFIVarName with
+// HostConfigEFIVarAttrs. data is either a host configuration or a
+// ConfigEnvelope, whose signature is not verified.
+func WriteConfigEFIVar(vars efivarfs.EFIVar, data []byte) error {
+	const operation = sterror.Op("write host config EFI var")
+
+	if err := ValidateConfigBytes(data); err != nil {
+		return sterror.E(ErrScope, operation, ErrInvalidConfig, err.Error())
+	}
+
+	if err := efivarfs.SimpleWriteVariable(vars, HostConfigEFIVarName, HostConfigEFIVarAttrs, bytes.NewBuffer(data)); err != nil {
+		return sterror.E(ErrScope, operation, err.Error())
+	}
+
+	return nil
+}
+
+// RemoveConfigEFIVar deletes the host configuration EFI variable
+// HostConfigEFIVarName.
+func RemoveConfigEFIVar(vars efivarfs.EFIVar) error {
+	const operation = sterror.Op("remove host config EFI var")
+
+	err := efivarfs.SimpleRemoveVariable(vars, HostConfigEFIVarName)
+	if errors.Is(err, efivarfs.ErrVarNotExist) {
+		return sterror.E(ErrScope, operation, ErrEFIVarNotExist)
+	} else if err != nil {
+		return sterror.E(ErrScope, operation, err.Error())
+	}
+
+	return nil
+}
+
+// ValidateConfigBytes returns an error if data is neither a valid host
+// configuration nor a ConfigEnvelope holding one.
+func ValidateConfigBytes(data []byte) error {
+	if IsConfigEnvelope(data) {
+		env, err := ConfigEnvelopeFromBytes(data)
+		if err != nil {
+			return err
+		}
+
+		data = env.Config
+	}
+
+	var cfg Config
+
+	return json.Unmarshal(data, &cfg)
+}
@@ -0,0 +1,62 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package host
+
+import (
+	"errors"
+	"testing"
+
+	"system-transparency.org/stboot/internal/efivartest"
+)
+
+func TestConfigEFIVar(t *testing.T) {
+	vars := efivartest.Dir(t.TempDir())
+
+	if _, _, err := ReadConfigEFIVar(vars); !errors.Is(err, ErrEFIVarNotExist) {
+		t.Fatalf("read missing variable: got %v, want %v", err, ErrEFIVarNotExist)
+	}
+
+	cfg := []byte(`{
+		"network_mode": "dhcp",
+		"host_ip": null,
+		"gateway": null,
+		"dns": null,
+		"network_interfaces": null,
+		"ospkg_pointer": "https://example.org/os.json",
+		"identity": null,
+		"authentication": null,
+		"bonding_mode": null,
+		"bond_name": null
+	}`)
+
+	if err := WriteConfigEFIVar(vars, []byte(`{"network_mode": "dhcp"}`)); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("write invalid config: got %v, want %v", err, ErrInvalidConfig)
+	}
+
+	if err := WriteConfigEFIVar(vars, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	got, attrs, err := ReadConfigEFIVar(vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(got) != string(cfg) {
+		t.Errorf("got %s, want %s", got, cfg)
+	}
+
+	if attrs != HostConfigEFIVarAttrs {
+		t.Errorf("got attributes %#x, want %#x", attrs, HostConfigEFIVarAttrs)
+	}
+
+	if err := RemoveConfigEFIVar(vars); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RemoveConfigEFIVar(vars); !errors.Is(err, ErrEFIVarNotExist) {
+		t.Errorf("remove missing variable: got %v, want %v", err, ErrEFIVarNotExist)
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package host
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"system-transparency.org/stboot/internal/jsonutil"
+)
+
+// Errors which may be raised and wrapped in this package.
+var (
+	ErrNotEnvelope      = errors.New("not a host configuration envelope")
+	ErrEnvelopeKey      = errors.New("host configuration signing key must be Ed25519")
+	ErrInvalidSignature = errors.New("invalid host configuration signature")
+)
+
+// ConfigEnvelope is a signed host configuration. Config holds the host
+// configuration JSON as is and Signature is an Ed25519 signature over it,
+// so no canonicalization is needed. Both are base64 encoded in JSON.
+type ConfigEnvelope struct {
+	Config    []byte `json:"host_config"`
+	Signature []byte `json:"signature"`
+}
+
+// IsConfigEnvelope returns true if data looks like a ConfigEnvelope rather
+// than a plain host configuration.
+func IsConfigEnvelope(data []byte) bool {
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return false
+	}
+
+	_, hasConfig := keys["host_config"]
+	_, hasSignature := keys["signature"]
+
+	return hasConfig && hasSignature
+}
+
+// ConfigEnvelopeFromBytes parses a ConfigEnvelope. The signature is not
+// verified.
+func ConfigEnvelopeFromBytes(data []byte) (*ConfigEnvelope, error) {
+	var env ConfigEnvelope
+	if err := jsonutil.UnmarshalStrict(data, &env); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotEnvelope, err)
+	}
+
+	if len(env.Config) == 0 || len(env.Signature) == 0 {
+		return nil, ErrNotEnvelope
+	}
+
+	return &env, nil
+}
+
+// SignConfig wraps the host configuration JSON config into a ConfigEnvelope
+// signed by key, which must be an Ed25519 key. The key material does not
+// need to be accessible.
+func SignConfig(config []byte, key crypto.Signer) (*ConfigEnvelope, error) {
+	if _, ok := key.Public().(ed25519.PublicKey); !ok {
+		return nil, ErrEnvelopeKey
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(config, &cfg); err != nil {
+		return nil, err
+	}
+
+	sig, err := key.Sign(nil, config, crypto.Hash(0))
+	if err != nil {
+		return nil, err
+	}
+
+	return &ConfigEnvelope{Config: config, Signature: sig}, nil
+}
+
+// Verify checks the signature of e against key.
+func (e *ConfigEnvelope) Verify(key ed25519.PublicKey) error {
+	if len(key) != ed25519.PublicKeySize {
+		return ErrEnvelopeKey
+	}
+
+	if !ed25519.Verify(key, e.Config, e.Signature) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
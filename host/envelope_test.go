@@ -0,0 +1,78 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package host
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestConfigEnvelope(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := []byte(`{
+		"network_mode": "dhcp",
+		"host_ip": null,
+		"gateway": null,
+		"dns": null,
+		"network_interfaces": null,
+		"ospkg_pointer": "https://example.org/ospkg.json",
+		"identity": null,
+		"authentication": null,
+		"bonding_mode": null,
+		"bond_name": null
+	}`)
+
+	env, err := SignConfig(cfg, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !IsConfigEnvelope(data) {
+		t.Fatal("envelope not detected")
+	}
+
+	if IsConfigEnvelope(cfg) {
+		t.Error("plain host configuration detected as envelope")
+	}
+
+	got, err := ConfigEnvelopeFromBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := got.Verify(pub); err != nil {
+		t.Errorf("verify: %v", err)
+	}
+
+	if err := got.Verify(otherPub); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("verify with other key: got %v, want %v", err, ErrInvalidSignature)
+	}
+
+	got.Config = []byte(`{"ospkg_pointer": "https://evil.example.org/ospkg.json"}`)
+	if err := got.Verify(pub); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("verify tampered config: got %v, want %v", err, ErrInvalidSignature)
+	}
+
+	if _, err := ConfigEnvelopeFromBytes([]byte(`{"host_config": "", "signature": ""}`)); !errors.Is(err, ErrNotEnvelope) {
+		t.Errorf("empty envelope: got %v, want %v", err, ErrNotEnvelope)
+	}
+}
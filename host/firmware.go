@@ -0,0 +1,138 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package host
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"system-transparency.org/stboot/sterror"
+)
+
+// Firmware host configuration sources, relative to the sysfs mount point.
+//
+// SMBIOS type 11 OEM strings are read like kernel command line parameters,
+// see CmdlineConfigParam. With QEMU they are set by
+// -smbios type=11,value=stboot.host_config=BASE64.
+//
+// The QEMU fw_cfg item FWCfgConfigName holds the host configuration JSON as
+// is. It is set by -fw_cfg name=opt/org.system-transparency/host_config,file=FILE.
+const (
+	SysfsPath        = "/sys"
+	SMBIOSEntriesDir = "firmware/dmi/entries"
+	FWCfgByNameDir   = "firmware/qemu_fw_cfg/by_name"
+	FWCfgConfigName  = "opt/org.system-transparency/host_config"
+
+	smbiosOEMStrings = 11
+)
+
+// Errors which may be raised and wrapped in this package.
+var (
+	ErrInvalidSMBIOSEntry = errors.New("invalid SMBIOS entry")
+)
+
+type smbios struct {
+	sysfs string
+}
+
+var _ configLoader = &smbios{}
+
+func (s *smbios) probe() (io.Reader, error) {
+	const operation = sterror.Op("read SMBIOS OEM strings")
+
+	pattern := filepath.Join(s.sysfs, SMBIOSEntriesDir, fmt.Sprintf("%d-*", smbiosOEMStrings), "raw")
+
+	entries, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, sterror.E(ErrScope, operation, err.Error())
+	}
+
+	sort.Strings(entries)
+
+	var params []string
+
+	for _, entry := range entries {
+		raw, err := os.ReadFile(entry)
+		if err != nil {
+			return nil, sterror.E(ErrScope, operation, err.Error())
+		}
+
+		strs, err := smbiosStrings(raw)
+		if err != nil {
+			return nil, sterror.E(ErrScope, operation, err, entry)
+		}
+
+		params = append(params, strs...)
+	}
+
+	cfg, err := configFromParams(params)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewReader(cfg), nil
+}
+
+func (s *smbios) info() string {
+	return fmt.Sprintf("Probing SMBIOS OEM strings at %s", filepath.Join(s.sysfs, SMBIOSEntriesDir))
+}
+
+// smbiosStrings returns the strings of the raw SMBIOS structure raw. The
+// strings follow the formatted area, whose length is stored in the header,
+// and are terminated by an empty string.
+func smbiosStrings(raw []byte) ([]string, error) {
+	const (
+		headerLen = 4
+		lengthOff = 1
+	)
+
+	if len(raw) < headerLen {
+		return nil, fmt.Errorf("%w: short header", ErrInvalidSMBIOSEntry)
+	}
+
+	formatted := int(raw[lengthOff])
+	if formatted < headerLen || formatted > len(raw) {
+		return nil, fmt.Errorf("%w: bad length %d", ErrInvalidSMBIOSEntry, formatted)
+	}
+
+	var strs []string
+
+	for _, str := range strings.Split(string(raw[formatted:]), "\x00") {
+		if str == "" {
+			break
+		}
+
+		strs = append(strs, str)
+	}
+
+	return strs, nil
+}
+
+type fwcfg struct {
+	sysfs string
+}
+
+var _ configLoader = &fwcfg{}
+
+func (f *fwcfg) probe() (io.Reader, error) {
+	const operation = sterror.Op("read fw_cfg")
+
+	data, err := os.ReadFile(filepath.Join(f.sysfs, FWCfgByNameDir, FWCfgConfigName, "raw"))
+	if err != nil {
+		return nil, sterror.E(ErrScope, operation, err.Error())
+	}
+
+	return bytes.NewReader(data), nil
+}
+
+func (f *fwcfg) info() string {
+	return fmt.Sprintf("Probing QEMU fw_cfg item %s", FWCfgConfigName)
+}
@@ -0,0 +1,79 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package host
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestFirmwareLoaders(t *testing.T) {
+	tests := []struct {
+		name   string
+		loader configLoader
+		want   string
+	}{
+		{
+			name:   "SMBIOS OEM strings",
+			loader: &smbios{sysfs: "testdata/sysfs"},
+			want:   "https://example.org/os.json",
+		},
+		{
+			name:   "QEMU fw_cfg",
+			loader: &fwcfg{sysfs: "testdata/sysfs"},
+			want:   "https://example.org/fw_cfg.json",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := tt.loader.probe()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var cfg Config
+			if err := json.Unmarshal(data, &cfg); err != nil {
+				t.Fatalf("invalid host config %s: %v", data, err)
+			}
+
+			if *cfg.OSPkgPointer != tt.want {
+				t.Errorf("got OS package pointer %q, want %q", *cfg.OSPkgPointer, tt.want)
+			}
+		})
+	}
+}
+
+func TestFirmwareLoadersMissing(t *testing.T) {
+	for _, loader := range []configLoader{&smbios{sysfs: t.TempDir()}, &fwcfg{sysfs: t.TempDir()}} {
+		if _, err := loader.probe(); err == nil {
+			t.Errorf("%s: expect an error", loader.info())
+		}
+	}
+}
+
+func TestSMBIOSStrings(t *testing.T) {
+	got, err := smbiosStrings([]byte("\x0b\x05\x00\x00\x02a=b\x00c\x00\x00"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 2 || got[0] != "a=b" || got[1] != "c" {
+		t.Errorf("got %q", got)
+	}
+
+	for _, raw := range []string{"\x0b", "\x0b\x20\x00\x00"} {
+		if _, err := smbiosStrings([]byte(raw)); !errors.Is(err, ErrInvalidSMBIOSEntry) {
+			t.Errorf("%q: got %v, want %v", raw, err, ErrInvalidSMBIOSEntry)
+		}
+	}
+}
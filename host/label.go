@@ -0,0 +1,365 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package host
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/u-root/u-root/pkg/mount"
+	"golang.org/x/sys/unix"
+	"system-transparency.org/stboot/sterror"
+	"system-transparency.org/stboot/stlog"
+)
+
+// The label host configuration source looks for a block device holding a
+// filesystem labelled ConfigLabel and reads ConfigLabelFile from it. Supported
+// filesystems are ext2/3/4, vfat and iso9660. Exactly one such device must be
+// present.
+const (
+	ConfigLabel     = "STBOOT-CFG"
+	ConfigLabelFile = "host_configuration.json"
+
+	labelMountPoint = "/tmp/stboot-cfg"
+	labelTimeout    = 10 * time.Second
+)
+
+// Operations used for raising Errors of this package.
+const (
+	ErrOpLabel sterror.Op = "probe label"
+)
+
+// Errors which may be raised and wrapped in this package.
+var (
+	ErrLabelNotFound  = errors.New("no filesystem with host configuration label found")
+	ErrLabelAmbiguous = errors.New("multiple filesystems with host configuration label found")
+	ErrTimeout        = errors.New("timeout")
+	ErrMountBusy      = errors.New("mount point busy")
+)
+
+// labelBusy is held while the mount point is in use, including by mounts and
+// reads which outlived their timeout.
+//
+//nolint:gochecknoglobals
+var labelBusy = make(chan struct{}, 1)
+
+type label struct {
+	sysfs   string
+	dev     string
+	dir     string
+	label   string
+	timeout time.Duration
+	busy    chan struct{}
+	mount   func(device, fstype, dir string) error
+	unmount func(dir string) error
+}
+
+var _ configLoader = &label{}
+
+func newLabel() *label {
+	return &label{
+		sysfs:   SysfsPath,
+		dev:     "/dev",
+		dir:     labelMountPoint,
+		label:   ConfigLabel,
+		timeout: labelTimeout,
+		busy:    labelBusy,
+		mount: func(device, fstype, dir string) error {
+			const flags = unix.MS_RDONLY | unix.MS_NOATIME | unix.MS_NOSUID | unix.MS_NODEV | unix.MS_NOEXEC
+
+			_, err := mount.Mount(device, dir, fstype, "", flags)
+
+			return err
+		},
+		unmount: func(dir string) error {
+			return mount.Unmount(dir, false, false)
+		},
+	}
+}
+
+func (l *label) probe() (io.Reader, error) {
+	device, fstype, err := l.find()
+	if err != nil {
+		return nil, err
+	}
+
+	stlog.Info("Using host configuration from %s (%s, label %s)", device, fstype, l.label)
+
+	if err := os.MkdirAll(l.dir, os.ModePerm); err != nil {
+		return nil, sterror.E(ErrScope, ErrOpLabel, err.Error())
+	}
+
+	select {
+	case l.busy <- struct{}{}:
+	case <-time.After(l.timeout):
+		return nil, sterror.E(ErrScope, ErrOpLabel, ErrMount, fmt.Sprintf("%s: %v", l.dir, ErrMountBusy))
+	}
+
+	// A mount which succeeds after the timeout is undone, so the mount point
+	// is free for the next probe.
+	err = withTimeout(l.timeout, func() error { return l.mount(device, fstype, l.dir) }, func(err error) {
+		if err != nil {
+			<-l.busy
+
+			return
+		}
+
+		l.release(device)
+	})
+	if err != nil {
+		if !errors.Is(err, ErrTimeout) {
+			<-l.busy
+		}
+
+		return nil, sterror.E(ErrScope, ErrOpLabel, ErrMount, fmt.Sprintf("%s: %v", device, err))
+	}
+
+	var data []byte
+
+	err = withTimeout(l.timeout, func() error {
+		var err error
+
+		data, err = os.ReadFile(filepath.Join(l.dir, ConfigLabelFile))
+
+		return err
+	}, func(error) { l.release(device) })
+	if errors.Is(err, ErrTimeout) {
+		return nil, sterror.E(ErrScope, ErrOpLabel, fmt.Sprintf("%s: %v", device, err))
+	}
+
+	l.release(device)
+
+	if err != nil {
+		return nil, sterror.E(ErrScope, ErrOpLabel, fmt.Sprintf("%s: %v", device, err))
+	}
+
+	return bytes.NewReader(data), nil
+}
+
+// release unmounts device from the mount point and frees it.
+func (l *label) release(device string) {
+	if err := l.unmount(l.dir); err != nil {
+		stlog.Warn("unmount %s: %v", device, err)
+	}
+
+	<-l.busy
+}
+
+func (l *label) info() string {
+	return fmt.Sprintf("Probing block devices for filesystem label %s", l.label)
+}
+
+// find returns the device and filesystem type of the only block device
+// labelled l.label.
+//
+//nolint:nonamedreturns
+func (l *label) find() (device, fstype string, err error) {
+	names, err := os.ReadDir(filepath.Join(l.sysfs, "class/block"))
+	if err != nil {
+		return "", "", sterror.E(ErrScope, ErrOpLabel, err.Error())
+	}
+
+	var matches []string
+
+	for _, name := range names {
+		dev := filepath.Join(l.dev, name.Name())
+
+		var lbl, typ string
+
+		err := withTimeout(l.timeout, func() error {
+			var err error
+
+			lbl, typ, err = readLabel(dev)
+
+			return err
+		}, nil)
+		if err != nil {
+			stlog.Debug("%s: %v", dev, err)
+
+			continue
+		}
+
+		if lbl == l.label {
+			device, fstype = dev, typ
+			matches = append(matches, dev)
+		}
+	}
+
+	sort.Strings(matches)
+
+	switch len(matches) {
+	case 0:
+		return "", "", sterror.E(ErrScope, ErrOpLabel, ErrLabelNotFound, l.label)
+	case 1:
+		return device, fstype, nil
+	default:
+		return "", "", sterror.E(ErrScope, ErrOpLabel, ErrLabelAmbiguous, strings.Join(matches, ", "))
+	}
+}
+
+// readLabel returns the filesystem label and type of the block device dev.
+//
+//nolint:nonamedreturns
+func readLabel(dev string) (label, fstype string, err error) {
+	const superblocksSize = 0x8800
+
+	f, err := os.Open(dev)
+	if err != nil {
+		return "", "", err
+	}
+	defer f.Close()
+
+	buf := make([]byte, superblocksSize)
+
+	n, err := io.ReadFull(f, buf)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return "", "", err
+	}
+
+	return parseLabel(buf[:n])
+}
+
+// parseLabel detects the filesystem type and label from the start of a block
+// device.
+//
+//nolint:nonamedreturns
+func parseLabel(buf []byte) (label, fstype string, err error) {
+	const (
+		extSuperblock = 1024
+		extMagicOff   = extSuperblock + 0x38
+		extLabelOff   = extSuperblock + 0x78
+		extMagic      = 0xef53
+		extCompatOff  = extSuperblock + 0x5c
+		extFeatureLen = 12
+
+		isoDescriptor = 0x8000
+		isoIDOff      = isoDescriptor + 1
+		isoLabelOff   = isoDescriptor + 40
+
+		fat16LabelOff = 0x2b
+		fat16TypeOff  = 0x36
+		fat32LabelOff = 0x47
+		fat32TypeOff  = 0x52
+		bootSigOff    = 0x1fe
+	)
+
+	field := func(off, size int) (string, bool) {
+		if len(buf) < off+size {
+			return "", false
+		}
+
+		return string(buf[off : off+size]), true
+	}
+
+	trim := func(s string) string {
+		return strings.TrimRight(s, "\x00 ")
+	}
+
+	if len(buf) >= extMagicOff+2 && binary.LittleEndian.Uint16(buf[extMagicOff:]) == extMagic {
+		lbl, ok := field(extLabelOff, 16)
+		if ok {
+			return trim(lbl), extType(buf[extCompatOff : extCompatOff+extFeatureLen]), nil
+		}
+	}
+
+	if id, ok := field(isoIDOff, 5); ok && id == "CD001" {
+		lbl, _ := field(isoLabelOff, 32)
+
+		return trim(lbl), "iso9660", nil
+	}
+
+	if sig, ok := field(bootSigOff, 2); ok && sig == "\x55\xaa" {
+		if typ, _ := field(fat32TypeOff, 5); typ == "FAT32" {
+			lbl, _ := field(fat32LabelOff, 11)
+
+			return trim(lbl), "vfat", nil
+		}
+
+		if typ, _ := field(fat16TypeOff, 3); typ == "FAT" {
+			lbl, _ := field(fat16LabelOff, 11)
+
+			return trim(lbl), "vfat", nil
+		}
+	}
+
+	return "", "", errors.New("unknown filesystem")
+}
+
+// extType tells ext2, ext3 and ext4 apart by the compatible, incompatible
+// and read-only compatible feature flags of the superblock, like blkid does.
+func extType(features []byte) string {
+	const (
+		compatHasJournal = 0x4
+
+		// Features ext3 drivers support, any other one needs ext4.
+		ext3Incompat = 0x2 | 0x4 | 0x10
+		ext3ROCompat = 0x1 | 0x2 | 0x4
+	)
+
+	compat := binary.LittleEndian.Uint32(features[0:])
+	incompat := binary.LittleEndian.Uint32(features[4:])
+	roCompat := binary.LittleEndian.Uint32(features[8:])
+
+	switch {
+	case incompat&^ext3Incompat != 0 || roCompat&^ext3ROCompat != 0:
+		return "ext4"
+	case compat&compatHasJournal != 0:
+		return "ext3"
+	default:
+		return "ext2"
+	}
+}
+
+// withTimeout runs f and returns ErrTimeout if it does not return within d.
+// f keeps running in the background in that case, and late is called with
+// its result once it returns. late may be nil.
+func withTimeout(d time.Duration, f func() error, late func(error)) error {
+	var (
+		mu       sync.Mutex
+		timedOut bool
+	)
+
+	done := make(chan error, 1)
+
+	go func() {
+		err := f()
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if !timedOut {
+			done <- err
+		} else if late != nil {
+			late(err)
+		}
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(d):
+		mu.Lock()
+		defer mu.Unlock()
+
+		// f may have returned while the timer fired.
+		select {
+		case err := <-done:
+			return err
+		default:
+			timedOut = true
+
+			return ErrTimeout
+		}
+	}
+}
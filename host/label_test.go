@@ -0,0 +1,261 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package host
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func extImage(label string) []byte {
+	return extFeaturesImage(label, 0, 0x2|0x40, 0)
+}
+
+func extFeaturesImage(label string, compat, incompat, roCompat uint32) []byte {
+	img := make([]byte, 4096)
+	binary.LittleEndian.PutUint16(img[1024+0x38:], 0xef53)
+	binary.LittleEndian.PutUint32(img[1024+0x5c:], compat)
+	binary.LittleEndian.PutUint32(img[1024+0x60:], incompat)
+	binary.LittleEndian.PutUint32(img[1024+0x64:], roCompat)
+	copy(img[1024+0x78:], label)
+
+	return img
+}
+
+func fat32Image(label string) []byte {
+	img := make([]byte, 512)
+	copy(img[0x47:], label+"           "[len(label):])
+	copy(img[0x52:], "FAT32   ")
+	copy(img[0x1fe:], "\x55\xaa")
+
+	return img
+}
+
+func isoImage(label string) []byte {
+	img := make([]byte, 0x8800)
+	copy(img[0x8001:], "CD001")
+	copy(img[0x8000+40:], label)
+
+	return img
+}
+
+func TestParseLabel(t *testing.T) {
+	tests := []struct {
+		name      string
+		img       []byte
+		wantLabel string
+		wantType  string
+	}{
+		{"ext2", extFeaturesImage("STBOOT-CFG", 0, 0x2, 0x1), "STBOOT-CFG", "ext2"},
+		{"ext3", extFeaturesImage("STBOOT-CFG", 0x4, 0x2, 0x1), "STBOOT-CFG", "ext3"},
+		{"ext4", extImage("STBOOT-CFG"), "STBOOT-CFG", "ext4"},
+		{"ext4 read-only features", extFeaturesImage("STBOOT-CFG", 0x4, 0x2, 0x8), "STBOOT-CFG", "ext4"},
+		{"vfat", fat32Image("STBOOT-CFG"), "STBOOT-CFG", "vfat"},
+		{"iso9660", isoImage("STBOOT-CFG"), "STBOOT-CFG", "iso9660"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			label, fstype, err := parseLabel(tt.img)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if label != tt.wantLabel || fstype != tt.wantType {
+				t.Errorf("got %q (%s), want %q (%s)", label, fstype, tt.wantLabel, tt.wantType)
+			}
+		})
+	}
+
+	if _, _, err := parseLabel(make([]byte, 4096)); err == nil {
+		t.Error("expect an error for unknown filesystem")
+	}
+}
+
+// fakeBlockDevices creates a sysfs and dev tree with the given images.
+//
+//nolint:nonamedreturns
+func fakeBlockDevices(t *testing.T, images map[string][]byte) (sysfs, dev string) {
+	t.Helper()
+
+	sysfs = t.TempDir()
+	dev = t.TempDir()
+
+	for name, img := range images {
+		if err := os.MkdirAll(filepath.Join(sysfs, "class/block", name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(filepath.Join(dev, name), img, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return sysfs, dev
+}
+
+func TestLabelProbe(t *testing.T) {
+	sysfs, dev := fakeBlockDevices(t, map[string][]byte{
+		"sda":  make([]byte, 4096),
+		"sda1": extImage("rootfs"),
+		"sdb1": fat32Image(ConfigLabel),
+	})
+
+	var mounted string
+
+	l := &label{
+		sysfs:   sysfs,
+		dev:     dev,
+		dir:     t.TempDir(),
+		label:   ConfigLabel,
+		timeout: time.Second,
+		busy:    make(chan struct{}, 1),
+		mount: func(device, fstype, dir string) error {
+			mounted = device
+
+			return os.WriteFile(filepath.Join(dir, ConfigLabelFile), []byte("{}"), 0o600)
+		},
+		unmount: func(dir string) error {
+			return os.Remove(filepath.Join(dir, ConfigLabelFile))
+		},
+	}
+
+	r, err := l.probe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != "{}" {
+		t.Errorf("got %q, want {}", data)
+	}
+
+	if want := filepath.Join(dev, "sdb1"); mounted != want {
+		t.Errorf("mounted %q, want %q", mounted, want)
+	}
+
+	if _, err := os.Stat(filepath.Join(l.dir, ConfigLabelFile)); !errors.Is(err, os.ErrNotExist) {
+		t.Error("not unmounted")
+	}
+}
+
+func TestLabelFind(t *testing.T) {
+	tests := []struct {
+		name    string
+		images  map[string][]byte
+		wantErr error
+	}{
+		{
+			name:    "Not found",
+			images:  map[string][]byte{"sda1": extImage("rootfs")},
+			wantErr: ErrLabelNotFound,
+		},
+		{
+			name: "Ambiguous",
+			images: map[string][]byte{
+				"sda1": extImage(ConfigLabel),
+				"sdb1": fat32Image(ConfigLabel),
+			},
+			wantErr: ErrLabelAmbiguous,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sysfs, dev := fakeBlockDevices(t, tt.images)
+			l := &label{sysfs: sysfs, dev: dev, label: ConfigLabel, timeout: time.Second}
+
+			if _, _, err := l.find(); !errors.Is(err, tt.wantErr) {
+				t.Errorf("got %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLabelProbeLateMount(t *testing.T) {
+	sysfs, dev := fakeBlockDevices(t, map[string][]byte{
+		"sdb1": fat32Image(ConfigLabel),
+	})
+
+	unmounted := make(chan struct{})
+
+	l := &label{
+		sysfs:   sysfs,
+		dev:     dev,
+		dir:     t.TempDir(),
+		label:   ConfigLabel,
+		timeout: 10 * time.Millisecond,
+		busy:    make(chan struct{}, 1),
+		mount: func(device, fstype, dir string) error {
+			time.Sleep(50 * time.Millisecond)
+
+			return nil
+		},
+		unmount: func(dir string) error {
+			close(unmounted)
+
+			return nil
+		},
+	}
+
+	if _, err := l.probe(); !errors.Is(err, ErrMount) {
+		t.Fatalf("got %v, want %v", err, ErrMount)
+	}
+
+	if _, err := l.probe(); !errors.Is(err, ErrMount) {
+		t.Errorf("got %v, want %v while the mount point is busy", err, ErrMount)
+	}
+
+	select {
+	case <-unmounted:
+	case <-time.After(time.Second):
+		t.Fatal("late mount not unmounted")
+	}
+
+	select {
+	case l.busy <- struct{}{}:
+	case <-time.After(time.Second):
+		t.Error("mount point not freed")
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	late := make(chan error, 1)
+	errLate := errors.New("late")
+
+	err := withTimeout(10*time.Millisecond, func() error {
+		time.Sleep(100 * time.Millisecond)
+
+		return errLate
+	}, func(err error) { late <- err })
+	if !errors.Is(err, ErrTimeout) {
+		t.Errorf("got %v, want %v", err, ErrTimeout)
+	}
+
+	select {
+	case err := <-late:
+		if !errors.Is(err, errLate) {
+			t.Errorf("late got %v, want %v", err, errLate)
+		}
+	case <-time.After(time.Second):
+		t.Error("late not called")
+	}
+
+	err = withTimeout(time.Second, func() error { return errLate }, func(error) {
+		t.Error("late called without timeout")
+	})
+	if !errors.Is(err, errLate) {
+		t.Errorf("got %v, want %v", err, errLate)
+	}
+}
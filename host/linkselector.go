@@ -0,0 +1,135 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package host
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"path"
+	"time"
+
+	"system-transparency.org/stboot/internal/jsonutil"
+)
+
+// DefaultLinkTimeout is used if Config.LinkTimeoutSeconds is not set.
+const DefaultLinkTimeout = 30 * time.Second
+
+// LinkTimeout returns how long to wait for network interfaces to get carrier.
+func (c *Config) LinkTimeout() time.Duration {
+	if c.LinkTimeoutSeconds == nil {
+		return DefaultLinkTimeout
+	}
+
+	return time.Duration(*c.LinkTimeoutSeconds) * time.Second
+}
+
+// LinkSelector matches network interfaces by the fields that are set.
+//
+// Name is a glob as understood by path.Match, e.g. "enp*". Driver is the
+// kernel driver, e.g. "e1000e". PCIPath is the PCI address of the device,
+// e.g. "0000:00:1f.6".
+type LinkSelector struct {
+	MACAddress net.HardwareAddr
+	Name       string
+	Driver     string
+	PCIPath    string
+}
+
+type linkSelector struct {
+	MACAddress *netHardwareAddr `json:"mac_address,omitempty"`
+	Name       string           `json:"name,omitempty"`
+	Driver     string           `json:"driver,omitempty"`
+	PCIPath    string           `json:"pci_path,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (s LinkSelector) MarshalJSON() ([]byte, error) {
+	aux := linkSelector{
+		Name:    s.Name,
+		Driver:  s.Driver,
+		PCIPath: s.PCIPath,
+	}
+
+	if s.MACAddress != nil {
+		aux.MACAddress = (*netHardwareAddr)(&s.MACAddress)
+	}
+
+	return json.Marshal(aux)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (s *LinkSelector) UnmarshalJSON(data []byte) error {
+	var aux linkSelector
+	if err := jsonutil.UnmarshalStrict(data, &aux); err != nil {
+		return err
+	}
+
+	*s = LinkSelector{
+		Name:    aux.Name,
+		Driver:  aux.Driver,
+		PCIPath: aux.PCIPath,
+	}
+
+	if aux.MACAddress != nil {
+		s.MACAddress = net.HardwareAddr(*aux.MACAddress)
+	}
+
+	return nil
+}
+
+// String returns a human readable description of s.
+func (s LinkSelector) String() string {
+	var desc string
+
+	for _, f := range []struct{ name, value string }{
+		{"mac", s.MACAddress.String()},
+		{"name", s.Name},
+		{"driver", s.Driver},
+		{"pci", s.PCIPath},
+	} {
+		if f.value == "" {
+			continue
+		}
+
+		if desc != "" {
+			desc += " "
+		}
+
+		desc += f.name + "=" + f.value
+	}
+
+	return desc
+}
+
+func checkLinkSelection(cfg *Config) error {
+	if cfg.LinkTimeoutSeconds != nil && *cfg.LinkTimeoutSeconds < 0 {
+		return fmt.Errorf("%w: %d", ErrInvalidLinkTimeout, *cfg.LinkTimeoutSeconds)
+	}
+
+	if cfg.LinkSelectors == nil {
+		return nil
+	}
+
+	if cfg.NetworkInterfaces != nil {
+		return ErrLinkSelectorsConflict
+	}
+
+	if len(*cfg.LinkSelectors) == 0 {
+		return fmt.Errorf("%w: empty list", ErrInvalidLinkSelector)
+	}
+
+	for _, s := range *cfg.LinkSelectors {
+		if s == nil || s.String() == "" {
+			return fmt.Errorf("%w: no field set", ErrInvalidLinkSelector)
+		}
+
+		if _, err := path.Match(s.Name, ""); err != nil {
+			return fmt.Errorf("%w: name %q: %v", ErrInvalidLinkSelector, s.Name, err)
+		}
+	}
+
+	return nil
+}
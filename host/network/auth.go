@@ -0,0 +1,168 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package network
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"system-transparency.org/stboot/host"
+)
+
+// HMACAuthPrefix starts the value of headers of host.AuthHMACSHA256.
+const HMACAuthPrefix = "STBOOT-HMAC-SHA256"
+
+var ErrNoAuthHosts = errors.New("no hosts to authenticate to")
+
+// authTransport adds the authentication header to HTTPS requests to hosts,
+// which are keyed by authKey.
+type authTransport struct {
+	base   http.RoundTripper
+	header *host.AuthHeader
+	id     string
+	secret string
+	hosts  map[string]bool
+	now    func() time.Time
+}
+
+// RoundTrip implements http.RoundTripper.
+func (a *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "https" || !a.hosts[authKey(req.URL)] {
+		return a.base.RoundTrip(req)
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Set(a.header.HeaderName(), a.value(req))
+
+	return a.base.RoundTrip(req)
+}
+
+func (a *authTransport) value(req *http.Request) string {
+	if a.header.Scheme == host.AuthBearer {
+		return "Bearer " + a.secret
+	}
+
+	ts := strconv.FormatInt(a.now().Unix(), 10)
+
+	value := HMACAuthPrefix
+	if a.id != "" {
+		value += " id=" + a.id + ","
+	}
+
+	return value + " ts=" + ts + ", sig=" + SignRequest(a.secret, req.Method, req.URL, ts)
+}
+
+// authKey returns scheme and host of u with the port made explicit, so the
+// default port matches whether it is given or not.
+func authKey(u *url.URL) string {
+	defaultPorts := map[string]string{"http": "80", "https": "443"}
+
+	scheme := strings.ToLower(u.Scheme)
+
+	port := u.Port()
+	if port == "" {
+		port = defaultPorts[scheme]
+	}
+
+	return scheme + "://" + net.JoinHostPort(strings.ToLower(u.Hostname()), port)
+}
+
+// SignRequest returns the hex encoded HMAC-SHA256 with key secret over the
+// request method, host, request URI and timestamp ts, separated by newlines.
+func SignRequest(secret, method string, u *url.URL, ts string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s", method, strings.ToLower(u.Host), u.RequestURI(), ts)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// UseAuthHeader makes h send the authentication of cfg in the header
+// configured by cfg.AuthHeader. It is only sent via HTTPS to the hosts of the
+// OS package pointer and the OS package stores, also after redirects. Since a
+// bearer token must not be sent in cleartext, plain HTTP URLs are rejected
+// for host.AuthBearer.
+func (h *HTTPClient) UseAuthHeader(cfg *host.Config) error {
+	hosts := make(map[string]bool)
+
+	var urls []string
+
+	if cfg.OSPkgPointer != nil {
+		urls = append(urls, strings.Split(*cfg.OSPkgPointer, ",")...)
+	}
+
+	if cfg.OSPkgStores != nil {
+		urls = append(urls, *cfg.OSPkgStores...)
+	}
+
+	for _, s := range urls {
+		u, err := url.Parse(s)
+		if err != nil || u.Host == "" {
+			continue
+		}
+
+		switch strings.ToLower(u.Scheme) {
+		case "https":
+			hosts[authKey(u)] = true
+		case "http":
+			if cfg.AuthHeader.Scheme == host.AuthBearer {
+				return fmt.Errorf("%w: bearer token for %s", ErrInsecureURL, u.Redacted())
+			}
+		}
+	}
+
+	if len(hosts) == 0 {
+		return ErrNoAuthHosts
+	}
+
+	auth := &authTransport{
+		base:   h.HTTPClient.Transport,
+		header: cfg.AuthHeader,
+		hosts:  hosts,
+		now:    time.Now,
+	}
+
+	if cfg.ID != nil {
+		auth.id = *cfg.ID
+	}
+
+	if cfg.Auth != nil {
+		auth.secret = *cfg.Auth
+	}
+
+	if auth.base == nil {
+		auth.base = http.DefaultTransport
+	}
+
+	h.HTTPClient.Transport = auth
+
+	return nil
+}
+
+// transport returns the transport created by NewHTTPClient, if h uses it.
+func (h *HTTPClient) transport() (*http.Transport, bool) {
+	rt := h.HTTPClient.Transport
+
+	for {
+		switch wrapper := rt.(type) {
+		case *authTransport:
+			rt = wrapper.base
+		case *pinTransport:
+			rt = wrapper.base
+		default:
+			transport, ok := rt.(*http.Transport)
+
+			return transport, ok
+		}
+	}
+}
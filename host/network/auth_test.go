@@ -0,0 +1,179 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"system-transparency.org/stboot/host"
+)
+
+func TestHTTPClientAuthHeader(t *testing.T) {
+	headers := make(chan http.Header, 1)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header.Clone()
+
+		fmt.Fprint(w, "ok")
+	})
+
+	svr := httptest.NewTLSServer(handler)
+	t.Cleanup(svr.Close)
+
+	other := httptest.NewTLSServer(handler)
+	t.Cleanup(other.Close)
+
+	id, auth := "device", "c2VjcmV0"
+	hmacValue := regexp.MustCompile(`^STBOOT-HMAC-SHA256 id=device, ts=([0-9]+), sig=([0-9a-f]{64})$`)
+
+	for _, tt := range []struct {
+		name   string
+		header host.AuthHeader
+		check  func(t *testing.T, value string)
+	}{
+		{
+			name:   "bearer",
+			header: host.AuthHeader{Scheme: host.AuthBearer},
+			check: func(t *testing.T, value string) {
+				t.Helper()
+
+				if value != "Bearer "+auth {
+					t.Errorf("got %q, want bearer token", value)
+				}
+			},
+		},
+		{
+			name:   "hmac",
+			header: host.AuthHeader{Scheme: host.AuthHMACSHA256, Name: "X-Stboot-Auth"},
+			check: func(t *testing.T, value string) {
+				t.Helper()
+
+				m := hmacValue.FindStringSubmatch(value)
+				if m == nil {
+					t.Fatalf("got %q, want HMAC", value)
+				}
+
+				if want := SignRequest(auth, http.MethodGet, mkURL(svr.URL+"/os.json"), m[1]); m[2] != want {
+					t.Errorf("got signature %s, want %s", m[2], want)
+				}
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			pointer := svr.URL + "/os.json"
+			cfg := &host.Config{OSPkgPointer: &pointer, ID: &id, Auth: &auth, AuthHeader: &tt.header}
+
+			client := NewHTTPClient(nil, true)
+			client.Retries = 1
+			client.RetryWait = 0
+
+			if err := client.UseAuthHeader(cfg); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := client.Download(context.Background(), mkURL(pointer)); err != nil {
+				t.Fatal(err)
+			}
+
+			tt.check(t, (<-headers).Get(tt.header.HeaderName()))
+
+			if _, err := client.Download(context.Background(), mkURL(other.URL+"/os.json")); err != nil {
+				t.Fatal(err)
+			}
+
+			if value := (<-headers).Get(tt.header.HeaderName()); value != "" {
+				t.Errorf("got %q sent to other host", value)
+			}
+		})
+	}
+}
+
+func TestUseAuthHeaderNoHosts(t *testing.T) {
+	auth := "c2VjcmV0"
+	cfg := &host.Config{Auth: &auth, AuthHeader: &host.AuthHeader{Scheme: host.AuthBearer}}
+	client := NewHTTPClient(nil, true)
+
+	if err := client.UseAuthHeader(cfg); !errors.Is(err, ErrNoAuthHosts) {
+		t.Errorf("got %v, want %v", err, ErrNoAuthHosts)
+	}
+}
+
+func TestHTTPClientAuthHeaderHTTP(t *testing.T) {
+	headers := make(chan http.Header, 1)
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header.Clone()
+
+		fmt.Fprint(w, "ok")
+	}))
+	t.Cleanup(plain.Close)
+
+	secure := httptest.NewTLSServer(http.RedirectHandler(plain.URL+"/os.json", http.StatusFound))
+	t.Cleanup(secure.Close)
+
+	// The plain HTTP store is not authenticated to, not even after a
+	// redirect from an authenticated host.
+	pointer := secure.URL + "/os.json"
+	stores := []string{plain.URL}
+	auth := "c2VjcmV0"
+	header := host.AuthHeader{Scheme: host.AuthHMACSHA256}
+	cfg := &host.Config{OSPkgPointer: &pointer, OSPkgStores: &stores, Auth: &auth, AuthHeader: &header}
+
+	client := NewHTTPClient(nil, true)
+	client.Retries = 1
+	client.RetryWait = 0
+
+	if err := client.UseAuthHeader(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.Download(context.Background(), mkURL(pointer)); err != nil {
+		t.Fatal(err)
+	}
+
+	if value := (<-headers).Get(header.HeaderName()); value != "" {
+		t.Errorf("got %q sent via plain HTTP after redirect", value)
+	}
+
+	if _, err := client.Download(context.Background(), mkURL(plain.URL)); err != nil {
+		t.Fatal(err)
+	}
+
+	if value := (<-headers).Get(header.HeaderName()); value != "" {
+		t.Errorf("got %q sent via plain HTTP", value)
+	}
+}
+
+func TestUseAuthHeaderBearerHTTP(t *testing.T) {
+	pointer := "https://server.com/os.json,http://server.com/os.json"
+	auth := "c2VjcmV0"
+	cfg := &host.Config{OSPkgPointer: &pointer, Auth: &auth, AuthHeader: &host.AuthHeader{Scheme: host.AuthBearer}}
+	client := NewHTTPClient(nil, true)
+
+	if err := client.UseAuthHeader(cfg); !errors.Is(err, ErrInsecureURL) {
+		t.Errorf("got %v, want %v", err, ErrInsecureURL)
+	}
+}
+
+func TestAuthKey(t *testing.T) {
+	for _, tt := range []struct {
+		a, b string
+		same bool
+	}{
+		{"https://Server.com/os.json", "https://server.com:443/ospkg.zip", true},
+		{"https://[2001:db8::1]/", "https://[2001:DB8::1]:443", true},
+		{"https://server.com:8443/", "https://server.com/", false},
+		{"http://server.com/", "https://server.com/", false},
+		{"http://server.com:443/", "https://server.com/", false},
+	} {
+		if same := authKey(mkURL(tt.a)) == authKey(mkURL(tt.b)); same != tt.same {
+			t.Errorf("%s and %s: got same %v, want %v", tt.a, tt.b, same, tt.same)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"errors"
+	"fmt"
 	"io"
 	"net"
 	"net/http"
@@ -13,6 +14,7 @@ import (
 	"time"
 
 	"github.com/u-root/u-root/pkg/uio"
+	"system-transparency.org/stboot/host"
 	"system-transparency.org/stboot/sterror"
 	"system-transparency.org/stboot/stlog"
 )
@@ -21,6 +23,25 @@ type HTTPClient struct {
 	HTTPClient http.Client
 	Retries    int
 	RetryWait  int
+	httpsOnly  bool
+}
+
+// RequireHTTPS makes h reject plain HTTP URLs, including redirect targets.
+func (h *HTTPClient) RequireHTTPS() {
+	h.httpsOnly = true
+	h.HTTPClient.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		const maxRedirects = 10
+
+		if req.URL.Scheme != "https" {
+			return ErrInsecureURL
+		}
+
+		if len(via) >= maxRedirects {
+			return errors.New("stopped after 10 redirects")
+		}
+
+		return nil
+	}
 }
 
 func NewHTTPClient(httpsRoots []*x509.Certificate, insecure bool) HTTPClient {
@@ -73,18 +94,60 @@ func NewHTTPClient(httpsRoots []*x509.Certificate, insecure bool) HTTPClient {
 }
 
 var (
-	ErrDownloadTimeout = errors.New("hit download timeout")
-	ErrRetriesLimit    = errors.New("hit retries limit")
-	ErrBadHTTPStatus   = errors.New("bad HTTP status")
-	ErrEmptyBody       = errors.New("HTTP response body is empty")
+	ErrDownloadTimeout     = errors.New("hit download timeout")
+	ErrRetriesLimit        = errors.New("hit retries limit")
+	ErrBadHTTPStatus       = errors.New("bad HTTP status")
+	ErrEmptyBody           = errors.New("HTTP response body is empty")
+	ErrInsecureURL         = errors.New("plain HTTP is not allowed")
+	ErrProxyTransport      = errors.New("proxy requires the transport of NewHTTPClient")
+	ErrClientCertTransport = errors.New("client certificate requires the transport of NewHTTPClient")
 )
 
+// UseProxy routes requests of h through proxy, except for hosts matched by its
+// no-proxy list. HTTPS requests are tunnelled with CONNECT, so the TLS
+// configuration of h, including its roots, still applies to the server.
+func (h *HTTPClient) UseProxy(proxy *host.Proxy) error {
+	transport, ok := h.transport()
+	if !ok {
+		return ErrProxyTransport
+	}
+
+	proxyURL := proxy.ProxyURL()
+
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		if proxy.Bypass(req.URL.Hostname()) {
+			return nil, nil
+		}
+
+		return proxyURL, nil
+	}
+
+	return nil
+}
+
+// UseClientCert makes h present cert to servers requesting TLS client
+// authentication.
+func (h *HTTPClient) UseClientCert(cert tls.Certificate) error {
+	transport, ok := h.transport()
+	if !ok || transport.TLSClientConfig == nil {
+		return ErrClientCertTransport
+	}
+
+	transport.TLSClientConfig.Certificates = []tls.Certificate{cert}
+
+	return nil
+}
+
 // Wrapper for DownloadObject to deal with retries.
 func (h *HTTPClient) Download(ctx context.Context, url *url.URL) ([]byte, error) {
 	var ret []byte
 
 	var err error
 
+	if h.httpsOnly && url.Scheme != "https" {
+		return nil, ErrInsecureURL
+	}
+
 	for iter := 0; iter < h.Retries; iter++ {
 		ret, err = DownloadObject(ctx, h.HTTPClient, url)
 		if err == nil {
@@ -95,10 +158,19 @@ func (h *HTTPClient) Download(ctx context.Context, url *url.URL) ([]byte, error)
 			return nil, ErrDownloadTimeout
 		}
 
+		// A different key or root will not show up on retry.
+		if errors.Is(err, ErrPinMismatch) || errors.Is(err, ErrRootNotAllowed) {
+			return nil, err
+		}
+
 		time.Sleep(time.Second * time.Duration(h.RetryWait))
 	}
 
 	if len(ret) == 0 {
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrRetriesLimit, err)
+		}
+
 		return nil, ErrRetriesLimit
 	}
 
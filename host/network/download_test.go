@@ -2,15 +2,26 @@ package network
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
 	"crypto/x509"
+	"crypto/x509/pkix"
 	"errors"
 	"fmt"
+	"io"
+	"math/big"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"reflect"
+	"sync"
 	"testing"
 	"time"
 
+	"system-transparency.org/stboot/host"
 	"system-transparency.org/stboot/stlog"
 )
 
@@ -69,3 +80,271 @@ func TestHTTPClientCancel(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestHTTPClientRequireHTTPS(t *testing.T) {
+	if !testing.Verbose() {
+		stlog.SetLevel(stlog.ErrorLevel)
+	}
+
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "plain")
+	}))
+	defer plain.Close()
+
+	secure := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, plain.URL, http.StatusFound)
+
+			return
+		}
+
+		fmt.Fprint(w, "secure")
+	}))
+	defer secure.Close()
+
+	client := NewHTTPClient([]*x509.Certificate{secure.Certificate()}, false)
+	client.Retries = 1
+	client.RetryWait = 0
+	client.RequireHTTPS()
+
+	if _, err := client.Download(context.Background(), mkURL(plain.URL)); !errors.Is(err, ErrInsecureURL) {
+		t.Errorf("got %v, want %v", err, ErrInsecureURL)
+	}
+
+	if _, err := client.Download(context.Background(), mkURL(secure.URL+"/redirect")); err == nil {
+		t.Error("expect redirect to plain HTTP to fail")
+	}
+
+	b, err := client.Download(context.Background(), mkURL(secure.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) != "secure" {
+		t.Errorf("got %q, want %q", b, "secure")
+	}
+}
+
+// testProxy forwards plain HTTP requests and tunnels CONNECT requests. It
+// records the requested hosts and requires basic authentication.
+func testProxy(t *testing.T, user, password string) (*httptest.Server, *[]string) {
+	t.Helper()
+
+	var (
+		mu    sync.Mutex
+		hosts []string
+	)
+
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, p, ok := parseProxyAuth(r.Header.Get("Proxy-Authorization"))
+		if !ok || u != user || p != password {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+
+			return
+		}
+
+		mu.Lock()
+		hosts = append(hosts, r.Method+" "+r.Host)
+		mu.Unlock()
+
+		if r.Method != http.MethodConnect {
+			r.RequestURI = ""
+			r.Header.Del("Proxy-Authorization")
+
+			resp, err := http.DefaultTransport.RoundTrip(r)
+			if err != nil {
+				w.WriteHeader(http.StatusBadGateway)
+
+				return
+			}
+			defer resp.Body.Close()
+
+			w.WriteHeader(resp.StatusCode)
+			_, _ = io.Copy(w, resp.Body)
+
+			return
+		}
+
+		upstream, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			upstream.Close()
+
+			return
+		}
+
+		go func() {
+			_, _ = io.Copy(upstream, conn)
+			upstream.Close()
+		}()
+
+		_, _ = io.Copy(conn, upstream)
+		conn.Close()
+	}))
+	t.Cleanup(proxy.Close)
+
+	return proxy, &hosts
+}
+
+func parseProxyAuth(header string) (string, string, bool) {
+	req := http.Request{Header: http.Header{"Authorization": {header}}}
+
+	return req.BasicAuth()
+}
+
+func TestHTTPClientProxy(t *testing.T) {
+	if !testing.Verbose() {
+		stlog.SetLevel(stlog.ErrorLevel)
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok")
+	})
+
+	trusted := httptest.NewTLSServer(handler)
+	defer trusted.Close()
+
+	// httptest servers share a certificate, so give this one its own.
+	untrusted := httptest.NewUnstartedServer(handler)
+	untrusted.TLS = &tls.Config{Certificates: []tls.Certificate{selfSigned(t)}}
+	untrusted.StartTLS()
+	defer untrusted.Close()
+
+	plain := httptest.NewServer(handler)
+	defer plain.Close()
+
+	proxy, hosts := testProxy(t, "stboot", "s3cret")
+
+	newClient := func(password string, noProxy ...string) *HTTPClient {
+		client := NewHTTPClient([]*x509.Certificate{trusted.Certificate()}, false)
+		client.Retries = 1
+		client.RetryWait = 0
+
+		err := client.UseProxy(&host.Proxy{
+			URL:      mkURL(proxy.URL),
+			Username: "stboot",
+			Password: password,
+			NoProxy:  noProxy,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return &client
+	}
+
+	client := newClient("s3cret")
+
+	if _, err := client.Download(context.Background(), mkURL(trusted.URL)); err != nil {
+		t.Errorf("HTTPS through proxy: %v", err)
+	}
+
+	if _, err := client.Download(context.Background(), mkURL(plain.URL)); err != nil {
+		t.Errorf("HTTP through proxy: %v", err)
+	}
+
+	want := []string{"CONNECT " + mkURL(trusted.URL).Host, "GET " + mkURL(plain.URL).Host}
+	if !reflect.DeepEqual(*hosts, want) {
+		t.Errorf("proxy got %v, want %v", *hosts, want)
+	}
+
+	if _, err := client.Download(context.Background(), mkURL(untrusted.URL)); err == nil {
+		t.Error("expect certificate of untrusted server to be rejected through proxy")
+	}
+
+	if _, err := newClient("wrong").Download(context.Background(), mkURL(trusted.URL)); err == nil {
+		t.Error("expect wrong proxy credentials to fail")
+	}
+
+	*hosts = nil
+
+	if _, err := newClient("s3cret", "127.0.0.1").Download(context.Background(), mkURL(trusted.URL)); err != nil {
+		t.Errorf("no proxy: %v", err)
+	}
+
+	if len(*hosts) != 0 {
+		t.Errorf("expect proxy to be bypassed, got %v", *hosts)
+	}
+}
+
+func selfSigned(t *testing.T) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "untrusted"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestHTTPClientClientCert(t *testing.T) {
+	serverCert := selfSigned(t)
+	clientCert := selfSigned(t)
+
+	clientLeaf, err := x509.ParseCertificate(clientCert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serverLeaf, err := x509.ParseCertificate(serverCert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientLeaf)
+
+	svr := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+	svr.TLS = &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	}
+	svr.StartTLS()
+	t.Cleanup(svr.Close)
+
+	client := NewHTTPClient([]*x509.Certificate{serverLeaf}, false)
+	client.Retries = 1
+	client.RetryWait = 0
+
+	if _, err := client.Download(context.Background(), mkURL(svr.URL)); err == nil {
+		t.Error("expect download without client certificate to fail")
+	}
+
+	if err := client.UseClientCert(clientCert); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := client.Download(context.Background(), mkURL(svr.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(got) != "untrusted" {
+		t.Errorf("server saw client %q", got)
+	}
+}
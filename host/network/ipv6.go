@@ -0,0 +1,179 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package network
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+	"system-transparency.org/stboot/sterror"
+	"system-transparency.org/stboot/stlog"
+)
+
+const ipv6ConfPath = "/proc/sys/net/ipv6/conf"
+
+var (
+	// ipv6Timeout bounds waiting for duplicate address detection and router
+	// advertisements.
+	ipv6Timeout      = 30 * time.Second
+	ipv6PollInterval = 100 * time.Millisecond
+)
+
+// configureSLAAC enables router advertisements on all links and returns the
+// first one to autoconfigure a global address.
+func configureSLAAC(links []netlink.Link) (netlink.Link, error) {
+	stlog.Info("Configure network interface using SLAAC")
+
+	var up []netlink.Link
+
+	for _, link := range links {
+		if err := acceptRA(link, true); err != nil {
+			stlog.Debug("%s: SLAAC config failed: %v", link.Attrs().Name, err)
+
+			continue
+		}
+
+		if err := netlink.LinkSetUp(link); err != nil {
+			stlog.Debug("%s: SLAAC config failed: %v", link.Attrs().Name, err)
+
+			continue
+		}
+
+		up = append(up, link)
+	}
+
+	deadline := time.Now().Add(ipv6Timeout)
+
+	for len(up) > 0 && time.Now().Before(deadline) {
+		for _, link := range up {
+			if ok, _ := hasIPv6Addr(link, isGlobal); !ok {
+				continue
+			}
+
+			if ok, _ := hasDefaultRoute6(link); !ok {
+				continue
+			}
+
+			stlog.Info("SLAAC successful - %s", link.Attrs().Name)
+
+			return link, nil
+		}
+
+		time.Sleep(ipv6PollInterval)
+	}
+
+	return nil, sterror.E(ErrScope, ErrOpConfigureSLAAC, ErrNetworkConfiguration, ErrInfoFailedForAllInterfaces)
+}
+
+// acceptRA enables IPv6 on link and makes it accept router advertisements.
+// With autoconf set, addresses are generated from advertised prefixes.
+func acceptRA(link netlink.Link, autoconf bool) error {
+	settings := []string{"disable_ipv6=0", "accept_ra=1"}
+	if autoconf {
+		settings = append(settings, "autoconf=1")
+	}
+
+	for _, s := range settings {
+		name, value, _ := strings.Cut(s, "=")
+
+		path := filepath.Join(ipv6ConfPath, link.Attrs().Name, name)
+		if err := os.WriteFile(path, []byte(value), 0o644); err != nil {
+			return fmt.Errorf("set %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func isGlobal(ip net.IP) bool {
+	return ip.IsGlobalUnicast() && !ip.IsLinkLocalUnicast()
+}
+
+// hasIPv6Addr reports whether link has an IPv6 address matching match that
+// has passed duplicate address detection.
+func hasIPv6Addr(link netlink.Link, match func(net.IP) bool) (bool, error) {
+	addrs, err := netlink.AddrList(link, netlink.FAMILY_V6)
+	if err != nil {
+		return false, err
+	}
+
+	for _, addr := range addrs {
+		if !match(addr.IP) {
+			continue
+		}
+
+		if addr.Flags&unix.IFA_F_DADFAILED != 0 {
+			return false, fmt.Errorf("duplicate address %s", addr.IP)
+		}
+
+		if addr.Flags&unix.IFA_F_TENTATIVE == 0 {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// waitIPv6Addr waits until link has a usable address matching match.
+func waitIPv6Addr(link netlink.Link, match func(net.IP) bool) error {
+	deadline := time.Now().Add(ipv6Timeout)
+
+	for time.Now().Before(deadline) {
+		ok, err := hasIPv6Addr(link, match)
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			return nil
+		}
+
+		time.Sleep(ipv6PollInterval)
+	}
+
+	return ErrIPv6Timeout
+}
+
+func hasDefaultRoute6(link netlink.Link) (bool, error) {
+	routes, err := netlink.RouteList(link, netlink.FAMILY_V6)
+	if err != nil {
+		return false, err
+	}
+
+	for _, r := range routes {
+		if r.Dst == nil && r.Gw != nil {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// waitDefaultRoute6 waits until a router advertisement has installed a default
+// route on link.
+func waitDefaultRoute6(link netlink.Link) error {
+	deadline := time.Now().Add(ipv6Timeout)
+
+	for time.Now().Before(deadline) {
+		ok, err := hasDefaultRoute6(link)
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			return nil
+		}
+
+		time.Sleep(ipv6PollInterval)
+	}
+
+	return ErrIPv6Timeout
+}
@@ -0,0 +1,319 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package network
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+	"system-transparency.org/stboot/host"
+)
+
+// advertise sends router advertisements from the router until the test ends.
+// With managed set, the M flag points clients to DHCPv6. A non-nil prefix is
+// announced for autoconfiguration.
+func (r router) advertise(t *testing.T, managed bool, prefix *net.IPNet) {
+	t.Helper()
+
+	var conn net.PacketConn
+
+	err := r.do(func() error {
+		var err error
+		conn, err = net.ListenPacket("ip6:ipv6-icmp", "fe80::1%router0")
+
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := conn.(*net.IPConn).SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Neighbor discovery packets must have a hop limit of 255.
+	var serr error
+	if err := raw.Control(func(fd uintptr) {
+		serr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_MULTICAST_HOPS, 255)
+	}); err != nil || serr != nil {
+		t.Fatal(err, serr)
+	}
+
+	const lifetime = 1800
+
+	msg := []byte{134, 0, 0, 0, 64, 0}
+	if managed {
+		msg[5] = 0x80
+	}
+
+	msg = binary.BigEndian.AppendUint16(msg, lifetime)
+	msg = append(msg, make([]byte, 8)...)
+
+	if prefix != nil {
+		ones, _ := prefix.Mask.Size()
+		msg = append(msg, 3, 4, byte(ones), 0xc0)
+		msg = binary.BigEndian.AppendUint32(msg, lifetime)
+		msg = binary.BigEndian.AppendUint32(msg, lifetime)
+		msg = append(msg, make([]byte, 4)...)
+		msg = append(msg, prefix.IP.To16()...)
+	}
+
+	done := make(chan struct{})
+	dst := &net.IPAddr{IP: net.IPv6linklocalallnodes, Zone: "router0"}
+
+	go func() {
+		ticker := time.NewTicker(200 * time.Millisecond)
+		defer ticker.Stop()
+
+		for {
+			_, _ = conn.WriteTo(msg, dst)
+
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	t.Cleanup(func() {
+		close(done)
+		conn.Close()
+	})
+}
+
+// serveDHCPv6 answers rapid commit solicits on the router with lease.
+func (r router) serveDHCPv6(t *testing.T, lease net.IP) {
+	t.Helper()
+
+	var conn *net.UDPConn
+
+	err := r.do(func() error {
+		iface, err := net.InterfaceByName("router0")
+		if err != nil {
+			return err
+		}
+
+		conn, err = net.ListenMulticastUDP("udp6", iface, &net.UDPAddr{
+			IP:   dhcpv6.AllDHCPRelayAgentsAndServers,
+			Port: dhcpv6.DefaultServerPort,
+		})
+
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { conn.Close() })
+
+	serverID := dhcpv6.Duid{
+		Type:          dhcpv6.DUID_LL,
+		HwType:        1,
+		LinkLayerAddr: net.HardwareAddr{2, 0, 0, 0, 0, 1},
+	}
+
+	go func() {
+		buf := make([]byte, 1500)
+
+		for {
+			n, peer, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+
+			msg, err := dhcpv6.MessageFromBytes(buf[:n])
+			if err != nil || msg.Type() != dhcpv6.MessageTypeSolicit {
+				continue
+			}
+
+			iana := &dhcpv6.OptIANA{}
+			if req := msg.Options.OneIANA(); req != nil {
+				iana.IaId = req.IaId
+			}
+
+			iana.Options.Add(&dhcpv6.OptIAAddress{
+				IPv6Addr:          lease,
+				PreferredLifetime: time.Hour,
+				ValidLifetime:     time.Hour,
+			})
+
+			reply, err := dhcpv6.NewReplyFromMessage(msg,
+				dhcpv6.WithServerID(serverID),
+				dhcpv6.WithOption(iana))
+			if err != nil {
+				continue
+			}
+
+			_, _ = conn.WriteToUDP(reply.ToBytes(), peer)
+		}
+	}()
+}
+
+func TestSetupIPv6Static(t *testing.T) {
+	if !inNetns(t) {
+		return
+	}
+
+	link, _ := setupVeth(t)
+
+	cfg := testConfig(host.IPStatic)
+	cfg.HostIP, _ = netlink.ParseAddr("2001:db8::2/64")
+	cfg.DefaultGateway = &routerIPv6
+
+	if err := SetupNetworkInterface(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if !hasAddr(t, link, netlink.FAMILY_V6, cfg.HostIP.IP.Equal) {
+		t.Error("static address not configured")
+	}
+
+	if !hasDefaultRoute(t, link, netlink.FAMILY_V6, routerIPv6) {
+		t.Error("default route not configured")
+	}
+}
+
+func TestSetupSLAAC(t *testing.T) {
+	if !inNetns(t) {
+		return
+	}
+
+	link, r := setupVeth(t)
+	_, prefix, _ := net.ParseCIDR("2001:db8:1::/64")
+	r.advertise(t, false, prefix)
+
+	if err := SetupNetworkInterface(testConfig(host.IPSLAAC)); err != nil {
+		t.Fatal(err)
+	}
+
+	if !hasAddr(t, link, netlink.FAMILY_V6, prefix.Contains) {
+		t.Errorf("no address from %s", prefix)
+	}
+
+	if !hasDefaultRoute(t, link, netlink.FAMILY_V6, routerLinkLocal) {
+		t.Error("default route not configured")
+	}
+}
+
+func TestSetupDHCPv6(t *testing.T) {
+	if !inNetns(t) {
+		return
+	}
+
+	link, r := setupVeth(t)
+	lease := net.ParseIP("2001:db8::100")
+	r.advertise(t, true, nil)
+	r.serveDHCPv6(t, lease)
+
+	if err := SetupNetworkInterface(testConfig(host.IPDHCPv6)); err != nil {
+		t.Fatal(err)
+	}
+
+	if !hasAddr(t, link, netlink.FAMILY_V6, lease.Equal) {
+		t.Errorf("leased address %s not configured", lease)
+	}
+
+	if !hasDefaultRoute(t, link, netlink.FAMILY_V6, routerLinkLocal) {
+		t.Error("default route not configured")
+	}
+}
+
+func TestSetupDualStack(t *testing.T) {
+	if !inNetns(t) {
+		return
+	}
+
+	link, r := setupVeth(t)
+	_, prefix, _ := net.ParseCIDR("2001:db8:1::/64")
+	r.advertise(t, false, prefix)
+
+	mode := host.IPSLAAC
+	cfg := testConfig(host.IPStatic)
+	cfg.HostIP, _ = netlink.ParseAddr("192.0.2.2/24")
+	cfg.DefaultGateway = &routerIPv4
+	cfg.IPv6AddrMode = &mode
+
+	if err := SetupNetworkInterface(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if !hasDefaultRoute(t, link, netlink.FAMILY_V4, routerIPv4) {
+		t.Error("IPv4 default route not configured")
+	}
+
+	if !hasAddr(t, link, netlink.FAMILY_V6, prefix.Contains) {
+		t.Errorf("no address from %s", prefix)
+	}
+
+	if !hasDefaultRoute(t, link, netlink.FAMILY_V6, routerLinkLocal) {
+		t.Error("IPv6 default route not configured")
+	}
+}
+
+func TestSetupDualStackStatic(t *testing.T) {
+	if !inNetns(t) {
+		return
+	}
+
+	link, _ := setupVeth(t)
+
+	mode := host.IPStatic
+	cfg := testConfig(host.IPStatic)
+	cfg.HostIP, _ = netlink.ParseAddr("192.0.2.2/24")
+	cfg.DefaultGateway = &routerIPv4
+	cfg.IPv6AddrMode = &mode
+	cfg.HostIPv6, _ = netlink.ParseAddr("2001:db8::2/64")
+	cfg.DefaultGatewayV6 = &routerIPv6
+
+	if err := SetupNetworkInterface(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if !hasDefaultRoute(t, link, netlink.FAMILY_V4, routerIPv4) {
+		t.Error("IPv4 default route not configured")
+	}
+
+	if !hasDefaultRoute(t, link, netlink.FAMILY_V6, routerIPv6) {
+		t.Error("IPv6 default route not configured")
+	}
+}
+
+func TestSetupDualStackIPv4Failure(t *testing.T) {
+	if !inNetns(t) {
+		return
+	}
+
+	link, _ := setupVeth(t)
+
+	// The IPv4 gateway is not on-link, so IPv4 fails.
+	offLink := net.ParseIP("10.9.9.9")
+	mode := host.IPStatic
+	cfg := testConfig(host.IPStatic)
+	cfg.HostIP, _ = netlink.ParseAddr("192.0.2.2/24")
+	cfg.DefaultGateway = &offLink
+	cfg.IPv6AddrMode = &mode
+	cfg.HostIPv6, _ = netlink.ParseAddr("2001:db8::2/64")
+	cfg.DefaultGatewayV6 = &routerIPv6
+
+	state, err := SetupNetwork(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if state.Interface != link.Attrs().Name {
+		t.Errorf("got interface %s, want %s", state.Interface, link.Attrs().Name)
+	}
+
+	if !hasDefaultRoute(t, link, netlink.FAMILY_V6, routerIPv6) {
+		t.Error("IPv6 default route not configured")
+	}
+}
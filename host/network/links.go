@@ -0,0 +1,300 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package network
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vishvananda/netlink"
+	"system-transparency.org/stboot/host"
+	"system-transparency.org/stboot/sterror"
+	"system-transparency.org/stboot/stlog"
+)
+
+const sysfsNet = "/sys/class/net"
+
+var carrierPollInterval = 100 * time.Millisecond
+
+// linkInfo describes a network interface for selection.
+type linkInfo struct {
+	link    netlink.Link
+	driver  string
+	pciPath string
+}
+
+func (l linkInfo) name() string {
+	return l.link.Attrs().Name
+}
+
+// configLinks returns the links to be configured, in the order they should be
+// tried. With bonding, this is the bond interface.
+func configLinks(cfg *host.Config) ([]netlink.Link, error) {
+	if cfg.BondingMode != host.BondingUnset {
+		bond, err := ConfigureBondInterface(cfg)
+		if err != nil {
+			return nil, sterror.E(ErrScope, ErrOpConfigureBonding, ErrBond, err.Error())
+		}
+		// If we use the bond interface we don't know the MAC address the kernel gives it
+		// ignore the original device and replace with our bonding interface
+		links, err := waitCarrier([]linkInfo{{link: bond}}, cfg.LinkTimeout())
+		if err != nil {
+			removeLink(bond)
+
+			return nil, err
+		}
+
+		return links, nil
+	}
+
+	all, err := listLinks(sysfsNet)
+	if err != nil {
+		return nil, sterror.E(ErrScope, ErrOpfindInterface, ErrNetworkConfiguration, err.Error())
+	}
+
+	candidates := selectLinks(all, linkSelectors(cfg))
+
+	if len(candidates) == 0 && cfg.NetworkInterfaces != nil {
+		stlog.Info("No NIC with configured MAC address, trying all interfaces")
+
+		candidates = selectLinks(all, nil)
+	}
+
+	if len(candidates) == 0 {
+		return nil, sterror.E(ErrScope, ErrOpfindInterface, ErrNetworkConfiguration, ErrInfoFoundNoInterfaces)
+	}
+
+	return waitCarrier(candidates, cfg.LinkTimeout())
+}
+
+// linkSelectors returns the link selectors of cfg. NetworkInterfaces are
+// selected by MAC address.
+func linkSelectors(cfg *host.Config) []*host.LinkSelector {
+	if cfg.LinkSelectors != nil {
+		return *cfg.LinkSelectors
+	}
+
+	if cfg.NetworkInterfaces == nil {
+		return nil
+	}
+
+	var selectors []*host.LinkSelector
+
+	for _, iface := range *cfg.NetworkInterfaces {
+		if iface.MACAddress != nil {
+			selectors = append(selectors, &host.LinkSelector{MACAddress: *iface.MACAddress})
+		}
+	}
+
+	return selectors
+}
+
+// listLinks returns all network interfaces ordered by index. Driver and PCI
+// path are read from sysfs.
+func listLinks(sysfs string) ([]linkInfo, error) {
+	links, err := netlink.LinkList()
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(links, func(i, j int) bool {
+		return links[i].Attrs().Index < links[j].Attrs().Index
+	})
+
+	infos := make([]linkInfo, 0, len(links))
+
+	for _, link := range links {
+		info := linkInfo{link: link}
+		info.driver, info.pciPath = deviceInfo(sysfs, link.Attrs().Name)
+		infos = append(infos, info)
+	}
+
+	return infos, nil
+}
+
+// deviceInfo returns the driver and, for PCI devices, the PCI path of the
+// network interface name. Virtual interfaces have neither.
+func deviceInfo(sysfs, name string) (string, string) {
+	var driver, pciPath string
+
+	device := filepath.Join(sysfs, name, "device")
+
+	if link, err := os.Readlink(filepath.Join(device, "driver")); err == nil {
+		driver = filepath.Base(link)
+	}
+
+	if link, err := os.Readlink(filepath.Join(device, "subsystem")); err == nil && filepath.Base(link) == "pci" {
+		if link, err := os.Readlink(device); err == nil {
+			pciPath = filepath.Base(link)
+		}
+	}
+
+	return driver, pciPath
+}
+
+// selectLinks returns the usable links matching selectors. Links are ordered
+// by the first selector they match. Without selectors, all usable links are
+// returned. The reason for skipping a link is logged.
+func selectLinks(links []linkInfo, selectors []*host.LinkSelector) []linkInfo {
+	usable := make([]linkInfo, 0, len(links))
+
+	for _, l := range links {
+		if reason := unusable(l.link); reason != "" {
+			stlog.Info("%s: skipped, %s", l.name(), reason)
+
+			continue
+		}
+
+		usable = append(usable, l)
+	}
+
+	if len(selectors) == 0 {
+		return usable
+	}
+
+	selected := make([]linkInfo, 0, len(usable))
+	taken := make(map[int]bool)
+
+	for _, s := range selectors {
+		for i, l := range usable {
+			if taken[i] {
+				continue
+			}
+
+			if reason := mismatch(s, l); reason != "" {
+				stlog.Debug("%s: does not match selector %s: %s", l.name(), s, reason)
+
+				continue
+			}
+
+			stlog.Debug("%s: matches selector %s", l.name(), s)
+
+			taken[i] = true
+
+			selected = append(selected, l)
+		}
+	}
+
+	for i, l := range usable {
+		if !taken[i] {
+			stlog.Info("%s: skipped, matches no link selector", l.name())
+		}
+	}
+
+	return selected
+}
+
+func unusable(link netlink.Link) string {
+	attrs := link.Attrs()
+
+	switch {
+	case attrs.Flags&net.FlagLoopback != 0:
+		return "loopback"
+	case len(attrs.HardwareAddr) == 0:
+		return "no hardware address"
+	case attrs.MasterIndex != 0:
+		return "enslaved to another interface"
+	default:
+		return ""
+	}
+}
+
+// mismatch returns why l does not match s, or the empty string if it does.
+func mismatch(s *host.LinkSelector, l linkInfo) string {
+	switch {
+	case s.MACAddress != nil && !bytes.Equal(s.MACAddress, l.link.Attrs().HardwareAddr):
+		return fmt.Sprintf("MAC address is %s", l.link.Attrs().HardwareAddr)
+	case s.Name != "":
+		if ok, _ := path.Match(s.Name, l.name()); !ok {
+			return "name does not match"
+		}
+	}
+
+	switch {
+	case s.Driver != "" && s.Driver != l.driver:
+		return fmt.Sprintf("driver is %q", l.driver)
+	case s.PCIPath != "" && !strings.EqualFold(s.PCIPath, l.pciPath):
+		return fmt.Sprintf("PCI path is %q", l.pciPath)
+	default:
+		return ""
+	}
+}
+
+// waitCarrier brings links up and waits until they have carrier. It returns
+// once all links have carrier, otherwise after timeout, so links which are
+// slower to come up are tried as well. The links with carrier are returned in
+// their original order.
+func waitCarrier(links []linkInfo, timeout time.Duration) ([]netlink.Link, error) {
+	up := make([]linkInfo, 0, len(links))
+
+	for _, l := range links {
+		if err := netlink.LinkSetUp(l.link); err != nil {
+			stlog.Info("%s: skipped, cannot set link up: %v", l.name(), err)
+
+			continue
+		}
+
+		up = append(up, l)
+	}
+
+	deadline := time.Now().Add(timeout)
+	ready := make([]netlink.Link, len(up))
+
+	for {
+		all := true
+
+		for i, l := range up {
+			if ready[i] != nil {
+				continue
+			}
+
+			link, err := netlink.LinkByIndex(l.link.Attrs().Index)
+			if err == nil && hasCarrier(link) {
+				ready[i] = link
+			} else {
+				all = false
+			}
+		}
+
+		if all || !time.Now().Before(deadline) {
+			break
+		}
+
+		time.Sleep(carrierPollInterval)
+	}
+
+	ret := make([]netlink.Link, 0, len(up))
+
+	for i, l := range up {
+		if ready[i] == nil {
+			stlog.Info("%s: skipped, no carrier", l.name())
+
+			continue
+		}
+
+		ret = append(ret, ready[i])
+	}
+
+	if len(ret) == 0 {
+		return nil, sterror.E(ErrScope, ErrOpfindInterface, ErrNetworkConfiguration, ErrInfoNoCarrier)
+	}
+
+	return ret, nil
+}
+
+// hasCarrier reports whether link is operational. Some drivers do not report
+// the operational state, so unknown counts as up.
+func hasCarrier(link netlink.Link) bool {
+	state := link.Attrs().OperState
+
+	return state == netlink.OperUp || state == netlink.OperUnknown
+}
@@ -0,0 +1,250 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package network
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/vishvananda/netlink"
+	"system-transparency.org/stboot/host"
+)
+
+func testLink(index int, name, mac, driver, pciPath string) linkInfo {
+	attrs := netlink.LinkAttrs{Index: index, Name: name}
+	if mac != "" {
+		attrs.HardwareAddr, _ = net.ParseMAC(mac)
+	}
+
+	return linkInfo{link: &netlink.Device{LinkAttrs: attrs}, driver: driver, pciPath: pciPath}
+}
+
+func mustMAC(t *testing.T, s string) net.HardwareAddr {
+	t.Helper()
+
+	mac, err := net.ParseMAC(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return mac
+}
+
+func TestSelectLinks(t *testing.T) {
+	lo := testLink(1, "lo", "", "", "")
+	lo.link.Attrs().Flags = net.FlagLoopback
+	slave := testLink(6, "eth3", "00:00:00:00:00:06", "igb", "0000:03:00.0")
+	slave.link.Attrs().MasterIndex = 7
+
+	links := []linkInfo{
+		lo,
+		testLink(2, "eno1", "00:00:00:00:00:02", "e1000e", "0000:00:1f.6"),
+		testLink(3, "enp1s0f0", "00:00:00:00:00:03", "ixgbe", "0000:01:00.0"),
+		testLink(4, "enp1s0f1", "00:00:00:00:00:04", "ixgbe", "0000:01:00.1"),
+		testLink(5, "tun0", "", "", ""),
+		slave,
+	}
+
+	tests := []struct {
+		name      string
+		selectors []*host.LinkSelector
+		want      []string
+	}{
+		{
+			name: "All usable",
+			want: []string{"eno1", "enp1s0f0", "enp1s0f1"},
+		},
+		{
+			name: "Several MAC addresses",
+			selectors: []*host.LinkSelector{
+				{MACAddress: mustMAC(t, "00:00:00:00:00:04")},
+				{MACAddress: mustMAC(t, "00:00:00:00:00:02")},
+			},
+			want: []string{"enp1s0f1", "eno1"},
+		},
+		{
+			name:      "Name glob",
+			selectors: []*host.LinkSelector{{Name: "enp*"}},
+			want:      []string{"enp1s0f0", "enp1s0f1"},
+		},
+		{
+			name:      "Driver",
+			selectors: []*host.LinkSelector{{Driver: "e1000e"}},
+			want:      []string{"eno1"},
+		},
+		{
+			name:      "PCI path",
+			selectors: []*host.LinkSelector{{PCIPath: "0000:01:00.1"}},
+			want:      []string{"enp1s0f1"},
+		},
+		{
+			name:      "All fields must match",
+			selectors: []*host.LinkSelector{{Name: "enp*", Driver: "e1000e"}},
+			want:      []string{},
+		},
+		{
+			name: "Preferred order without duplicates",
+			selectors: []*host.LinkSelector{
+				{Driver: "e1000e"},
+				{Name: "en*"},
+			},
+			want: []string{"eno1", "enp1s0f0", "enp1s0f1"},
+		},
+		{
+			name:      "Enslaved interfaces are skipped",
+			selectors: []*host.LinkSelector{{Name: "eth3"}},
+			want:      []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			for _, l := range selectLinks(links, tt.selectors) {
+				got = append(got, l.name())
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLinkSelectors(t *testing.T) {
+	mac1 := mustMAC(t, "00:00:00:00:00:01")
+	mac2 := mustMAC(t, "00:00:00:00:00:02")
+	name := "eth0"
+
+	cfg := host.Config{NetworkInterfaces: &[]*host.NetworkInterface{
+		{InterfaceName: &name, MACAddress: &mac1},
+		{InterfaceName: &name, MACAddress: &mac2},
+	}}
+
+	got := linkSelectors(&cfg)
+	want := []*host.LinkSelector{{MACAddress: mac1}, {MACAddress: mac2}}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestDeviceInfo(t *testing.T) {
+	sysfs := t.TempDir()
+	device := filepath.Join(sysfs, "devices", "pci0000:00", "0000:00:1f.6")
+
+	for _, dir := range []string{
+		device,
+		filepath.Join(sysfs, "bus", "pci", "drivers", "e1000e"),
+		filepath.Join(sysfs, "class", "net", "eno1"),
+		filepath.Join(sysfs, "class", "net", "tun0"),
+	} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for link, target := range map[string]string{
+		filepath.Join(sysfs, "class", "net", "eno1", "device"): "../../../devices/pci0000:00/0000:00:1f.6",
+		filepath.Join(device, "driver"):                        "../../../bus/pci/drivers/e1000e",
+		filepath.Join(device, "subsystem"):                     "../../../bus/pci",
+	} {
+		if err := os.Symlink(target, link); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	classNet := filepath.Join(sysfs, "class", "net")
+
+	if driver, pciPath := deviceInfo(classNet, "eno1"); driver != "e1000e" || pciPath != "0000:00:1f.6" {
+		t.Errorf("eno1: got %q, %q", driver, pciPath)
+	}
+
+	if driver, pciPath := deviceInfo(classNet, "tun0"); driver != "" || pciPath != "" {
+		t.Errorf("tun0: got %q, %q", driver, pciPath)
+	}
+}
+
+func TestWaitCarrier(t *testing.T) {
+	if !inNetns(t) {
+		return
+	}
+
+	for _, name := range []string{"a", "b"} {
+		veth := &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: name + "0"}, PeerName: name + "1"}
+		if err := netlink.LinkAdd(veth); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Only a0 gets carrier, as the peer of b0 stays down.
+	peer, err := netlink.LinkByName("a1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := netlink.LinkSetUp(peer); err != nil {
+		t.Fatal(err)
+	}
+
+	var candidates []linkInfo
+
+	for _, name := range []string{"b0", "a0"} {
+		link, err := netlink.LinkByName(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		candidates = append(candidates, linkInfo{link: link})
+	}
+
+	const timeout = 500 * time.Millisecond
+
+	start := time.Now()
+
+	got, err := waitCarrier(candidates, timeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 1 || got[0].Attrs().Name != "a0" {
+		t.Errorf("got %v, want only a0", got)
+	}
+
+	if time.Since(start) < timeout {
+		t.Error("expect to wait for the preferred link b0")
+	}
+
+	if _, err := waitCarrier(candidates[:1], 0); err == nil {
+		t.Error("expect an error without carrier")
+	}
+
+	// b0 gets carrier after a0, both are returned.
+	peer, err = netlink.LinkByName("b1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		time.Sleep(timeout / 5)
+
+		if err := netlink.LinkSetUp(peer); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	got, err = waitCarrier([]linkInfo{candidates[1], candidates[0]}, timeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 2 || got[0].Attrs().Name != "a0" || got[1].Attrs().Name != "b0" {
+		t.Errorf("got %v, want a0 and b0", got)
+	}
+}
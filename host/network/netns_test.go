@@ -0,0 +1,192 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package network
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"syscall"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+	"golang.org/x/sys/unix"
+	"system-transparency.org/stboot/host"
+)
+
+const netnsTestEnv = "STBOOT_NETNS_TEST"
+
+// inNetns re-runs the calling test in a new network namespace. It reports
+// whether the caller is running inside that namespace and should go on.
+func inNetns(t *testing.T) bool {
+	t.Helper()
+
+	if os.Getenv(netnsTestEnv) == t.Name() {
+		return true
+	}
+
+	if os.Geteuid() != 0 {
+		t.Skip("network namespaces require root")
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run", "^"+t.Name()+"$", "-test.v")
+	cmd.Env = append(os.Environ(), netnsTestEnv+"="+t.Name())
+	cmd.SysProcAttr = &syscall.SysProcAttr{Cloneflags: syscall.CLONE_NEWNET}
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("%v\n%s", err, out)
+	}
+
+	if bytes.Contains(out, []byte("--- SKIP")) {
+		t.Skipf("skipped in network namespace:\n%s", out)
+	}
+
+	return false
+}
+
+// router is the far end of a veth pair, living in its own network namespace.
+type router struct {
+	ns netns.NsHandle
+}
+
+// do runs f inside the router's network namespace. Sockets opened by f stay
+// in that namespace after do returns.
+func (r router) do(f func() error) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	origin, err := netns.Get()
+	if err != nil {
+		return err
+	}
+	defer origin.Close()
+
+	if err := netns.Set(r.ns); err != nil {
+		return err
+	}
+	defer netns.Set(origin) //nolint:errcheck
+
+	return f()
+}
+
+var (
+	routerLinkLocal = net.ParseIP("fe80::1")
+	routerIPv6      = net.ParseIP("2001:db8::1")
+	routerIPv4      = net.ParseIP("192.0.2.1")
+)
+
+// setupVeth creates the interface eth0 connected to a router.
+func setupVeth(t *testing.T) (netlink.Link, router) {
+	t.Helper()
+
+	runtime.LockOSThread()
+
+	origin, err := netns.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ns, err := netns.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := netns.Set(origin); err != nil {
+		t.Fatal(err)
+	}
+
+	runtime.UnlockOSThread()
+	origin.Close()
+	t.Cleanup(func() { ns.Close() })
+
+	veth := &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: "eth0"}, PeerName: "router0"}
+	if err := netlink.LinkAdd(veth); err != nil {
+		t.Fatal(err)
+	}
+
+	peer, err := netlink.LinkByName("router0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := netlink.LinkSetNsFd(peer, int(ns)); err != nil {
+		t.Fatal(err)
+	}
+
+	r := router{ns: ns}
+
+	err = r.do(func() error {
+		peer, err := netlink.LinkByName("router0")
+		if err != nil {
+			return err
+		}
+
+		for _, addr := range []string{"fe80::1/64", "2001:db8::1/64", "192.0.2.1/24"} {
+			a, err := netlink.ParseAddr(addr)
+			if err != nil {
+				return err
+			}
+
+			a.Flags = unix.IFA_F_NODAD
+			if err := netlink.AddrAdd(peer, a); err != nil {
+				return err
+			}
+		}
+
+		return netlink.LinkSetUp(peer)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	link, err := netlink.LinkByName("eth0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return link, r
+}
+
+func testConfig(mode host.IPAddrMode) *host.Config {
+	return &host.Config{IPAddrMode: &mode}
+}
+
+func hasAddr(t *testing.T, link netlink.Link, family int, match func(net.IP) bool) bool {
+	t.Helper()
+
+	addrs, err := netlink.AddrList(link, family)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, a := range addrs {
+		if match(a.IP) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hasDefaultRoute(t *testing.T, link netlink.Link, family int, gw net.IP) bool {
+	t.Helper()
+
+	routes, err := netlink.RouteList(link, family)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, r := range routes {
+		if r.Dst == nil && r.Gw.Equal(gw) {
+			return true
+		}
+	}
+
+	return false
+}
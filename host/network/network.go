@@ -5,7 +5,6 @@
 package network
 
 import (
-	"bytes"
 	"context"
 	"crypto/tls"
 	"crypto/x509"
@@ -33,12 +32,18 @@ const (
 	ErrScope                      sterror.Scope = "Network"
 	ErrOpConfigureStatic          sterror.Op    = "ConfigureStatic"
 	ErrOpConfigureDHCP            sterror.Op    = "ConfigureDHCP"
+	ErrOpConfigureDHCPv6          sterror.Op    = "ConfigureDHCPv6"
+	ErrOpConfigureSLAAC           sterror.Op    = "ConfigureSLAAC"
 	ErrOpConfigureBonding         sterror.Op    = "ConfigureBonding"
+	ErrOpConfigureVLAN            sterror.Op    = "ConfigureVLAN"
 	ErrOpSetDNSServer             sterror.Op    = "SetDNSServer"
 	ErrOpfindInterface            sterror.Op    = "findInterface"
 	ErrOpDownload                 sterror.Op    = "Download"
+	ErrOpReadState                sterror.Op    = "ReadState"
+	ErrOpSyncTime                 sterror.Op    = "SyncTime"
 	ErrInfoFailedForAllInterfaces               = "IP configuration failed for all interfaces"
 	ErrInfoFoundNoInterfaces                    = "found no interfaces"
+	ErrInfoNoCarrier                            = "no selected interface has carrier"
 )
 
 // Errors which may be raised and wrapped in this package.
@@ -46,26 +51,98 @@ var (
 	ErrNetworkConfiguration = errors.New("failed to configure network")
 	ErrDownload             = errors.New("failed to download")
 	ErrBond                 = errors.New("failed to setup bonding interface")
+	ErrVLAN                 = errors.New("failed to setup VLAN interface")
+	ErrIPv6Timeout          = errors.New("timeout waiting for IPv6 configuration")
 )
 
 const (
 	entropyAvail       = "/proc/sys/kernel/random/entropy_avail"
+	resolvConf         = "/etc/resolv.conf"
 	interfaceUpTimeout = 6 * time.Second
 )
 
 func SetupNetworkInterface(cfg *host.Config) error {
-	switch *cfg.IPAddrMode {
-	case host.IPStatic:
-		if err := configureStatic(cfg); err != nil {
-			return err
+	_, err := SetupNetwork(cfg)
+
+	return err
+}
+
+// SetupNetwork is like SetupNetworkInterface and returns the resulting
+// network state. The state is nil if networking is not configured. On
+// failure, the interfaces and addresses set up so far are removed again.
+//
+//nolint:nonamedreturns,cyclop
+func SetupNetwork(cfg *host.Config) (_ *State, setupErr error) {
+	if *cfg.IPAddrMode == host.IPUnset {
+		return nil, nil
+	}
+
+	links, err := configLinks(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.BondingMode != host.BondingUnset {
+		bond := links[0]
+
+		defer func() {
+			if setupErr != nil {
+				removeLink(bond)
+			}
+		}()
+	}
+
+	// keep is the VLAN interface in use, once setup succeeded.
+	var keep netlink.Link
+
+	if cfg.VLANID != nil {
+		links, err = setupVLANs(links, int(*cfg.VLANID))
+		if err != nil {
+			return nil, err
 		}
-	case host.IPDynamic:
-		if err := configureDHCP(cfg); err != nil {
-			return err
+
+		vlans := links
+
+		defer func() { removeVLANs(vlans, keep) }()
+	}
+
+	// configured are the links which got addresses.
+	var configured []netlink.Link
+
+	defer func() {
+		if setupErr != nil {
+			removeAddrs(configured)
 		}
-	case host.IPUnset:
-	default:
-		return sterror.E(ErrScope, ErrOpfindInterface, ErrNetworkConfiguration, "IP addr mode is not set")
+	}()
+
+	static, staticV6 := staticConfigs(cfg)
+
+	link, err := configureMode(*cfg.IPAddrMode, static, links)
+	if err == nil {
+		configured = append(configured, link)
+	}
+
+	// For dual-stack, IPv6 is set up on the selected links whether or not IPv4
+	// succeeded, preferring the interface that got IPv4. Either family is
+	// enough to continue.
+	if cfg.IPv6AddrMode != nil && *cfg.IPv6AddrMode != host.IPUnset {
+		link6, err6 := configureMode(*cfg.IPv6AddrMode, staticV6, preferLink(links, link))
+		if err6 == nil && (link == nil || link6.Attrs().Index != link.Attrs().Index) {
+			configured = append(configured, link6)
+		}
+
+		switch {
+		case err != nil && err6 == nil:
+			stlog.Warn("IPv4 configuration failed, continuing with IPv6: %v", err)
+
+			link, err = link6, nil
+		case err == nil && err6 != nil:
+			stlog.Warn("IPv6 configuration failed, continuing with IPv4: %v", err6)
+		}
+	}
+
+	if err != nil {
+		return nil, err
 	}
 
 	if cfg.DNSServer != nil {
@@ -76,13 +153,163 @@ func SetupNetworkInterface(cfg *host.Config) error {
 		}
 
 		if err := SetDNSServer(*cfg.DNSServer); err != nil {
-			return fmt.Errorf("set DNS Server: %w", err)
+			return nil, fmt.Errorf("set DNS Server: %w", err)
+		}
+	}
+
+	state, err := readState(cfg, link, resolvConf)
+	if err != nil {
+		return nil, sterror.E(ErrScope, ErrOpReadState, ErrNetworkConfiguration, err.Error())
+	}
+
+	keep = link
+	state.configured = configured
+
+	return state, nil
+}
+
+// TeardownNetwork removes the interfaces and addresses SetupNetwork set up
+// for state, so networking can be set up again.
+func TeardownNetwork(state *State) error {
+	if state == nil {
+		return nil
+	}
+
+	// Deleting a VLAN or bonding interface drops its addresses as well.
+	if state.VLAN == nil && state.Bond == nil {
+		removeAddrs(state.configured)
+	}
+
+	if state.VLAN != nil {
+		link, err := netlink.LinkByName(state.Interface)
+		if err != nil {
+			return sterror.E(ErrScope, ErrOpConfigureVLAN, ErrVLAN, err.Error())
+		}
+
+		if err := netlink.LinkDel(link); err != nil {
+			return sterror.E(ErrScope, ErrOpConfigureVLAN, ErrVLAN, err.Error())
+		}
+	}
+
+	if state.Bond != nil {
+		link, err := netlink.LinkByName(state.Bond.Name)
+		if err != nil {
+			return sterror.E(ErrScope, ErrOpConfigureBonding, ErrBond, err.Error())
+		}
+
+		if err := netlink.LinkDel(link); err != nil {
+			return sterror.E(ErrScope, ErrOpConfigureBonding, ErrBond, err.Error())
 		}
 	}
 
 	return nil
 }
 
+// removeLink deletes the interface link.
+func removeLink(link netlink.Link) {
+	if err := netlink.LinkDel(link); err != nil {
+		stlog.Debug("%s: removal failed: %v", link.Attrs().Name, err)
+	}
+}
+
+// removeAddrs deletes the global addresses of links. Routes using them are
+// dropped by the kernel.
+func removeAddrs(links []netlink.Link) {
+	for _, link := range links {
+		addrs, err := netlink.AddrList(link, netlink.FAMILY_ALL)
+		if err != nil {
+			stlog.Debug("%s: listing addresses failed: %v", link.Attrs().Name, err)
+
+			continue
+		}
+
+		for i := range addrs {
+			if !isGlobal(addrs[i].IP) {
+				continue
+			}
+
+			if err := netlink.AddrDel(link, &addrs[i]); err != nil {
+				stlog.Debug("%s: removing address %s failed: %v", link.Attrs().Name, addrs[i].IPNet, err)
+			}
+		}
+	}
+}
+
+// preferLink returns links with link moved to the front, if it is among them.
+func preferLink(links []netlink.Link, link netlink.Link) []netlink.Link {
+	if link == nil {
+		return links
+	}
+
+	ordered := []netlink.Link{link}
+
+	for _, l := range links {
+		if l.Attrs().Index != link.Attrs().Index {
+			ordered = append(ordered, l)
+		}
+	}
+
+	return ordered
+}
+
+// staticConfig is the addressing applied by configureStatic.
+type staticConfig struct {
+	addrs   []*netlink.Addr
+	gateway net.IP
+	routes  []*host.Route
+}
+
+// staticConfigs returns the static addressing for IPAddrMode and IPv6AddrMode
+// of cfg.
+func staticConfigs(cfg *host.Config) (staticConfig, staticConfig) {
+	var static, staticV6 staticConfig
+
+	if cfg.HostIP != nil {
+		static.addrs = append(static.addrs, cfg.HostIP)
+	}
+
+	if cfg.AdditionalHostIPs != nil {
+		static.addrs = append(static.addrs, *cfg.AdditionalHostIPs...)
+	}
+
+	if cfg.DefaultGateway != nil {
+		static.gateway = *cfg.DefaultGateway
+	}
+
+	if cfg.Routes != nil {
+		static.routes = *cfg.Routes
+	}
+
+	if cfg.HostIPv6 != nil {
+		staticV6.addrs = []*netlink.Addr{cfg.HostIPv6}
+	}
+
+	if cfg.DefaultGatewayV6 != nil {
+		staticV6.gateway = *cfg.DefaultGatewayV6
+	}
+
+	return static, staticV6
+}
+
+// configureMode sets up one of links according to mode and returns it.
+func configureMode(mode host.IPAddrMode, static staticConfig, links []netlink.Link) (netlink.Link, error) {
+	switch mode {
+	case host.IPStatic:
+		return configureStatic(links, static)
+	case host.IPDynamic:
+		return configureDHCP(links, false)
+	case host.IPSLAAC:
+		return configureSLAAC(links)
+	case host.IPDHCPv6:
+		return configureDHCP(links, true)
+	default:
+		return nil, sterror.E(ErrScope, ErrOpfindInterface, ErrNetworkConfiguration, "IP addr mode is not set")
+	}
+}
+
+// ConfigureBondInterface bonds the network interfaces of cfg. cfg is left
+// unchanged, so the setup can be repeated. On failure, the bonding interface
+// is removed again.
 func ConfigureBondInterface(cfg *host.Config) (*netlink.Bond, error) {
 	bond, err := SetupBondInterface(*cfg.BondName, netlink.StringToBondMode(cfg.BondingMode.String()))
 	if err != nil {
@@ -90,135 +317,238 @@ func ConfigureBondInterface(cfg *host.Config) (*netlink.Bond, error) {
 	}
 
 	if err := SetBonded(bond, *cfg.NetworkInterfaces); err != nil {
+		removeLink(bond)
+
 		return nil, err
 	}
 
-	*cfg.NetworkInterfaces = append(*cfg.NetworkInterfaces,
-		&host.NetworkInterface{
-			InterfaceName: &bond.Name,
-			MACAddress:    &bond.HardwareAddr})
-
 	return bond, nil
 }
 
-//nolint:funlen,cyclop
-func configureStatic(cfg *host.Config) error {
-	var links []netlink.Link
+// setupVLANs creates a VLAN interface with the given 802.1Q id on top of each
+// of links and returns the VLAN interfaces.
+func setupVLANs(links []netlink.Link, id int) ([]netlink.Link, error) {
+	vlans := make([]netlink.Link, 0, len(links))
+	taken := make(map[string]bool, len(links))
 
-	var err error
+	for _, parent := range links {
+		name := vlanName(parent.Attrs().Name, id)
 
-	stlog.Info("Setup network interface with static IP: " + cfg.HostIP.String())
+		// Shortened names of different parents may collide.
+		if taken[name] {
+			name = vlanName("vlan"+strconv.Itoa(parent.Attrs().Index), id)
+		}
 
-	//nolint:nestif
-	if cfg.BondingMode != host.BondingUnset {
-		bond, err := ConfigureBondInterface(cfg)
-		if err != nil {
-			return sterror.E(ErrScope, ErrOpConfigureBonding, ErrBond, err.Error())
+		if taken[name] {
+			stlog.Debug("%s: VLAN setup failed: name of %s already in use", name, parent.Attrs().Name)
+
+			continue
 		}
-		// If we use the bond interface we don't know the MAC address the kernel gives it
-		// ignore the original device and replace with our bonding interface
-		links = []netlink.Link{bond}
-	} else {
-		if cfg.NetworkInterfaces != nil {
-			for _, iface := range *cfg.NetworkInterfaces {
-				links, err = findInterfaces(iface.MACAddress)
-				if err != nil {
-					stlog.Debug("findInterface: finding interface failed: %v", err)
-
-					continue
-				}
-			}
-		} else {
-			links, err = findInterfaces(nil)
-			if err != nil {
-				stlog.Debug("findInterface: finding interface failed: %v", err)
+
+		stlog.Info("Setup VLAN interface %s", name)
+
+		if err := netlink.LinkSetUp(parent); err != nil {
+			stlog.Debug("%s: VLAN setup failed: %v", name, err)
+
+			continue
+		}
+
+		vlan := &netlink.Vlan{
+			LinkAttrs: netlink.LinkAttrs{Name: name, ParentIndex: parent.Attrs().Index},
+			VlanId:    id,
+		}
+		if err := netlink.LinkAdd(vlan); err != nil {
+			stlog.Debug("%s: VLAN setup failed: %v", name, err)
+
+			continue
+		}
+
+		link, err := netlink.LinkByName(name)
+		if err != nil {
+			stlog.Debug("%s: VLAN setup failed: %v", name, err)
+
+			if err := netlink.LinkDel(vlan); err != nil {
+				stlog.Debug("%s: VLAN removal failed: %v", name, err)
 			}
+
+			continue
 		}
 
-		if len(links) == 0 {
-			return sterror.E(ErrScope, ErrOpConfigureDHCP, ErrNetworkConfiguration, err.Error())
+		taken[name] = true
+		vlans = append(vlans, link)
+	}
+
+	if len(vlans) == 0 {
+		return nil, sterror.E(ErrScope, ErrOpConfigureVLAN, ErrVLAN, ErrInfoFailedForAllInterfaces)
+	}
+
+	return vlans, nil
+}
+
+// removeVLANs deletes the VLAN interfaces vlans, except keep.
+func removeVLANs(vlans []netlink.Link, keep netlink.Link) {
+	for _, vlan := range vlans {
+		if keep != nil && vlan.Attrs().Index == keep.Attrs().Index {
+			continue
+		}
+
+		if err := netlink.LinkDel(vlan); err != nil {
+			stlog.Debug("%s: VLAN removal failed: %v", vlan.Attrs().Name, err)
 		}
 	}
+}
+
+// vlanName returns the conventional name parent.id, shortening parent to fit
+// the kernel's limit on interface names.
+func vlanName(parent string, id int) string {
+	const maxLen = 15
+
+	suffix := "." + strconv.Itoa(id)
+	if len(parent)+len(suffix) > maxLen {
+		parent = parent[:maxLen-len(suffix)]
+	}
+
+	return parent + suffix
+}
+
+func configureStatic(links []netlink.Link, static staticConfig) (netlink.Link, error) {
+	for _, addr := range static.addrs {
+		stlog.Info("Setup network interface with static IP: " + addr.String())
+	}
 
 	for _, link := range links {
-		if err = netlink.AddrAdd(link, cfg.HostIP); err != nil {
+		if err := applyStatic(link, static); err != nil {
 			stlog.Debug("%s: IP config failed: %v", link.Attrs().Name, err)
 
 			continue
 		}
 
-		if err = netlink.LinkSetUp(link); err != nil {
-			stlog.Debug("%s: IP config failed: %v", link.Attrs().Name, err)
+		stlog.Info("%s: IP configuration successful", link.Attrs().Name)
 
-			continue
+		return link, nil
+	}
+
+	return nil, sterror.E(ErrScope, ErrOpConfigureStatic, ErrNetworkConfiguration, ErrInfoFailedForAllInterfaces)
+}
+
+// applyStatic adds the addresses, the default route and further routes of
+// static to link. On failure, everything added so far is removed again.
+//
+//nolint:cyclop
+func applyStatic(link netlink.Link, static staticConfig) (err error) {
+	var undo []func() error
+
+	defer func() {
+		if err == nil {
+			return
 		}
 
-		if err != nil {
-			stlog.Debug("%s: IP config failed: %v", link.Attrs().Name, err)
+		for i := len(undo) - 1; i >= 0; i-- {
+			if uerr := undo[i](); uerr != nil {
+				stlog.Debug("%s: rollback failed: %v", link.Attrs().Name, uerr)
+			}
+		}
+	}()
 
-			continue
+	for _, addr := range static.addrs {
+		addr := addr
+		if err := netlink.AddrAdd(link, addr); err != nil {
+			return fmt.Errorf("add address %s: %w", addr, err)
 		}
 
-		r := &netlink.Route{
+		undo = append(undo, func() error { return netlink.AddrDel(link, addr) })
+	}
+
+	if err := netlink.LinkSetUp(link); err != nil {
+		return err
+	}
+
+	// IPv6 addresses are unusable until duplicate address detection is done.
+	for _, addr := range static.addrs {
+		if addr.IP.To4() == nil {
+			if err := waitIPv6Addr(link, addr.IP.Equal); err != nil {
+				return fmt.Errorf("address %s: %w", addr, err)
+			}
+		}
+	}
+
+	routes := make([]*netlink.Route, 0, len(static.routes)+1)
+
+	if static.gateway != nil {
+		routes = append(routes, &netlink.Route{
 			LinkIndex: link.Attrs().Index,
-			Gw:        *cfg.DefaultGateway,
+			Gw:        static.gateway,
+		})
+	}
+
+	for _, r := range static.routes {
+		route, err := netlinkRoute(link, r)
+		if err != nil {
+			return err
 		}
-		if err = netlink.RouteAdd(r); err != nil {
-			stlog.Debug("%s: IP config failed: %v", link.Attrs().Name, err)
 
-			continue
+		routes = append(routes, route)
+	}
+
+	for _, route := range routes {
+		route := route
+		if err := netlink.RouteAdd(route); err != nil {
+			return fmt.Errorf("add route %s: %w", route, err)
 		}
 
-		stlog.Info("%s: IP configuration successful", link.Attrs().Name)
+		undo = append(undo, func() error { return netlink.RouteDel(route) })
+	}
 
-		return nil
+	return nil
+}
+
+// netlinkRoute converts r to a route on link, or on r.Link if set.
+func netlinkRoute(link netlink.Link, r *host.Route) (*netlink.Route, error) {
+	if r.Link != "" {
+		var err error
+		if link, err = netlink.LinkByName(r.Link); err != nil {
+			return nil, fmt.Errorf("route %s: %w", r.Destination, err)
+		}
+	}
+
+	route := &netlink.Route{
+		LinkIndex: link.Attrs().Index,
+		Dst:       r.Destination,
+		Gw:        r.Gateway,
+		Priority:  int(r.Metric),
 	}
 
-	return sterror.E(ErrScope, ErrOpConfigureStatic, ErrNetworkConfiguration, ErrInfoFailedForAllInterfaces)
+	if r.Gateway == nil {
+		route.Scope = netlink.SCOPE_LINK
+	}
+
+	return route, nil
 }
 
-//nolint:funlen,cyclop
-func configureDHCP(cfg *host.Config) error {
+// configureDHCP requests a DHCPv4 lease, or a DHCPv6 lease if ipv6 is set.
+// DHCPv6 does not provide routes, so the default route is taken from router
+// advertisements.
+//
+//nolint:funlen
+func configureDHCP(links []netlink.Link, ipv6 bool) (netlink.Link, error) {
 	const (
 		retries       = 4
 		linkUpTimeout = 30 * time.Second
 	)
 
-	var (
-		links []netlink.Link
-		err   error
-	)
-
-	stlog.Info("Configure network interface using DHCP")
+	op := ErrOpConfigureDHCP
+	if ipv6 {
+		op = ErrOpConfigureDHCPv6
 
-	//nolint:nestif
-	if cfg.BondingMode != host.BondingUnset {
-		bond, err := ConfigureBondInterface(cfg)
-		if err != nil {
-			return sterror.E(ErrScope, ErrOpConfigureDHCP, ErrNetworkConfiguration, err.Error())
-		}
+		stlog.Info("Configure network interface using DHCPv6")
 
-		links = []netlink.Link{bond}
-	} else {
-		if cfg.NetworkInterfaces != nil {
-			for _, iface := range *cfg.NetworkInterfaces {
-				links, err = findInterfaces(iface.MACAddress)
-				if err != nil {
-					stlog.Debug("findInterface: finding interface failed: %v", err)
-
-					continue
-				}
-			}
-		} else {
-			links, err = findInterfaces(nil)
-			if err != nil {
-				stlog.Debug("findInterface: finding interface failed: %v", err)
+		for _, link := range links {
+			if err := acceptRA(link, false); err != nil {
+				stlog.Debug("%s: %v", link.Attrs().Name, err)
 			}
 		}
-
-		if len(links) == 0 {
-			return sterror.E(ErrScope, ErrOpConfigureDHCP, ErrNetworkConfiguration, err.Error())
-		}
+	} else {
+		stlog.Info("Configure network interface using DHCP")
 	}
 
 	var level dhclient.LogLevel
@@ -234,30 +564,41 @@ func configureDHCP(cfg *host.Config) error {
 		LogLevel: level,
 	}
 
-	r := dhclient.SendRequests(context.TODO(), links, true, false, config, linkUpTimeout)
+	r := dhclient.SendRequests(context.TODO(), links, !ipv6, ipv6, config, linkUpTimeout)
 	for result := range r {
+		name := result.Interface.Attrs().Name
+
 		if result.Err != nil {
-			stlog.Debug("%s: DHCP response error: %v", result.Interface.Attrs().Name, result.Err)
+			stlog.Debug("%s: DHCP response error: %v", name, result.Err)
 
 			continue
 		}
 
-		err = result.Lease.Configure()
-		if err != nil {
-			stlog.Debug("%s: DHCP configuration error: %v", result.Interface.Attrs().Name, err)
-		} else {
-			stlog.Info("DHCP successful - %s", result.Interface.Attrs().Name)
+		if err := result.Lease.Configure(); err != nil {
+			stlog.Debug("%s: DHCP configuration error: %v", name, err)
 
-			return nil
+			continue
 		}
+
+		if ipv6 {
+			if err := waitDefaultRoute6(result.Interface); err != nil {
+				stlog.Debug("%s: DHCPv6 configuration error: %v", name, err)
+
+				continue
+			}
+		}
+
+		stlog.Info("DHCP successful - %s", name)
+
+		return result.Interface, nil
 	}
 
-	return sterror.E(ErrScope, ErrOpConfigureDHCP, ErrNetworkConfiguration, ErrInfoFailedForAllInterfaces)
+	return nil, sterror.E(ErrScope, op, ErrNetworkConfiguration, ErrInfoFailedForAllInterfaces)
 }
 
 // SetDNSServer writes adresses to /etc/resolv.conf file.
 func SetDNSServer(addresses []*net.IP) error {
-	return setDNSServer(addresses, "/etc/resolv.conf")
+	return setDNSServer(addresses, resolvConf)
 }
 
 func setDNSServer(addresses []*net.IP, out string) error {
@@ -275,58 +616,6 @@ func setDNSServer(addresses []*net.IP, out string) error {
 	return nil
 }
 
-//nolint:cyclop
-func findInterfaces(mac *net.HardwareAddr) ([]netlink.Link, error) {
-	interfaces, err := net.Interfaces()
-	if err != nil {
-		return nil, sterror.E(ErrScope, ErrOpfindInterface, err.Error())
-	}
-
-	if len(interfaces) == 0 {
-		return nil, sterror.E(ErrScope, ErrOpfindInterface, ErrInfoFoundNoInterfaces)
-	}
-
-	if mac != nil {
-		stlog.Info("Looking for specific NIC with MAC addr. %s", mac.String())
-	}
-
-	links := make([]netlink.Link, 0, len(interfaces))
-
-	for _, iface := range interfaces {
-		stlog.Debug("Found interface %s", iface.Name)
-		stlog.Debug("    MTU: %d Hardware Addr: %s", iface.MTU, iface.HardwareAddr.String())
-		stlog.Debug("    Flags: %v", iface.Flags)
-		// skip loopback
-		if iface.Flags&net.FlagLoopback != 0 || bytes.Equal(iface.HardwareAddr, nil) {
-			continue
-		}
-
-		link, err := netlink.LinkByName(iface.Name)
-		if err != nil {
-			stlog.Debug("%v", err)
-		}
-
-		if mac != nil && bytes.Equal(*mac, iface.HardwareAddr) {
-			stlog.Debug("Got it!")
-
-			return []netlink.Link{link}, nil
-		}
-
-		links = append(links, link)
-	}
-
-	if mac != nil && !bytes.Equal(*mac, links[0].Attrs().HardwareAddr) {
-		stlog.Info("No NIC with MAC addr. %s", mac.String())
-		stlog.Info("Try to use an existing NIC")
-	}
-
-	if len(links) == 0 {
-		return nil, sterror.E(ErrScope, ErrOpfindInterface, ErrInfoFoundNoInterfaces)
-	}
-
-	return links, nil
-}
-
 // Download sets up a HTTP client and downloads sources.
 //
 //nolint:funlen
@@ -5,10 +5,17 @@
 package network
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net"
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+	"system-transparency.org/stboot/host"
 )
 
 func TestSetDNSServer(t *testing.T) {
@@ -32,3 +39,337 @@ func TestSetDNSServer(t *testing.T) {
 		t.Errorf("got %q, want %q", got, want)
 	}
 }
+
+func TestVLANName(t *testing.T) {
+	tests := []struct {
+		parent string
+		id     int
+		want   string
+	}{
+		{"eth0", 100, "eth0.100"},
+		{"enp0s31f6", 4094, "enp0s31f6.4094"},
+		{"enx001122334455", 7, "enx0011223344.7"},
+	}
+
+	for _, tt := range tests {
+		if got := vlanName(tt.parent, tt.id); got != tt.want {
+			t.Errorf("vlanName(%q, %d) = %q, want %q", tt.parent, tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestSetupVLAN(t *testing.T) {
+	if !inNetns(t) {
+		return
+	}
+
+	_, r := setupVeth(t)
+
+	var ln net.Listener
+
+	err := r.do(func() error {
+		parent, err := netlink.LinkByName("router0")
+		if err != nil {
+			return err
+		}
+
+		vlan := &netlink.Vlan{
+			LinkAttrs: netlink.LinkAttrs{Name: "router0.100", ParentIndex: parent.Attrs().Index},
+			VlanId:    100,
+		}
+		if err := netlink.LinkAdd(vlan); err != nil {
+			return fmt.Errorf("add VLAN: %w", err)
+		}
+
+		addr, err := netlink.ParseAddr("198.51.100.1/24")
+		if err != nil {
+			return err
+		}
+
+		if err := netlink.AddrAdd(vlan, addr); err != nil {
+			return err
+		}
+
+		if err := netlink.LinkSetUp(vlan); err != nil {
+			return err
+		}
+
+		ln, err = net.Listen("tcp", "198.51.100.1:0")
+
+		return err
+	})
+	if errors.Is(err, unix.EOPNOTSUPP) {
+		t.Skip("kernel lacks 802.1Q support")
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	go func() {
+		if conn, err := ln.Accept(); err == nil {
+			conn.Close()
+		}
+	}()
+
+	id := uint16(100)
+	gw := net.ParseIP("198.51.100.1")
+	cfg := testConfig(host.IPStatic)
+	cfg.HostIP, _ = netlink.ParseAddr("198.51.100.2/24")
+	cfg.DefaultGateway = &gw
+	cfg.VLANID = &id
+
+	state, err := SetupNetwork(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := netlink.LinkByName("eth0.100"); err != nil {
+		t.Fatalf("VLAN interface: %v", err)
+	}
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("tagged traffic: %v", err)
+	}
+	conn.Close()
+
+	if err := TeardownNetwork(state); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := netlink.LinkByName("eth0.100"); err == nil {
+		t.Error("VLAN interface not removed")
+	}
+}
+
+func TestSetupVLANRollback(t *testing.T) {
+	if !inNetns(t) {
+		return
+	}
+
+	setupVeth(t)
+
+	// The gateway is not on-link, so the setup fails.
+	id := uint16(100)
+	gw := net.ParseIP("10.9.9.9")
+	cfg := testConfig(host.IPStatic)
+	cfg.HostIP, _ = netlink.ParseAddr("198.51.100.2/24")
+	cfg.DefaultGateway = &gw
+	cfg.VLANID = &id
+
+	err := SetupNetworkInterface(cfg)
+	if errors.Is(err, ErrVLAN) {
+		t.Skip("kernel lacks 802.1Q support")
+	}
+
+	if !errors.Is(err, ErrNetworkConfiguration) {
+		t.Fatalf("got %v, want %v", err, ErrNetworkConfiguration)
+	}
+
+	if _, err := netlink.LinkByName("eth0.100"); err == nil {
+		t.Error("VLAN interface not removed")
+	}
+}
+
+func TestSetupVLANNameCollision(t *testing.T) {
+	if !inNetns(t) {
+		return
+	}
+
+	var links []netlink.Link
+
+	for i, name := range []string{"enx001122334455", "enx001122334466"} {
+		veth := &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: name}, PeerName: fmt.Sprintf("peer%d", i)}
+		if err := netlink.LinkAdd(veth); err != nil {
+			t.Fatal(err)
+		}
+
+		link, err := netlink.LinkByName(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		links = append(links, link)
+	}
+
+	vlans, err := setupVLANs(links, 7)
+	if errors.Is(err, ErrVLAN) {
+		t.Skip("kernel lacks 802.1Q support")
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(vlans) != 2 || vlans[0].Attrs().Name == vlans[1].Attrs().Name {
+		t.Fatalf("got %d VLAN interfaces, want 2 with distinct names", len(vlans))
+	}
+
+	removeVLANs(vlans, vlans[0])
+
+	if _, err := netlink.LinkByName(vlans[0].Attrs().Name); err != nil {
+		t.Errorf("kept VLAN interface removed: %v", err)
+	}
+
+	if _, err := netlink.LinkByName(vlans[1].Attrs().Name); err == nil {
+		t.Error("VLAN interface not removed")
+	}
+}
+
+func staticRouteConfig(t *testing.T, routes ...string) *host.Config {
+	t.Helper()
+
+	cfg := testConfig(host.IPStatic)
+	cfg.HostIP, _ = netlink.ParseAddr("192.0.2.2/24")
+	cfg.DefaultGateway = &routerIPv4
+
+	extra, _ := netlink.ParseAddr("2001:db8::2/64")
+	cfg.AdditionalHostIPs = &[]*netlink.Addr{extra}
+
+	var rs []*host.Route
+
+	for _, r := range routes {
+		var route host.Route
+		if err := json.Unmarshal([]byte(r), &route); err != nil {
+			t.Fatal(err)
+		}
+
+		rs = append(rs, &route)
+	}
+
+	cfg.Routes = &rs
+
+	return cfg
+}
+
+func TestSetupStaticRoutes(t *testing.T) {
+	if !inNetns(t) {
+		return
+	}
+
+	link, _ := setupVeth(t)
+
+	cfg := staticRouteConfig(t,
+		`{"destination":"203.0.113.0/24", "gateway":"192.0.2.254", "metric":10}`,
+		`{"destination":"198.51.100.0/24", "link":"eth0"}`)
+
+	if err := SetupNetworkInterface(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if !hasAddr(t, link, netlink.FAMILY_V6, net.ParseIP("2001:db8::2").Equal) {
+		t.Error("additional address not configured")
+	}
+
+	if !hasDefaultRoute(t, link, netlink.FAMILY_V4, routerIPv4) {
+		t.Error("default route not configured")
+	}
+
+	routes, err := netlink.RouteList(link, netlink.FAMILY_V4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]bool{"203.0.113.0/24 192.0.2.254 10": true, "198.51.100.0/24 <nil> 0": true}
+
+	for _, r := range routes {
+		if r.Dst != nil {
+			delete(want, fmt.Sprintf("%s %v %d", r.Dst, r.Gw, r.Priority))
+		}
+	}
+
+	if len(want) != 0 {
+		t.Errorf("routes missing: %v, got %v", want, routes)
+	}
+}
+
+func TestSetupStaticRollback(t *testing.T) {
+	if !inNetns(t) {
+		return
+	}
+
+	link, _ := setupVeth(t)
+
+	// The gateway is not on-link, so adding the route fails.
+	cfg := staticRouteConfig(t, `{"destination":"203.0.113.0/24", "gateway":"10.9.9.9"}`)
+
+	if err := SetupNetworkInterface(cfg); !errors.Is(err, ErrNetworkConfiguration) {
+		t.Fatalf("got %v, want %v", err, ErrNetworkConfiguration)
+	}
+
+	if hasAddr(t, link, netlink.FAMILY_ALL, func(ip net.IP) bool { return ip.IsGlobalUnicast() }) {
+		t.Error("addresses not rolled back")
+	}
+
+	if hasDefaultRoute(t, link, netlink.FAMILY_V4, routerIPv4) {
+		t.Error("default route not rolled back")
+	}
+}
+
+func TestTeardownStatic(t *testing.T) {
+	if !inNetns(t) {
+		return
+	}
+
+	link, _ := setupVeth(t)
+	cfg := staticRouteConfig(t)
+
+	for i := 0; i < 2; i++ {
+		state, err := SetupNetwork(cfg)
+		if err != nil {
+			t.Fatalf("setup %d: %v", i, err)
+		}
+
+		if err := TeardownNetwork(state); err != nil {
+			t.Fatalf("teardown %d: %v", i, err)
+		}
+
+		if hasAddr(t, link, netlink.FAMILY_ALL, isGlobal) {
+			t.Errorf("teardown %d: addresses not removed", i)
+		}
+	}
+}
+
+func TestSetupBond(t *testing.T) {
+	if !inNetns(t) {
+		return
+	}
+
+	setupVeth(t)
+
+	name := "eth0"
+	bondName := "bond0"
+	cfg := staticRouteConfig(t)
+	cfg.BondingMode = host.BondingActiveBackup
+	cfg.BondName = &bondName
+	cfg.NetworkInterfaces = &[]*host.NetworkInterface{{InterfaceName: &name}}
+
+	for i := 0; i < 2; i++ {
+		state, err := SetupNetwork(cfg)
+		if errors.Is(err, ErrBond) && i == 0 {
+			t.Skipf("kernel lacks bonding support: %v", err)
+		}
+
+		if err != nil {
+			t.Fatalf("setup %d: %v", i, err)
+		}
+
+		if state.Bond == nil || state.Interface != bondName {
+			t.Errorf("setup %d: got state %+v, want bond %s", i, state, bondName)
+		}
+
+		if len(*cfg.NetworkInterfaces) != 1 {
+			t.Errorf("setup %d: network interfaces of config changed to %d entries", i, len(*cfg.NetworkInterfaces))
+		}
+
+		if err := TeardownNetwork(state); err != nil {
+			t.Fatalf("teardown %d: %v", i, err)
+		}
+
+		if _, err := netlink.LinkByName(bondName); err == nil {
+			t.Errorf("teardown %d: bond interface not removed", i)
+		}
+	}
+}
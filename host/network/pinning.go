@@ -0,0 +1,152 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package network
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"system-transparency.org/stboot/trust"
+)
+
+var (
+	ErrPinMismatch  = errors.New("no pinned public key")
+	ErrPinTransport = errors.New("TLS pins require the transport of NewHTTPClient")
+)
+
+// SPKIHash returns the SHA-256 hash of the SubjectPublicKeyInfo of cert.
+func SPKIHash(cert *x509.Certificate) []byte {
+	hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+
+	return hash[:]
+}
+
+// UsePins makes h check the public keys of the servers pinned in pins. Servers
+// without a pin are verified as before. Pins apply to host names only, since
+// TLS does not reveal IP addresses of servers to the verification.
+func (h *HTTPClient) UsePins(pins []trust.TLSPin) error {
+	transport, ok := h.transport()
+	if !ok || transport.TLSClientConfig == nil {
+		return ErrPinTransport
+	}
+
+	if len(pins) == 0 {
+		return nil
+	}
+
+	byHost := make(map[string]trust.TLSPin, len(pins))
+	for _, pin := range pins {
+		byHost[strings.ToLower(pin.Host)] = pin
+	}
+
+	verify := transport.TLSClientConfig.VerifyConnection
+	transport.TLSClientConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+		if verify != nil {
+			if err := verify(cs); err != nil {
+				return err
+			}
+		}
+
+		pin, pinned := byHost[strings.ToLower(cs.ServerName)]
+		if !pinned {
+			return nil
+		}
+
+		// Chains are not verified with InsecureSkipVerify.
+		chains := cs.VerifiedChains
+		if len(chains) == 0 {
+			chains = [][]*x509.Certificate{cs.PeerCertificates}
+		}
+
+		for _, chain := range chains {
+			if err := checkPin(pin, chain); err == nil {
+				return nil
+			}
+		}
+
+		return checkPin(pin, chains[0])
+	}
+
+	pinned := &pinTransport{base: h.HTTPClient.Transport, pins: byHost}
+	if pinned.base == nil {
+		pinned.base = http.DefaultTransport
+	}
+
+	h.HTTPClient.Transport = pinned
+
+	return nil
+}
+
+// pinTransport sends requests to hosts pinned with trust.TLSPin.PinOnly via
+// a transport without chain validation.
+type pinTransport struct {
+	base http.RoundTripper
+	pins map[string]trust.TLSPin
+
+	once    sync.Once
+	pinOnly http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (p *pinTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	pin, ok := p.pins[strings.ToLower(req.URL.Hostname())]
+	if !ok || !pin.PinOnly {
+		return p.base.RoundTrip(req)
+	}
+
+	// Clone on first use to pick up later changes, e.g. a proxy.
+	p.once.Do(func() {
+		transport, ok := p.base.(*http.Transport)
+		if !ok {
+			return
+		}
+
+		transport = transport.Clone()
+		transport.TLSClientConfig.InsecureSkipVerify = true //nolint:gosec
+		transport.TLSClientConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+			pin, ok := p.pins[strings.ToLower(cs.ServerName)]
+			if !ok || !pin.PinOnly {
+				return fmt.Errorf("%w for %q", ErrPinMismatch, cs.ServerName)
+			}
+
+			return checkPin(pin, cs.PeerCertificates[:1])
+		}
+		p.pinOnly = transport
+	})
+
+	if p.pinOnly == nil {
+		return nil, ErrPinTransport
+	}
+
+	return p.pinOnly.RoundTrip(req)
+}
+
+// checkPin returns an error wrapping ErrPinMismatch, if no certificate of
+// chain has a key pinned in pin. The error lists the hashes of the chain.
+func checkPin(pin trust.TLSPin, chain []*x509.Certificate) error {
+	got := make([]string, 0, len(chain))
+
+	for _, cert := range chain {
+		hash := SPKIHash(cert)
+
+		for _, want := range pin.SPKISHA256 {
+			if bytes.Equal(hash, want) {
+				return nil
+			}
+		}
+
+		got = append(got, base64.StdEncoding.EncodeToString(hash))
+	}
+
+	return fmt.Errorf("%w for %s, got SPKI SHA-256 %s", ErrPinMismatch, pin.Host, strings.Join(got, ", "))
+}
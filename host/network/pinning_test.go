@@ -0,0 +1,133 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package network
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"system-transparency.org/stboot/trust"
+)
+
+// issue creates a certificate for localhost and 127.0.0.1, signed by parent
+// or self-signed if parent is nil.
+func issue(t *testing.T, name string, isCA bool, parent *tls.Certificate) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+	}
+
+	if isCA {
+		template.KeyUsage = x509.KeyUsageCertSign
+	}
+
+	signer, signerKey := template, interface{}(key)
+	if parent != nil {
+		signer, signerKey = parent.Leaf, parent.PrivateKey
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+func TestHTTPClientPins(t *testing.T) {
+	ca := issue(t, "root", true, nil)
+	leaf := issue(t, "server", false, &ca)
+	untrusted := issue(t, "untrusted", false, nil)
+
+	serve := func(cert tls.Certificate) string {
+		svr := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, "ok")
+		}))
+		svr.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+		svr.StartTLS()
+		t.Cleanup(svr.Close)
+
+		return strings.Replace(svr.URL, "127.0.0.1", "localhost", 1)
+	}
+
+	trusted := serve(leaf)
+	selfSigned := serve(untrusted)
+
+	pin := func(pinOnly bool, certs ...tls.Certificate) trust.TLSPin {
+		p := trust.TLSPin{Host: "localhost", PinOnly: pinOnly}
+		for _, c := range certs {
+			p.SPKISHA256 = append(p.SPKISHA256, SPKIHash(c.Leaf))
+		}
+
+		return p
+	}
+
+	for _, tt := range []struct {
+		name    string
+		url     string
+		pins    []trust.TLSPin
+		wantErr error
+	}{
+		{name: "unpinned", url: trusted},
+		{name: "leaf", url: trusted, pins: []trust.TLSPin{pin(false, leaf)}},
+		{name: "root", url: trusted, pins: []trust.TLSPin{pin(false, untrusted, ca)}},
+		{name: "mismatch", url: trusted, pins: []trust.TLSPin{pin(false, untrusted)}, wantErr: ErrPinMismatch},
+		{name: "root with pin only", url: trusted, pins: []trust.TLSPin{pin(true, ca)}, wantErr: ErrPinMismatch},
+		{name: "untrusted chain", url: selfSigned, pins: []trust.TLSPin{pin(false, untrusted)}, wantErr: ErrRetriesLimit},
+		{name: "pin only", url: selfSigned, pins: []trust.TLSPin{pin(true, untrusted)}},
+		{name: "other host", url: strings.Replace(selfSigned, "localhost", "127.0.0.1", 1), pins: []trust.TLSPin{pin(true, untrusted)}, wantErr: ErrRetriesLimit},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewHTTPClient([]*x509.Certificate{ca.Leaf}, false)
+			client.Retries = 1
+			client.RetryWait = 0
+
+			if err := client.UsePins(tt.pins); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := client.Download(context.Background(), mkURL(tt.url))
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package network
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrRootNotAllowed = errors.New("root certificate is not trusted for host")
+	ErrRootsTransport = errors.New("restricting roots requires the transport of NewHTTPClient")
+)
+
+// RestrictRoots makes h accept chains ending in a root of hosts only for the
+// host names listed for it. Other roots of h are trusted for all hosts. Since
+// TLS does not reveal IP addresses of servers to the verification, restricted
+// roots are never trusted for IP addresses.
+func (h *HTTPClient) RestrictRoots(hosts map[*x509.Certificate][]string) error {
+	transport, ok := h.transport()
+	if !ok || transport.TLSClientConfig == nil {
+		return ErrRootsTransport
+	}
+
+	if len(hosts) == 0 {
+		return nil
+	}
+
+	restricted := make(map[string]map[string]bool, len(hosts))
+
+	for root, names := range hosts {
+		allowed := make(map[string]bool, len(names))
+		for _, name := range names {
+			allowed[strings.ToLower(name)] = true
+		}
+
+		restricted[string(root.Raw)] = allowed
+	}
+
+	config := transport.TLSClientConfig
+	verify := config.VerifyConnection
+	config.VerifyConnection = func(cs tls.ConnectionState) error {
+		if verify != nil {
+			if err := verify(cs); err != nil {
+				return err
+			}
+		}
+
+		// Without verification there are no chains to check.
+		if len(cs.VerifiedChains) == 0 {
+			return nil
+		}
+
+		for _, chain := range cs.VerifiedChains {
+			allowed, ok := restricted[string(chain[len(chain)-1].Raw)]
+			if !ok || allowed[strings.ToLower(cs.ServerName)] {
+				return nil
+			}
+		}
+
+		root := cs.VerifiedChains[0][len(cs.VerifiedChains[0])-1]
+		if cs.ServerName == "" {
+			return fmt.Errorf("%w IP address: %s", ErrRootNotAllowed, root.Subject)
+		}
+
+		return fmt.Errorf("%w %s: %s", ErrRootNotAllowed, cs.ServerName, root.Subject)
+	}
+
+	return nil
+}
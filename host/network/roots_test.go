@@ -0,0 +1,88 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package network
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"system-transparency.org/stboot/trust"
+)
+
+func TestHTTPClientRestrictRoots(t *testing.T) {
+	ca := issue(t, "internal", true, nil)
+	leaf := issue(t, "server", false, &ca)
+	other := issue(t, "public", true, nil)
+
+	svr := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok")
+	}))
+	svr.TLS = &tls.Config{Certificates: []tls.Certificate{leaf}}
+	svr.StartTLS()
+	t.Cleanup(svr.Close)
+
+	byName := strings.Replace(svr.URL, "127.0.0.1", "localhost", 1)
+
+	for _, tt := range []struct {
+		name    string
+		url     string
+		hosts   map[*x509.Certificate][]string
+		pins    []trust.TLSPin
+		wantErr error
+	}{
+		{name: "unrestricted", url: byName},
+		{name: "allowed host", url: byName, hosts: map[*x509.Certificate][]string{ca.Leaf: {"LOCALHOST"}}},
+		{name: "other root restricted", url: svr.URL, hosts: map[*x509.Certificate][]string{other.Leaf: {"mirror.example"}}},
+		{
+			name:    "other host",
+			url:     byName,
+			hosts:   map[*x509.Certificate][]string{ca.Leaf: {"stboot.example"}},
+			wantErr: ErrRootNotAllowed,
+		},
+		{
+			name:    "IP address",
+			url:     svr.URL,
+			hosts:   map[*x509.Certificate][]string{ca.Leaf: {"localhost"}},
+			wantErr: ErrRootNotAllowed,
+		},
+		{
+			name:    "with pins",
+			url:     byName,
+			hosts:   map[*x509.Certificate][]string{ca.Leaf: {"localhost"}},
+			pins:    []trust.TLSPin{{Host: "localhost", SPKISHA256: [][]byte{SPKIHash(other.Leaf)}}},
+			wantErr: ErrPinMismatch,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewHTTPClient([]*x509.Certificate{ca.Leaf, other.Leaf}, false)
+			client.Retries = 1
+			client.RetryWait = 0
+
+			if err := client.RestrictRoots(tt.hosts); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := client.UsePins(tt.pins); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := client.Download(context.Background(), mkURL(tt.url))
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package network
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+	"system-transparency.org/stboot/host"
+)
+
+// State is the network configuration stboot has set up and used to fetch the
+// OS package. It is handed over to the booted OS, so that its initramfs can
+// reuse the configuration instead of probing the network again.
+type State struct {
+	Interface    string       `json:"interface"`
+	MACAddress   string       `json:"mac_address"`
+	IPAddrMode   string       `json:"network_mode"`
+	IPv6AddrMode string       `json:"ipv6_network_mode,omitempty"`
+	VLAN         *VLANState   `json:"vlan,omitempty"`
+	Bond         *BondState   `json:"bond,omitempty"`
+	Addresses    []AddrState  `json:"addresses"`
+	Routes       []RouteState `json:"routes"`
+	DNSServers   []string     `json:"dns,omitempty"`
+
+	// configured are the links SetupNetwork has added addresses to.
+	configured []netlink.Link
+}
+
+// VLANState describes the VLAN interface stboot has created.
+type VLANState struct {
+	ID     int    `json:"id"`
+	Parent string `json:"parent"`
+}
+
+// BondState describes the bonding interface stboot has created.
+type BondState struct {
+	Name       string   `json:"name"`
+	Mode       string   `json:"mode"`
+	Interfaces []string `json:"interfaces"`
+}
+
+// AddrState is an address in CIDR notation. ValidLifetime is the remaining
+// lifetime in seconds of a leased or autoconfigured address and zero for
+// addresses which do not expire.
+type AddrState struct {
+	Address       string `json:"address"`
+	ValidLifetime int    `json:"valid_lifetime,omitempty"`
+}
+
+// RouteState is a route. Destination is empty for the default route.
+type RouteState struct {
+	Destination string `json:"destination,omitempty"`
+	Gateway     string `json:"gateway,omitempty"`
+	Metric      int    `json:"metric,omitempty"`
+}
+
+// readState reads the configuration of link back from the kernel and the
+// name servers from resolvConf.
+func readState(cfg *host.Config, link netlink.Link, resolvConf string) (*State, error) {
+	link, err := netlink.LinkByIndex(link.Attrs().Index)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &State{
+		Interface:  link.Attrs().Name,
+		MACAddress: link.Attrs().HardwareAddr.String(),
+		IPAddrMode: cfg.IPAddrMode.String(),
+		Addresses:  []AddrState{},
+		Routes:     []RouteState{},
+	}
+
+	if cfg.IPv6AddrMode != nil && *cfg.IPv6AddrMode != host.IPUnset {
+		state.IPv6AddrMode = cfg.IPv6AddrMode.String()
+	}
+
+	lower := link
+
+	if vlan, ok := link.(*netlink.Vlan); ok {
+		if lower, err = netlink.LinkByIndex(vlan.ParentIndex); err != nil {
+			return nil, err
+		}
+
+		state.VLAN = &VLANState{ID: vlan.VlanId, Parent: lower.Attrs().Name}
+	}
+
+	if bond, ok := lower.(*netlink.Bond); ok {
+		if state.Bond, err = bondState(bond); err != nil {
+			return nil, err
+		}
+	}
+
+	addrs, err := netlink.AddrList(link, netlink.FAMILY_ALL)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, addr := range addrs {
+		if !isGlobal(addr.IP) || addr.Flags&unix.IFA_F_TENTATIVE != 0 {
+			continue
+		}
+
+		a := AddrState{Address: addr.IPNet.String()}
+		if addr.ValidLft > 0 && uint32(addr.ValidLft) != math.MaxUint32 {
+			a.ValidLifetime = addr.ValidLft
+		}
+
+		state.Addresses = append(state.Addresses, a)
+	}
+
+	routes, err := netlink.RouteList(link, netlink.FAMILY_ALL)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range routes {
+		// Prefix routes are implied by the addresses.
+		if r.Protocol == unix.RTPROT_KERNEL || r.Dst != nil && !isGlobal(r.Dst.IP) {
+			continue
+		}
+
+		rs := RouteState{Metric: r.Priority}
+		if r.Dst != nil {
+			rs.Destination = r.Dst.String()
+		}
+
+		if r.Gw != nil {
+			rs.Gateway = r.Gw.String()
+		}
+
+		state.Routes = append(state.Routes, rs)
+	}
+
+	if state.DNSServers, err = nameServers(resolvConf); err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+func bondState(bond *netlink.Bond) (*BondState, error) {
+	links, err := netlink.LinkList()
+	if err != nil {
+		return nil, err
+	}
+
+	state := &BondState{
+		Name:       bond.Name,
+		Mode:       bond.Mode.String(),
+		Interfaces: []string{},
+	}
+
+	for _, l := range links {
+		if l.Attrs().MasterIndex == bond.Index {
+			state.Interfaces = append(state.Interfaces, l.Attrs().Name)
+		}
+	}
+
+	return state, nil
+}
+
+// nameServers returns the name servers listed in the resolv.conf file path.
+// A missing file lists none.
+func nameServers(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var servers []string
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			servers = append(servers, fields[1])
+		}
+	}
+
+	return servers, scanner.Err()
+}
+
+// KernelParams returns s as kernel command line parameters in the format
+// understood by the kernel's IP autoconfiguration and dracut: one ip=
+// parameter per address, with the default gateway of the address family
+// added to the first one. Name servers, VLAN and bonding are described with
+// the nameserver=, vlan= and bond= parameters of dracut.
+func (s *State) KernelParams() string {
+	var params []string
+
+	if s.Bond != nil {
+		params = append(params, fmt.Sprintf("bond=%s:%s:mode=%s",
+			s.Bond.Name, strings.Join(s.Bond.Interfaces, ","), s.Bond.Mode))
+	}
+
+	if s.VLAN != nil {
+		params = append(params, fmt.Sprintf("vlan=%s:%s", s.Interface, s.VLAN.Parent))
+	}
+
+	gateways := make(map[bool]string)
+
+	for _, r := range s.Routes {
+		if r.Destination == "" && r.Gateway != "" {
+			ipv6 := net.ParseIP(r.Gateway).To4() == nil
+			if _, ok := gateways[ipv6]; !ok {
+				gateways[ipv6] = r.Gateway
+			}
+		}
+	}
+
+	for _, a := range s.Addresses {
+		ip, ipnet, err := net.ParseCIDR(a.Address)
+		if err != nil {
+			continue
+		}
+
+		ipv6 := ip.To4() == nil
+		gw := gateways[ipv6]
+		delete(gateways, ipv6)
+
+		var param string
+		if ipv6 {
+			ones, _ := ipnet.Mask.Size()
+			if gw != "" {
+				gw = "[" + gw + "]"
+			}
+
+			param = fmt.Sprintf("ip=[%s]::%s:%d::%s:none", ip, gw, ones, s.Interface)
+		} else {
+			param = fmt.Sprintf("ip=%s::%s:%s::%s:none", ip, gw, net.IP(ipnet.Mask), s.Interface)
+		}
+
+		params = append(params, param)
+	}
+
+	for _, ns := range s.DNSServers {
+		params = append(params, "nameserver="+ns)
+	}
+
+	return strings.Join(params, " ")
+}
@@ -0,0 +1,175 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package network
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+	"system-transparency.org/stboot/host"
+)
+
+func TestKernelParams(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		state State
+		want  string
+	}{
+		{
+			name: "no addresses",
+			state: State{
+				Interface: "eth0",
+			},
+			want: "",
+		},
+		{
+			name: "dual-stack",
+			state: State{
+				Interface: "eth0",
+				Addresses: []AddrState{
+					{Address: "192.0.2.2/24"},
+					{Address: "192.0.2.3/24"},
+					{Address: "2001:db8::2/64", ValidLifetime: 3600},
+				},
+				Routes: []RouteState{
+					{Destination: "198.51.100.0/24", Gateway: "192.0.2.254"},
+					{Gateway: "192.0.2.1"},
+					{Gateway: "fe80::1", Metric: 1024},
+				},
+				DNSServers: []string{"192.0.2.53", "2001:db8::53"},
+			},
+			want: "ip=192.0.2.2::192.0.2.1:255.255.255.0::eth0:none " +
+				"ip=192.0.2.3:::255.255.255.0::eth0:none " +
+				"ip=[2001:db8::2]::[fe80::1]:64::eth0:none " +
+				"nameserver=192.0.2.53 nameserver=2001:db8::53",
+		},
+		{
+			name: "VLAN on bond",
+			state: State{
+				Interface: "bond0.100",
+				VLAN:      &VLANState{ID: 100, Parent: "bond0"},
+				Bond:      &BondState{Name: "bond0", Mode: "802.3ad", Interfaces: []string{"eth0", "eth1"}},
+				Addresses: []AddrState{{Address: "192.0.2.2/24"}},
+			},
+			want: "bond=bond0:eth0,eth1:mode=802.3ad vlan=bond0.100:bond0 " +
+				"ip=192.0.2.2:::255.255.255.0::bond0.100:none",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.state.KernelParams(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNameServers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "resolv.conf")
+
+	got, err := nameServers(path)
+	if err != nil || got != nil {
+		t.Fatalf("missing file: got %v, %v", got, err)
+	}
+
+	data := "# comment\nsearch example.org\nnameserver 192.0.2.53\nnameserver  2001:db8::53\n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err = nameServers(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"192.0.2.53", "2001:db8::53"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestReadState(t *testing.T) {
+	if !inNetns(t) {
+		return
+	}
+
+	link, _ := setupVeth(t)
+
+	mode := host.IPStatic
+	cfg := staticRouteConfig(t, `{"destination": "198.51.100.0/24", "gateway": "192.0.2.254"}`)
+	cfg.IPv6AddrMode = &mode
+	cfg.HostIPv6, _ = netlink.ParseAddr("2001:db8::3/64")
+	cfg.DefaultGatewayV6 = &routerIPv6
+
+	static, staticV6 := staticConfigs(cfg)
+	if err := applyStatic(link, static); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := applyStatic(link, staticV6); err != nil {
+		t.Fatal(err)
+	}
+
+	resolv := filepath.Join(t.TempDir(), "resolv.conf")
+	if err := setDNSServer([]*net.IP{&routerIPv4}, resolv); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := readState(cfg, link, resolv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &State{
+		Interface:    "eth0",
+		MACAddress:   link.Attrs().HardwareAddr.String(),
+		IPAddrMode:   "static",
+		IPv6AddrMode: "static",
+		Addresses: []AddrState{
+			{Address: "192.0.2.2/24"},
+			{Address: "2001:db8::2/64"},
+			{Address: "2001:db8::3/64"},
+		},
+		DNSServers: []string{"192.0.2.1"},
+	}
+
+	if state.VLAN != nil || state.Bond != nil {
+		t.Errorf("unexpected VLAN or bond: %+v", state)
+	}
+
+	sort.Slice(state.Addresses, func(i, j int) bool {
+		return state.Addresses[i].Address < state.Addresses[j].Address
+	})
+
+	if !reflect.DeepEqual(state.Addresses, want.Addresses) {
+		t.Errorf("got addresses %+v, want %+v", state.Addresses, want.Addresses)
+	}
+
+	for _, route := range []RouteState{
+		{Gateway: "192.0.2.1"},
+		{Gateway: "2001:db8::1", Metric: 1024},
+		{Destination: "198.51.100.0/24", Gateway: "192.0.2.254"},
+	} {
+		found := false
+
+		for _, r := range state.Routes {
+			found = found || r == route
+		}
+
+		if !found {
+			t.Errorf("route %+v not in %+v", route, state.Routes)
+		}
+	}
+
+	state.Routes, want.Routes = nil, nil
+	state.Addresses, want.Addresses = nil, nil
+
+	if !reflect.DeepEqual(state, want) {
+		t.Errorf("got %+v, want %+v", state, want)
+	}
+}
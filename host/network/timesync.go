@@ -0,0 +1,158 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"golang.org/x/sys/unix"
+	"system-transparency.org/stboot/internal/roughtime"
+	"system-transparency.org/stboot/sterror"
+	"system-transparency.org/stboot/stlog"
+	"system-transparency.org/stboot/trust"
+)
+
+var (
+	ErrTimeSync             = errors.New("failed to obtain trusted time")
+	ErrTrustedTimeTransport = errors.New("trusted time requires the transport of NewHTTPClient")
+)
+
+var roughtimeTimeout = roughtime.DefaultTimeout
+
+// TrustedTime is the current time as agreed on by Roughtime servers. It
+// advances with the monotonic clock, so it is not affected by later changes
+// of the system clock.
+type TrustedTime struct {
+	at     time.Time
+	local  time.Time
+	Radius time.Duration
+}
+
+// Now returns the current trusted time.
+func (t *TrustedTime) Now() time.Time {
+	return t.at.Add(time.Since(t.local))
+}
+
+// SetSystemClock sets the system clock to the trusted time.
+func (t *TrustedTime) SetSystemClock() error {
+	tv := unix.NsecToTimeval(t.Now().UnixNano())
+
+	return unix.Settimeofday(&tv)
+}
+
+// interval is a time range relative to a common local reference.
+type interval struct {
+	earliest time.Time
+	latest   time.Time
+}
+
+// SyncTime queries the Roughtime servers of policy and returns the time a
+// quorum of them agrees on. Servers agree if the ranges of their answers,
+// widened by the round trip time, overlap.
+func SyncTime(ctx context.Context, policy *trust.TimeSyncPolicy) (*TrustedTime, error) {
+	ref := time.Now()
+	samples := make([]interval, 0, len(policy.Servers))
+
+	for _, server := range policy.Servers {
+		qctx, cancel := context.WithTimeout(ctx, roughtimeTimeout)
+		sent := time.Now()
+		rt, err := roughtime.Query(qctx, server.Address, server.PublicKey)
+		received := time.Now()
+
+		cancel()
+
+		if err != nil {
+			stlog.Info("Roughtime server %s: %v", server.Address, err)
+
+			continue
+		}
+
+		stlog.Debug("Roughtime server %s: %s ± %s", server.Address, rt.Midpoint.UTC(), rt.Radius)
+
+		// The server time at the reference, given that the server answered
+		// at some point between sending the request and receiving the response.
+		since := received.Sub(ref)
+		samples = append(samples, interval{
+			earliest: rt.Midpoint.Add(-rt.Radius - since),
+			latest:   rt.Midpoint.Add(rt.Radius + received.Sub(sent) - since),
+		})
+	}
+
+	agreed, n := agree(samples)
+	if n < policy.MinAgreeing() {
+		return nil, sterror.E(ErrScope, ErrOpSyncTime, ErrTimeSync,
+			fmt.Sprintf("%d of %d servers agree, need %d", n, len(policy.Servers), policy.MinAgreeing()))
+	}
+
+	radius := agreed.latest.Sub(agreed.earliest) / 2
+
+	return &TrustedTime{
+		at:     agreed.earliest.Add(radius),
+		local:  ref,
+		Radius: radius,
+	}, nil
+}
+
+// agree returns the range that the most samples overlap in, and the number of
+// these samples.
+func agree(samples []interval) (interval, int) {
+	type event struct {
+		at    time.Time
+		start bool
+	}
+
+	events := make([]event, 0, 2*len(samples))
+	for _, s := range samples {
+		events = append(events, event{s.earliest, true}, event{s.latest, false})
+	}
+
+	// At equal times, starts go first, so touching ranges overlap.
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].at.Equal(events[j].at) {
+			return events[i].start && !events[j].start
+		}
+
+		return events[i].at.Before(events[j].at)
+	})
+
+	var (
+		best        interval
+		count, most int
+	)
+
+	for i, e := range events {
+		if !e.start {
+			count--
+
+			continue
+		}
+
+		count++
+		if count > most {
+			// The next event is the end of the overlap.
+			most = count
+			best = interval{earliest: e.at, latest: events[i+1].at}
+		}
+	}
+
+	return best, most
+}
+
+// UseTrustedTime makes h check certificate validity against t instead of the
+// system clock.
+func (h *HTTPClient) UseTrustedTime(t *TrustedTime) error {
+	transport, ok := h.transport()
+	if !ok || transport.TLSClientConfig == nil {
+		return ErrTrustedTimeTransport
+	}
+
+	transport.TLSClientConfig.Time = t.Now
+
+	return nil
+}
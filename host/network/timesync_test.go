@@ -0,0 +1,117 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package network
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"system-transparency.org/stboot/trust"
+)
+
+func TestAgree(t *testing.T) {
+	base := time.Unix(1700000000, 0)
+	span := func(from, to int) interval {
+		return interval{earliest: base.Add(time.Duration(from) * time.Second), latest: base.Add(time.Duration(to) * time.Second)}
+	}
+
+	for _, tt := range []struct {
+		name    string
+		samples []interval
+		want    interval
+		n       int
+	}{
+		{name: "none", n: 0},
+		{name: "one", samples: []interval{span(0, 10)}, want: span(0, 10), n: 1},
+		{name: "overlap", samples: []interval{span(0, 10), span(5, 15), span(8, 20)}, want: span(8, 10), n: 3},
+		{name: "touching", samples: []interval{span(0, 10), span(10, 20)}, want: span(10, 10), n: 2},
+		{name: "outlier", samples: []interval{span(0, 10), span(1000, 1010), span(2, 12)}, want: span(2, 10), n: 2},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, n := agree(tt.samples)
+			if n != tt.n {
+				t.Fatalf("got %d agreeing samples, want %d", n, tt.n)
+			}
+
+			if !got.earliest.Equal(tt.want.earliest) || !got.latest.Equal(tt.want.latest) {
+				t.Errorf("got %v - %v, want %v - %v", got.earliest, got.latest, tt.want.earliest, tt.want.latest)
+			}
+		})
+	}
+}
+
+func TestSyncTimeUnreachable(t *testing.T) {
+	policy := &trust.TimeSyncPolicy{
+		Servers: []trust.RoughtimeServer{
+			{Address: "127.0.0.1:1", PublicKey: make([]byte, ed25519.PublicKeySize)},
+		},
+	}
+
+	if _, err := SyncTime(context.Background(), policy); !errors.Is(err, ErrTimeSync) {
+		t.Errorf("got %v, want %v", err, ErrTimeSync)
+	}
+}
+
+func TestTrustedTime(t *testing.T) {
+	at := time.Unix(1700000000, 0)
+	trusted := &TrustedTime{at: at, local: time.Now()}
+
+	if d := trusted.Now().Sub(at); d < 0 || d > time.Minute {
+		t.Errorf("trusted time drifted by %v", d)
+	}
+}
+
+func TestHTTPClientTrustedTime(t *testing.T) {
+	cert := selfSigned(t)
+
+	svr := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok")
+	}))
+	svr.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	svr.StartTLS()
+	t.Cleanup(svr.Close)
+
+	root, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name  string
+		at    time.Time
+		valid bool
+	}{
+		{name: "now", at: time.Now(), valid: true},
+		{name: "expired", at: time.Now().Add(24 * time.Hour), valid: false},
+		{name: "not yet valid", at: time.Unix(0, 0), valid: false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewHTTPClient([]*x509.Certificate{root}, false)
+			client.Retries = 1
+			client.RetryWait = 0
+
+			if err := client.UseTrustedTime(&TrustedTime{at: tt.at, local: time.Now()}); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := client.Download(context.Background(), mkURL(svr.URL))
+			if tt.valid && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			if !tt.valid && err == nil {
+				t.Error("expected certificate validity error")
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package host
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"system-transparency.org/stboot/internal/jsonutil"
+)
+
+// Proxy is an HTTP proxy used for all downloads. HTTPS requests are tunnelled
+// with CONNECT, so certificates of the servers are verified as without proxy.
+//
+// URL is the http or https URL of the proxy. Username and Password are sent
+// as basic authentication to the proxy. NoProxy lists hosts reached directly:
+// host names, which also match their subdomains, IP addresses and networks in
+// CIDR notation, or "*" to disable the proxy.
+type Proxy struct {
+	URL      *url.URL
+	Username string
+	Password string
+	NoProxy  []string
+}
+
+type proxy struct {
+	URL      string   `json:"url"`
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"`
+	NoProxy  []string `json:"no_proxy,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (p Proxy) MarshalJSON() ([]byte, error) {
+	aux := proxy{
+		Username: p.Username,
+		Password: p.Password,
+		NoProxy:  p.NoProxy,
+	}
+
+	if p.URL != nil {
+		aux.URL = p.URL.String()
+	}
+
+	return json.Marshal(aux)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (p *Proxy) UnmarshalJSON(data []byte) error {
+	var aux proxy
+	if err := jsonutil.UnmarshalStrict(data, &aux); err != nil {
+		return err
+	}
+
+	u, err := url.Parse(aux.URL)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidProxy, err)
+	}
+
+	*p = Proxy{
+		URL:      u,
+		Username: aux.Username,
+		Password: aux.Password,
+		NoProxy:  aux.NoProxy,
+	}
+
+	return nil
+}
+
+// ProxyURL returns the URL of the proxy including the credentials.
+func (p *Proxy) ProxyURL() *url.URL {
+	u := *p.URL
+	if p.Username != "" {
+		u.User = url.UserPassword(p.Username, p.Password)
+	}
+
+	return &u
+}
+
+// Secrets returns the credentials of the proxy in the forms they take in the
+// proxy URL and in JSON, so they can be kept out of logs.
+func (p *Proxy) Secrets() []string {
+	passwords := []string{p.Password}
+
+	if p.URL != nil && p.URL.User != nil {
+		if password, ok := p.URL.User.Password(); ok {
+			passwords = append(passwords, password)
+		}
+	}
+
+	var secrets []string
+
+	seen := make(map[string]bool)
+	add := func(secret string) {
+		if !seen[secret] {
+			seen[secret] = true
+			secrets = append(secrets, secret)
+		}
+	}
+
+	for _, password := range passwords {
+		if password == "" {
+			continue
+		}
+
+		escaped := strings.TrimPrefix(url.UserPassword("", password).String(), ":")
+
+		for _, form := range []string{password, escaped} {
+			add(form)
+
+			if data, err := json.Marshal(form); err == nil {
+				add(strings.Trim(string(data), `"`))
+			}
+		}
+	}
+
+	return secrets
+}
+
+// Bypass reports whether host is matched by NoProxy.
+func (p *Proxy) Bypass(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	ip := net.ParseIP(host)
+
+	for _, entry := range p.NoProxy {
+		entry = strings.ToLower(strings.TrimSuffix(entry, "."))
+
+		switch {
+		case entry == "*":
+			return true
+		case strings.Contains(entry, "/"):
+			if _, network, err := net.ParseCIDR(entry); err == nil && ip != nil && network.Contains(ip) {
+				return true
+			}
+		case ip != nil:
+			if entryIP := net.ParseIP(entry); entryIP != nil && entryIP.Equal(ip) {
+				return true
+			}
+		default:
+			domain := strings.TrimPrefix(entry, ".")
+			if host == domain || strings.HasSuffix(host, "."+domain) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func checkProxy(cfg *Config) error {
+	p := cfg.Proxy
+	if p == nil {
+		return nil
+	}
+
+	if p.URL == nil || p.URL.Scheme != "http" && p.URL.Scheme != "https" || p.URL.Host == "" {
+		return fmt.Errorf("%w: URL must be http or https", ErrInvalidProxy)
+	}
+
+	if p.URL.User != nil {
+		return fmt.Errorf("%w: credentials belong into username and password", ErrInvalidProxy)
+	}
+
+	if p.Password != "" && p.Username == "" {
+		return fmt.Errorf("%w: password without username", ErrInvalidProxy)
+	}
+
+	for _, entry := range p.NoProxy {
+		if entry == "" {
+			return fmt.Errorf("%w: empty no_proxy entry", ErrInvalidProxy)
+		}
+	}
+
+	return nil
+}
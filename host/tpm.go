@@ -24,6 +24,7 @@ const (
 	ErrScope        sterror.Scope = "Host"
 	ErrOpMeasureTPM sterror.Op    = "MeasureTPM"
 	ErrOpIdentity   sterror.Op    = "Identity"
+	ErrOpCheckTPM   sterror.Op    = "CheckTPM"
 )
 
 // stboot events.
@@ -38,6 +39,11 @@ const (
 	// manifest itself. Only measured once.
 	OspkgManifest EventType = 0xa0000001
 
+	// The SHA-256 hash of the host configuration provenance in layered mode.
+	// The event log note is the provenance JSON, mapping each set field to its
+	// source. Only measured once.
+	HostConfigProvenance EventType = 0xa0000006
+
 	// PCR[13]: Authority measurements.
 
 	// The SHA-256 hash of the stboot trust policy. The event log note is the
@@ -70,8 +76,9 @@ const (
 
 // Errors which may be raised and wrapped in this package.
 var (
-	ErrTPM    = errors.New("failed to measure TPM")
-	ErrNoInit = errors.New("TPM not initialized")
+	ErrTPM        = errors.New("failed to measure TPM")
+	ErrNoInit     = errors.New("TPM not initialized")
+	ErrTPMVersion = errors.New("TPM version not supported")
 )
 
 type Event struct {
@@ -103,6 +110,40 @@ func (m *Measurements) Info() (*tss.TPMInfo, error) {
 	return m.tpm.Info()
 }
 
+// CheckTPM returns an error if no TPM is available or its specification
+// version is lower than minVersion, which can only be "2.0". An empty
+// minVersion accepts any version.
+func (m *Measurements) CheckTPM(minVersion string) error {
+	info, err := m.Info()
+	if err != nil {
+		return err
+	}
+
+	return checkTPMVersion(info.Version, minVersion)
+}
+
+func checkTPMVersion(version tss.TPMVersion, minVersion string) error {
+	var versions = map[string]tss.TPMVersion{
+		"":    tss.TPMVersionAgnostic,
+		"2.0": tss.TPMVersion20,
+	}
+
+	want, ok := versions[minVersion]
+	if !ok {
+		return sterror.E(ErrScope, ErrOpCheckTPM, ErrTPMVersion, fmt.Sprintf("unknown minimum version %q", minVersion))
+	}
+
+	if version == tss.TPMVersionAgnostic {
+		return sterror.E(ErrScope, ErrOpCheckTPM, ErrTPMVersion, "unknown TPM version")
+	}
+
+	if version < want {
+		return sterror.E(ErrScope, ErrOpCheckTPM, ErrTPMVersion, fmt.Sprintf("want at least TPM %s", minVersion))
+	}
+
+	return nil
+}
+
 // returns serialized TPM 2.0 event log.
 func (m *Measurements) Finalize() ([]byte, error) {
 	if m.tpm == nil {
@@ -0,0 +1,42 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package host
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/u-root/u-root/pkg/tss"
+)
+
+func TestCheckTPMVersion(t *testing.T) {
+	tests := []struct {
+		version tss.TPMVersion
+		min     string
+		wantErr error
+	}{
+		{version: tss.TPMVersion20, min: ""},
+		{version: tss.TPMVersion12, min: ""},
+		{version: tss.TPMVersion20, min: "2.0"},
+		{version: tss.TPMVersion20, min: "1.2", wantErr: ErrTPMVersion},
+		{version: tss.TPMVersion12, min: "2.0", wantErr: ErrTPMVersion},
+		{version: tss.TPMVersionAgnostic, min: "", wantErr: ErrTPMVersion},
+		{version: tss.TPMVersion20, min: "3.0", wantErr: ErrTPMVersion},
+	}
+
+	for _, tt := range tests {
+		err := checkTPMVersion(tt.version, tt.min)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("version %v, min %q: got %v, want %v", tt.version, tt.min, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCheckTPMWithoutTPM(t *testing.T) {
+	m := &Measurements{}
+	if err := m.CheckTPM(""); !errors.Is(err, ErrNoInit) {
+		t.Errorf("got %v, want %v", err, ErrNoInit)
+	}
+}
@@ -0,0 +1,179 @@
+package certutil
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+var (
+	ErrNoCertificates = errors.New("no certificates found")
+	ErrInvalidPKCS7   = errors.New("invalid PKCS #7 data")
+)
+
+//nolint:gochecknoglobals
+var oidSignedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+
+// Decode parses certificates in any of the following formats: concatenated
+// PEM blocks of type CERTIFICATE or PKCS7, DER certificates, or DER PKCS #7
+// signed data as in .p7b files.
+func Decode(data []byte) ([]*x509.Certificate, error) {
+	var (
+		certs []*x509.Certificate
+		err   error
+	)
+
+	switch {
+	case bytes.Contains(data, []byte("-----BEGIN")):
+		certs, err = decodePEMBlocks(data)
+	default:
+		if certs, err = x509.ParseCertificates(data); err != nil {
+			certs, err = DecodePKCS7(data)
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(certs) == 0 {
+		return nil, ErrNoCertificates
+	}
+
+	return certs, nil
+}
+
+func decodePEMBlocks(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		switch block.Type {
+		case "CERTIFICATE":
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+
+			certs = append(certs, cert)
+		case "PKCS7":
+			p7, err := DecodePKCS7(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+
+			certs = append(certs, p7...)
+		}
+	}
+
+	return certs, nil
+}
+
+// DecodePKCS7 returns the certificates of DER encoded PKCS #7 signed data,
+// as defined in RFC 2315. Signatures are ignored.
+func DecodePKCS7(der []byte) ([]*x509.Certificate, error) {
+	var info struct {
+		ContentType asn1.ObjectIdentifier
+		Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+	}
+
+	if rest, err := asn1.Unmarshal(der, &info); err != nil || len(rest) != 0 {
+		return nil, fmt.Errorf("%w: content info", ErrInvalidPKCS7)
+	}
+
+	if !info.ContentType.Equal(oidSignedData) {
+		return nil, fmt.Errorf("%w: content type %v is not signed data", ErrInvalidPKCS7, info.ContentType)
+	}
+
+	var signed struct {
+		Version          int
+		DigestAlgorithms asn1.RawValue
+		ContentInfo      asn1.RawValue
+		Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+		CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+		SignerInfos      asn1.RawValue
+	}
+
+	if _, err := asn1.Unmarshal(info.Content.Bytes, &signed); err != nil {
+		return nil, fmt.Errorf("%w: signed data: %v", ErrInvalidPKCS7, err)
+	}
+
+	return x509.ParseCertificates(signed.Certificates.Bytes)
+}
+
+// Load reads the certificates of the file at path, see Decode. If path is a
+// directory, the certificates of all files in it are returned. Files of a
+// directory without certificates are skipped. Duplicate certificates, e.g.
+// from hash links in /etc/ssl/certs, are returned once.
+func Load(path string) ([]*x509.Certificate, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		certs, err := Decode(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+
+		return Dedup(certs), nil
+	}
+
+	names, err := filepath.Glob(filepath.Join(path, "*"))
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(names)
+
+	var certs []*x509.Certificate
+
+	for _, name := range names {
+		if info, err := os.Stat(name); err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+
+		if c, err := Decode(data); err == nil {
+			certs = append(certs, c...)
+		}
+	}
+
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("%s: %w", path, ErrNoCertificates)
+	}
+
+	return Dedup(certs), nil
+}
+
+// Dedup returns certs without duplicates, keeping the first occurrence.
+func Dedup(certs []*x509.Certificate) []*x509.Certificate {
+	seen := make(map[string]bool, len(certs))
+	ret := make([]*x509.Certificate, 0, len(certs))
+
+	for _, c := range certs {
+		if seen[string(c.Raw)] {
+			continue
+		}
+
+		seen[string(c.Raw)] = true
+		ret = append(ret, c)
+	}
+
+	return ret
+}
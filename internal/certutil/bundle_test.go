@@ -0,0 +1,174 @@
+package certutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newCert(t *testing.T, name string) *x509.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert
+}
+
+// pkcs7 returns a degenerate PKCS #7 signed data structure holding certs.
+func pkcs7(t *testing.T, certs ...*x509.Certificate) []byte {
+	t.Helper()
+
+	var raw []byte
+	for _, c := range certs {
+		raw = append(raw, c.Raw...)
+	}
+
+	empty := asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true}
+	signed, err := asn1.Marshal(struct {
+		Version          int
+		DigestAlgorithms asn1.RawValue
+		ContentInfo      struct{ ContentType asn1.ObjectIdentifier }
+		Certificates     asn1.RawValue
+		SignerInfos      asn1.RawValue
+	}{
+		Version:          1,
+		DigestAlgorithms: empty,
+		ContentInfo:      struct{ ContentType asn1.ObjectIdentifier }{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}},
+		Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: raw},
+		SignerInfos:      empty,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	der, err := asn1.Marshal(struct {
+		ContentType asn1.ObjectIdentifier
+		Content     asn1.RawValue
+	}{oidSignedData, asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: signed}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return der
+}
+
+func TestDecode(t *testing.T) {
+	a, b := newCert(t, "a"), newCert(t, "b")
+	pemCert := func(c *x509.Certificate) []byte {
+		return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.Raw})
+	}
+
+	for _, tt := range []struct {
+		name string
+		data []byte
+		want []*x509.Certificate
+	}{
+		{name: "PEM", data: append(pemCert(a), pemCert(b)...), want: []*x509.Certificate{a, b}},
+		{name: "DER", data: a.Raw, want: []*x509.Certificate{a}},
+		{name: "PKCS7", data: pkcs7(t, a, b), want: []*x509.Certificate{a, b}},
+		{
+			name: "PEM PKCS7",
+			data: append(pem.EncodeToMemory(&pem.Block{Type: "PKCS7", Bytes: pkcs7(t, b)}), pemCert(a)...),
+			want: []*x509.Certificate{b, a},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Decode(tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d certificates, want %d", len(got), len(tt.want))
+			}
+
+			for i := range got {
+				if !got[i].Equal(tt.want[i]) {
+					t.Errorf("certificate %d: got %s, want %s", i, got[i].Subject, tt.want[i].Subject)
+				}
+			}
+		})
+	}
+
+	for name, data := range map[string][]byte{
+		"garbage":     []byte("garbage"),
+		"no PEM cert": pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte{1}}),
+	} {
+		if _, err := Decode(data); err == nil {
+			t.Errorf("%s: expect an error", name)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	a, b := newCert(t, "a"), newCert(t, "b")
+	dir := t.TempDir()
+
+	files := map[string][]byte{
+		"a.pem":    pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: a.Raw}),
+		"b.der":    b.Raw,
+		"both.p7b": pkcs7(t, a, b),
+		"README":   []byte("not a certificate"),
+	}
+
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 2 || !got[0].Equal(a) || !got[1].Equal(b) {
+		t.Errorf("got %d certificates, want a and b once", len(got))
+	}
+
+	if got, err := Load(filepath.Join(dir, "both.p7b")); err != nil || len(got) != 2 {
+		t.Errorf("got %d certificates, %v, want 2", len(got), err)
+	}
+
+	if _, err := Load(filepath.Join(dir, "README")); err == nil {
+		t.Error("expect an error")
+	}
+
+	if _, err := Load(filepath.Join(dir, "sub")); !errors.Is(err, ErrNoCertificates) {
+		t.Errorf("got %v, want %v", err, ErrNoCertificates)
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package efivartest provides a fake efivarfs backed by a plain directory.
+package efivartest
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/u-root/u-root/pkg/efivarfs"
+)
+
+// Dir implements efivarfs.EFIVar in a plain directory, using the file format
+// of efivarfs: the little endian attributes followed by the data.
+type Dir string
+
+var _ efivarfs.EFIVar = Dir("")
+
+func (d Dir) path(desc efivarfs.VariableDescriptor) string {
+	return filepath.Join(string(d), fmt.Sprintf("%s-%s", desc.Name, desc.GUID.String()))
+}
+
+// Get implements efivarfs.EFIVar.
+func (d Dir) Get(desc efivarfs.VariableDescriptor) (efivarfs.VariableAttributes, []byte, error) {
+	const attrsLen = 4
+
+	data, err := os.ReadFile(d.path(desc))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil, efivarfs.ErrVarNotExist
+	} else if err != nil {
+		return 0, nil, err
+	}
+
+	if len(data) < attrsLen {
+		return 0, nil, efivarfs.ErrVarNotExist
+	}
+
+	return efivarfs.VariableAttributes(binary.LittleEndian.Uint32(data)), data[attrsLen:], nil
+}
+
+// Set implements efivarfs.EFIVar.
+func (d Dir) Set(desc efivarfs.VariableDescriptor, attrs efivarfs.VariableAttributes, data []byte) error {
+	buf := binary.LittleEndian.AppendUint32(nil, uint32(attrs))
+
+	return os.WriteFile(d.path(desc), append(buf, data...), 0o644)
+}
+
+// Remove implements efivarfs.EFIVar.
+func (d Dir) Remove(desc efivarfs.VariableDescriptor) error {
+	err := os.Remove(d.path(desc))
+	if errors.Is(err, os.ErrNotExist) {
+		return efivarfs.ErrVarNotExist
+	}
+
+	return err
+}
+
+// List is not implemented.
+func (d Dir) List() ([]efivarfs.VariableDescriptor, error) {
+	return nil, errors.New("not implemented")
+}
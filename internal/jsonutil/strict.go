@@ -0,0 +1,181 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+var (
+	ErrUnknownField       = errors.New("unknown field")
+	ErrTrailingData       = errors.New("trailing data after JSON value")
+	ErrUnsupportedVersion = errors.New("unsupported version")
+)
+
+// Prefix of the error returned by encoding/json for unknown fields.
+const unknownFieldPrefix = "json: unknown field "
+
+// UnmarshalStrict is like json.Unmarshal, but it rejects keys which do
+// not correspond to a field of v. Errors about unknown keys wrap
+// ErrUnknownField and name the closest known key, if there is one.
+func UnmarshalStrict(data []byte, v interface{}) error {
+	return DecodeStrict(bytes.NewReader(data), v)
+}
+
+// DecodeStrict decodes exactly one JSON value from r into v. It rejects keys
+// which do not correspond to a field of v and any non-whitespace data
+// following the value.
+func DecodeStrict(r io.Reader, v interface{}) error {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(v); err != nil {
+		return unknownFieldError(err, v)
+	}
+
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return ErrTrailingData
+	}
+
+	return nil
+}
+
+// Version returns the value of the top-level "version" key of the JSON
+// object in data. It returns 0 if the key is absent or null.
+func Version(data []byte) (int, error) {
+	var peek struct {
+		Version *int `json:"version"`
+	}
+
+	if err := json.Unmarshal(data, &peek); err != nil {
+		return 0, err
+	}
+
+	if peek.Version == nil {
+		return 0, nil
+	}
+
+	return *peek.Version, nil
+}
+
+// Names returns the JSON key names of struct or struct pointer s,
+// stripped of any tag options.
+func Names(s interface{}) []string {
+	tags := Tags(s)
+	names := make([]string, 0, len(tags))
+
+	for _, tag := range tags {
+		name, _, _ := strings.Cut(tag, ",")
+		if name != "" && name != "-" {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// Required returns the JSON key names of struct or struct pointer s, which
+// are not tagged with the omitempty option.
+func Required(s interface{}) []string {
+	tags := Tags(s)
+	names := make([]string, 0, len(tags))
+
+	for _, tag := range tags {
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" || name == "-" || hasOption(opts, "omitempty") {
+			continue
+		}
+
+		names = append(names, name)
+	}
+
+	return names
+}
+
+func hasOption(opts, option string) bool {
+	for _, opt := range strings.Split(opts, ",") {
+		if opt == option {
+			return true
+		}
+	}
+
+	return false
+}
+
+func unknownFieldError(err error, v interface{}) error {
+	if !strings.HasPrefix(err.Error(), unknownFieldPrefix) {
+		return err
+	}
+
+	field := strings.Trim(strings.TrimPrefix(err.Error(), unknownFieldPrefix), `"`)
+
+	if typ := reflect.TypeOf(v); typ != nil && typ.Kind() == reflect.Ptr {
+		if hint := closest(field, Names(reflect.New(typ.Elem()).Elem().Interface())); hint != "" {
+			return fmt.Errorf("%w %q, did you mean %q?", ErrUnknownField, field, hint)
+		}
+	}
+
+	return fmt.Errorf("%w %q", ErrUnknownField, field)
+}
+
+// closest returns the candidate with the smallest edit distance to s, if
+// that distance is small enough to be a likely typo.
+func closest(s string, candidates []string) string {
+	const maxDistance = 3
+
+	best, bestDist := "", maxDistance+1
+
+	for _, c := range candidates {
+		if d := distance(s, c); d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+
+	return best
+}
+
+// distance returns the Levenshtein distance of a and b.
+func distance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+
+		prev, cur = cur, prev
+	}
+
+	return prev[len(b)]
+}
+
+func minInt(vals ...int) int {
+	m := vals[0]
+	for _, v := range vals[1:] {
+		if v < m {
+			m = v
+		}
+	}
+
+	return m
+}
@@ -0,0 +1,103 @@
+package jsonutil
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type strictTest struct {
+	Threshold int    `json:"ospkg_signature_threshold"`
+	Method    string `json:"ospkg_fetch_method,omitempty"`
+}
+
+func TestUnmarshalStrict(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		wantErr error
+		hint    string
+	}{
+		{
+			name: "Valid",
+			json: `{"ospkg_signature_threshold": 1, "ospkg_fetch_method": "network"}`,
+		},
+		{
+			name: "Trailing whitespace",
+			json: "{\"ospkg_signature_threshold\": 1}\n\t ",
+		},
+		{
+			name:    "Typo",
+			json:    `{"ospkg_signature_treshold": 1}`,
+			wantErr: ErrUnknownField,
+			hint:    `did you mean "ospkg_signature_threshold"?`,
+		},
+		{
+			name:    "Unknown field",
+			json:    `{"ospkg_signature_threshold": 1, "foo": 1}`,
+			wantErr: ErrUnknownField,
+		},
+		{
+			name:    "Trailing object",
+			json:    `{"ospkg_signature_threshold": 1}{}`,
+			wantErr: ErrTrailingData,
+		},
+		{
+			name:    "Trailing garbage",
+			json:    `{"ospkg_signature_threshold": 1}}`,
+			wantErr: ErrTrailingData,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got strictTest
+			err := UnmarshalStrict([]byte(tt.json), &got)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got %v, want %v", err, tt.wantErr)
+			}
+			if tt.hint != "" && !strings.Contains(err.Error(), tt.hint) {
+				t.Errorf("error %q does not contain %q", err, tt.hint)
+			}
+		})
+	}
+}
+
+func TestVersion(t *testing.T) {
+	tests := []struct {
+		json string
+		want int
+	}{
+		{json: `{}`, want: 0},
+		{json: `{"version": null}`, want: 0},
+		{json: `{"version": 2, "foo": "bar"}`, want: 2},
+	}
+
+	for _, tt := range tests {
+		got, err := Version([]byte(tt.json))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.json, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.json, got, tt.want)
+		}
+	}
+
+	if _, err := Version([]byte(`{"version": "1"}`)); err == nil {
+		t.Error("expect an error for non-numeric version")
+	}
+}
+
+func TestNames(t *testing.T) {
+	got := Names(strictTest{})
+	if len(got) != 2 || got[0] != "ospkg_signature_threshold" || got[1] != "ospkg_fetch_method" {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestRequired(t *testing.T) {
+	got := Required(strictTest{})
+	if len(got) != 1 || got[0] != "ospkg_signature_threshold" {
+		t.Errorf("got %v", got)
+	}
+}
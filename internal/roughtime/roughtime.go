@@ -0,0 +1,360 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package roughtime implements a client of the Roughtime protocol as
+// specified at https://roughtime.googlesource.com/roughtime/+/HEAD/PROTOCOL.md.
+package roughtime
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"time"
+)
+
+const (
+	nonceSize       = 64
+	minRequestSize  = 1024
+	maxResponseSize = 4096
+	hashSize        = sha512.Size
+
+	// DefaultTimeout is used by Query if ctx has no deadline.
+	DefaultTimeout = 5 * time.Second
+)
+
+const (
+	responseContext   = "RoughTime v1 response signature\x00"
+	delegationContext = "RoughTime v1 delegation signature--\x00"
+)
+
+var (
+	tagSIG  = tag("SIG\x00")
+	tagNONC = tag("NONC")
+	tagDELE = tag("DELE")
+	tagPATH = tag("PATH")
+	tagRADI = tag("RADI")
+	tagPUBK = tag("PUBK")
+	tagMIDP = tag("MIDP")
+	tagSREP = tag("SREP")
+	tagMINT = tag("MINT")
+	tagROOT = tag("ROOT")
+	tagCERT = tag("CERT")
+	tagMAXT = tag("MAXT")
+	tagINDX = tag("INDX")
+	tagPAD  = tag("PAD\xff")
+)
+
+// Errors which may be returned by this package.
+var (
+	ErrMalformed         = errors.New("malformed roughtime message")
+	ErrInvalidSignature  = errors.New("invalid roughtime signature")
+	ErrNonceNotIncluded  = errors.New("nonce not included in roughtime response")
+	ErrInvalidDelegation = errors.New("roughtime midpoint outside of delegation")
+)
+
+// Time is a time reported by a Roughtime server. The true time is within
+// Radius of Midpoint at the time the response was created.
+type Time struct {
+	Midpoint time.Time
+	Radius   time.Duration
+}
+
+// Query requests the time from the Roughtime server at address over UDP and
+// verifies the response with the long-term public key of the server.
+func Query(ctx context.Context, address string, key ed25519.PublicKey) (*Time, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, DefaultTimeout)
+		defer cancel()
+	}
+
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	var dialer net.Dialer
+
+	conn, err := dialer.DialContext(ctx, "udp", address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	if _, err := conn.Write(request(nonce)); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, maxResponseSize)
+
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	return verify(buf[:n], nonce, key)
+}
+
+// request returns a request for nonce, padded to the minimum request size.
+func request(nonce []byte) []byte {
+	const headerSize = 4 + 4 + 2*4
+
+	return encode(message{
+		tagNONC: nonce,
+		tagPAD:  make([]byte, minRequestSize-headerSize-len(nonce)),
+	})
+}
+
+// verify checks that data is a response to nonce signed by a key delegated
+// by the long-term key and returns the time it reports.
+//
+//nolint:cyclop
+func verify(data, nonce []byte, key ed25519.PublicKey) (*Time, error) {
+	resp, err := parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, srepData, certData, indx, path, err := resp.get5(tagSIG, tagSREP, tagCERT, tagINDX, tagPATH)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := parse(certData)
+	if err != nil {
+		return nil, err
+	}
+
+	deleData, deleSig, err := cert.get2(tagDELE, tagSIG)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ed25519.Verify(key, append([]byte(delegationContext), deleData...), deleSig) {
+		return nil, fmt.Errorf("%w: delegation", ErrInvalidSignature)
+	}
+
+	dele, err := parse(deleData)
+	if err != nil {
+		return nil, err
+	}
+
+	pubk, mint, maxt, err := dele.get3(tagPUBK, tagMINT, tagMAXT)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pubk) != ed25519.PublicKeySize || len(mint) != 8 || len(maxt) != 8 {
+		return nil, fmt.Errorf("%w: delegation", ErrMalformed)
+	}
+
+	if !ed25519.Verify(pubk, append([]byte(responseContext), srepData...), sig) {
+		return nil, fmt.Errorf("%w: response", ErrInvalidSignature)
+	}
+
+	srep, err := parse(srepData)
+	if err != nil {
+		return nil, err
+	}
+
+	root, midp, radi, err := srep.get3(tagROOT, tagMIDP, tagRADI)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(root) != hashSize || len(midp) != 8 || len(radi) != 4 || len(indx) != 4 || len(path)%hashSize != 0 {
+		return nil, fmt.Errorf("%w: signed response", ErrMalformed)
+	}
+
+	if !includes(root, nonce, binary.LittleEndian.Uint32(indx), path) {
+		return nil, ErrNonceNotIncluded
+	}
+
+	mid := binary.LittleEndian.Uint64(midp)
+	if mid < binary.LittleEndian.Uint64(mint) || mid > binary.LittleEndian.Uint64(maxt) {
+		return nil, ErrInvalidDelegation
+	}
+
+	return &Time{
+		Midpoint: time.UnixMicro(int64(mid)),
+		Radius:   time.Duration(binary.LittleEndian.Uint32(radi)) * time.Microsecond,
+	}, nil
+}
+
+// includes reports whether the Merkle tree with root has nonce as leaf index.
+func includes(root, nonce []byte, index uint32, path []byte) bool {
+	hash := leafHash(nonce)
+
+	for ; len(path) > 0; path = path[hashSize:] {
+		if index&1 == 0 {
+			hash = nodeHash(hash, path[:hashSize])
+		} else {
+			hash = nodeHash(path[:hashSize], hash)
+		}
+
+		index >>= 1
+	}
+
+	return index == 0 && string(hash) == string(root)
+}
+
+func leafHash(nonce []byte) []byte {
+	h := sha512.New()
+	h.Write([]byte{0})
+	h.Write(nonce)
+
+	return h.Sum(nil)
+}
+
+func nodeHash(left, right []byte) []byte {
+	h := sha512.New()
+	h.Write([]byte{1})
+	h.Write(left)
+	h.Write(right)
+
+	return h.Sum(nil)
+}
+
+func tag(s string) uint32 {
+	return binary.LittleEndian.Uint32([]byte(s))
+}
+
+func tagName(t uint32) string {
+	return string(binary.LittleEndian.AppendUint32(nil, t))
+}
+
+// message maps tags to values.
+type message map[uint32][]byte
+
+func (m message) get(t uint32) ([]byte, error) {
+	v, ok := m[t]
+	if !ok {
+		return nil, fmt.Errorf("%w: missing tag %q", ErrMalformed, tagName(t))
+	}
+
+	return v, nil
+}
+
+func (m message) get2(t1, t2 uint32) ([]byte, []byte, error) {
+	v1, err := m.get(t1)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	v2, err := m.get(t2)
+
+	return v1, v2, err
+}
+
+func (m message) get3(t1, t2, t3 uint32) ([]byte, []byte, []byte, error) {
+	v1, v2, err := m.get2(t1, t2)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	v3, err := m.get(t3)
+
+	return v1, v2, v3, err
+}
+
+func (m message) get5(t1, t2, t3, t4, t5 uint32) ([]byte, []byte, []byte, []byte, []byte, error) {
+	v1, v2, v3, err := m.get3(t1, t2, t3)
+	if err != nil {
+		return nil, nil, nil, nil, nil, err
+	}
+
+	v4, v5, err := m.get2(t4, t5)
+
+	return v1, v2, v3, v4, v5, err
+}
+
+// encode returns the wire format of m. Values must be a multiple of four
+// bytes long.
+func encode(m message) []byte {
+	tags := make([]uint32, 0, len(m))
+	for t := range m {
+		tags = append(tags, t)
+	}
+
+	sort.Slice(tags, func(i, j int) bool { return tags[i] < tags[j] })
+
+	out := binary.LittleEndian.AppendUint32(nil, uint32(len(tags)))
+
+	var offset int
+
+	for i, t := range tags {
+		if i > 0 {
+			out = binary.LittleEndian.AppendUint32(out, uint32(offset))
+		}
+
+		offset += len(m[t])
+	}
+
+	for _, t := range tags {
+		out = binary.LittleEndian.AppendUint32(out, t)
+	}
+
+	for _, t := range tags {
+		out = append(out, m[t]...)
+	}
+
+	return out
+}
+
+// parse decodes a message from its wire format.
+func parse(data []byte) (message, error) {
+	if len(data) < 4 || len(data)%4 != 0 {
+		return nil, fmt.Errorf("%w: length %d", ErrMalformed, len(data))
+	}
+
+	n := int(binary.LittleEndian.Uint32(data))
+	if n == 0 {
+		return message{}, nil
+	}
+
+	if n > len(data)/8 {
+		return nil, fmt.Errorf("%w: %d tags in %d bytes", ErrMalformed, n, len(data))
+	}
+
+	headerSize := 8 * n
+	offsets := data[4 : 4*n]
+	tags := data[4*n : headerSize]
+	values := data[headerSize:]
+
+	m := make(message, n)
+	start := 0
+
+	for i := 0; i < n; i++ {
+		end := len(values)
+		if i < n-1 {
+			end = int(binary.LittleEndian.Uint32(offsets[4*i:]))
+		}
+
+		if end < start || end > len(values) || end%4 != 0 {
+			return nil, fmt.Errorf("%w: invalid offset", ErrMalformed)
+		}
+
+		t := binary.LittleEndian.Uint32(tags[4*i:])
+		if i > 0 && t <= binary.LittleEndian.Uint32(tags[4*(i-1):]) {
+			return nil, fmt.Errorf("%w: tags not in ascending order", ErrMalformed)
+		}
+
+		m[t] = values[start:end]
+		start = end
+	}
+
+	return m, nil
+}
@@ -0,0 +1,249 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package roughtime
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/binary"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// server signs responses like a Roughtime server.
+type server struct {
+	rootKey   ed25519.PrivateKey
+	onlineKey ed25519.PrivateKey
+	mint      time.Time
+	maxt      time.Time
+	now       time.Time
+	radius    time.Duration
+}
+
+func newServer(t *testing.T, now time.Time) *server {
+	t.Helper()
+
+	_, rootKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, onlineKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &server{
+		rootKey:   rootKey,
+		onlineKey: onlineKey,
+		mint:      now.Add(-time.Hour),
+		maxt:      now.Add(time.Hour),
+		now:       now,
+		radius:    time.Second,
+	}
+}
+
+func (s *server) publicKey() ed25519.PublicKey {
+	return s.rootKey.Public().(ed25519.PublicKey)
+}
+
+func u64(v int64) []byte {
+	return binary.LittleEndian.AppendUint64(nil, uint64(v))
+}
+
+func u32(v uint32) []byte {
+	return binary.LittleEndian.AppendUint32(nil, v)
+}
+
+// respond answers a request in a batch with another nonce, so the nonce is
+// included in the Merkle tree with a path of length one.
+func (s *server) respond(req []byte) []byte {
+	msg, err := parse(req)
+	if err != nil {
+		return nil
+	}
+
+	nonce := msg[tagNONC]
+	other := bytes.Repeat([]byte{0xaa}, nonceSize)
+	root := nodeHash(leafHash(other), leafHash(nonce))
+
+	srep := encode(message{
+		tagROOT: root,
+		tagMIDP: u64(s.now.UnixMicro()),
+		tagRADI: u32(uint32(s.radius / time.Microsecond)),
+	})
+
+	dele := encode(message{
+		tagPUBK: s.onlineKey.Public().(ed25519.PublicKey),
+		tagMINT: u64(s.mint.UnixMicro()),
+		tagMAXT: u64(s.maxt.UnixMicro()),
+	})
+
+	cert := encode(message{
+		tagDELE: dele,
+		tagSIG:  ed25519.Sign(s.rootKey, append([]byte(delegationContext), dele...)),
+	})
+
+	return encode(message{
+		tagSIG:  ed25519.Sign(s.onlineKey, append([]byte(responseContext), srep...)),
+		tagSREP: srep,
+		tagCERT: cert,
+		tagINDX: u32(1),
+		tagPATH: leafHash(other),
+	})
+}
+
+// serve answers requests on a local UDP port until the test ends.
+func (s *server) serve(t *testing.T) string {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 2*minRequestSize)
+
+		for {
+			n, peer, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+
+			if n < minRequestSize {
+				continue
+			}
+
+			_, _ = conn.WriteTo(s.respond(buf[:n]), peer)
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+func TestEncodeParse(t *testing.T) {
+	msg := message{
+		tagPAD:  make([]byte, 8),
+		tagNONC: bytes.Repeat([]byte{1}, nonceSize),
+		tagRADI: u32(7),
+	}
+
+	got, err := parse(encode(msg))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != len(msg) {
+		t.Fatalf("got %d tags, want %d", len(got), len(msg))
+	}
+
+	for tag, value := range msg {
+		if !bytes.Equal(got[tag], value) {
+			t.Errorf("tag %q: got %x, want %x", tagName(tag), got[tag], value)
+		}
+	}
+
+	if len(request(make([]byte, nonceSize))) != minRequestSize {
+		t.Errorf("request is not padded to %d bytes", minRequestSize)
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+	valid := encode(message{tagNONC: make([]byte, 4), tagPAD: make([]byte, 4)})
+
+	unordered := append([]byte(nil), valid...)
+	copy(unordered[8:], u32(tagPAD))
+	copy(unordered[12:], u32(tagNONC))
+
+	badOffset := append([]byte(nil), valid...)
+	copy(badOffset[4:], u32(12))
+
+	for name, data := range map[string][]byte{
+		"empty":         {},
+		"unaligned":     valid[:len(valid)-1],
+		"too many tags": u32(100),
+		"unordered":     unordered,
+		"bad offset":    badOffset,
+	} {
+		if _, err := parse(data); !errors.Is(err, ErrMalformed) {
+			t.Errorf("%s: got %v, want %v", name, err, ErrMalformed)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	nonce := bytes.Repeat([]byte{0x42}, nonceSize)
+
+	s := newServer(t, now)
+
+	got, err := verify(s.respond(request(nonce)), nonce, s.publicKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !got.Midpoint.Equal(now) || got.Radius != s.radius {
+		t.Errorf("got %v ± %v, want %v ± %v", got.Midpoint, got.Radius, now, s.radius)
+	}
+
+	other := newServer(t, now)
+	if _, err := verify(s.respond(request(nonce)), nonce, other.publicKey()); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("wrong key: got %v, want %v", err, ErrInvalidSignature)
+	}
+
+	wrongNonce := bytes.Repeat([]byte{0x43}, nonceSize)
+	if _, err := verify(s.respond(request(wrongNonce)), nonce, s.publicKey()); !errors.Is(err, ErrNonceNotIncluded) {
+		t.Errorf("wrong nonce: got %v, want %v", err, ErrNonceNotIncluded)
+	}
+
+	s.maxt = now.Add(-time.Minute)
+	if _, err := verify(s.respond(request(nonce)), nonce, s.publicKey()); !errors.Is(err, ErrInvalidDelegation) {
+		t.Errorf("expired delegation: got %v, want %v", err, ErrInvalidDelegation)
+	}
+}
+
+func TestVerifyTampered(t *testing.T) {
+	nonce := bytes.Repeat([]byte{0x42}, nonceSize)
+	s := newServer(t, time.Unix(1700000000, 0))
+
+	resp := s.respond(request(nonce))
+
+	msg, err := parse(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Shift the midpoint inside the signed response.
+	srep, _ := parse(msg[tagSREP])
+	srep[tagMIDP][5]++
+
+	if _, err := verify(resp, nonce, s.publicKey()); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("got %v, want %v", err, ErrInvalidSignature)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	s := newServer(t, now)
+	addr := s.serve(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	got, err := Query(ctx, addr, s.publicKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !got.Midpoint.Equal(now) {
+		t.Errorf("got %v, want %v", got.Midpoint, now)
+	}
+}
@@ -19,8 +19,9 @@ const (
 	pmemAddressPath  = "/sys/kernel/fake_pmem/address"
 	pmemContentsPath = "/sys/kernel/fake_pmem/contents"
 
-	UxIdentity = 0x44495855
-	EventLog   = 0x474F4C45
+	UxIdentity    = 0x44495855
+	EventLog      = 0x474F4C45
+	NetworkConfig = 0x4354454E
 
 	currentVersion = "stboot metadata v1\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00"
 )
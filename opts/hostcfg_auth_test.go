@@ -0,0 +1,247 @@
+package opts
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"testing"
+
+	"system-transparency.org/stboot/host"
+	"system-transparency.org/stboot/trust"
+)
+
+func TestWithHostCfgAuth(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	openKey := func(name string) (io.ReadCloser, error) {
+		if name != "host_config_key.pem" {
+			return nil, os.ErrNotExist
+		}
+
+		return io.NopCloser(bytes.NewReader(keyPEM)), nil
+	}
+
+	unsigned := open(t, "testdata/host_good_all_set.json").Bytes()
+
+	env, err := host.SignConfig(unsigned, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed, err := json.Marshal(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env.Signature[0] ^= 0xff
+
+	tampered, err := json.Marshal(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	provisionCfg := host.ProvisionConfig()
+
+	provision, err := json.Marshal(&provisionCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	auth := func(mode trust.HostConfigAuthMode, fields ...string) *trust.HostConfigAuthPolicy {
+		return &trust.HostConfigAuthPolicy{Mode: mode, Key: "host_config_key.pem", UnsignedFields: fields}
+	}
+
+	tests := []struct {
+		name    string
+		auth    *trust.HostConfigAuthPolicy
+		config  []byte
+		wantErr error
+	}{
+		{
+			name:   "No auth, unsigned",
+			config: unsigned,
+		},
+		{
+			name:    "No auth, signed",
+			config:  signed,
+			wantErr: ErrInvalidHostCfgKey,
+		},
+		{
+			name:   "Optional, unsigned",
+			auth:   auth(trust.HostConfigSignatureOptional),
+			config: unsigned,
+		},
+		{
+			name:   "Required, signed",
+			auth:   auth(trust.HostConfigSignatureRequired),
+			config: signed,
+		},
+		{
+			name:    "Required, unsigned",
+			auth:    auth(trust.HostConfigSignatureRequired),
+			config:  unsigned,
+			wantErr: ErrUnsignedHostCfg,
+		},
+		{
+			name:    "Required, bad signature",
+			auth:    auth(trust.HostConfigSignatureRequired),
+			config:  tampered,
+			wantErr: host.ErrInvalidSignature,
+		},
+		{
+			name:   "Required, provision mode",
+			auth:   auth(trust.HostConfigSignatureRequired),
+			config: provision,
+		},
+		{
+			name: "Restricted, allowed fields",
+			auth: auth(trust.HostConfigRestricted, "network_mode", "host_ip", "gateway", "dns",
+				"network_interfaces", "ospkg_pointer", "identity", "authentication", "bonding_mode", "bond_name"),
+			config: unsigned,
+		},
+		{
+			name:    "Restricted, signed field",
+			auth:    auth(trust.HostConfigRestricted, "network_mode", "host_ip", "gateway", "dns"),
+			config:  unsigned,
+			wantErr: ErrUnsignedHostCfg,
+		},
+		{
+			name:    "Restricted, unknown field",
+			auth:    auth(trust.HostConfigRestricted, "ospkg_pointers"),
+			config:  unsigned,
+			wantErr: ErrUnsignedHostCfg,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := &Opts{}
+			opts.TrustPolicy.HostConfigAuth = tt.auth
+
+			if err := WithHostCfgKey(openKey)(opts); err != nil {
+				t.Fatalf("load key: %v", err)
+			}
+
+			err := WithHostCfg(bytes.NewReader(tt.config))(opts)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got %v, want %v", err, tt.wantErr)
+			}
+
+			if err == nil && opts.HostCfg.OSPkgPointer == nil {
+				t.Error("host configuration not loaded")
+			}
+		})
+	}
+}
+
+func TestWithLayeredHostCfgAuth(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := open(t, "testdata/host_good_all_set.json").Bytes()
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(config, &fields); err != nil {
+		t.Fatal(err)
+	}
+
+	provenance := make(host.ConfigProvenance)
+	signed := make(map[string]bool)
+
+	for name, value := range fields {
+		if string(value) != "null" {
+			provenance[name] = host.ConfigSourceEFIVar
+			signed[name] = true
+		}
+	}
+
+	partly := make(map[string]bool)
+	for name := range signed {
+		partly[name] = name != "identity"
+	}
+
+	auth := func(mode trust.HostConfigAuthMode, fields ...string) *trust.HostConfigAuthPolicy {
+		return &trust.HostConfigAuthPolicy{Mode: mode, Key: "host_config_key.pem", UnsignedFields: fields}
+	}
+
+	tests := []struct {
+		name    string
+		auth    *trust.HostConfigAuthPolicy
+		signed  map[string]bool
+		wantErr error
+	}{
+		{
+			name: "No auth, unsigned",
+		},
+		{
+			name:   "Required, signed",
+			auth:   auth(trust.HostConfigSignatureRequired),
+			signed: signed,
+		},
+		{
+			name:    "Required, partly signed",
+			auth:    auth(trust.HostConfigSignatureRequired),
+			signed:  partly,
+			wantErr: ErrUnsignedHostCfg,
+		},
+		{
+			name:   "Restricted, allowed field",
+			auth:   auth(trust.HostConfigRestricted, "identity"),
+			signed: partly,
+		},
+		{
+			name:    "Restricted, signed field",
+			auth:    auth(trust.HostConfigRestricted, "identity"),
+			wantErr: ErrUnsignedHostCfg,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := &Opts{HostCfgKey: pub}
+			opts.TrustPolicy.HostConfigAuth = tt.auth
+
+			err := WithLayeredHostCfg(func(key ed25519.PublicKey) (*host.LayeredConfig, error) {
+				if !key.Equal(pub) {
+					t.Error("host configuration key not passed")
+				}
+
+				return &host.LayeredConfig{Config: config, Provenance: provenance, Signed: tt.signed}, nil
+			})(opts)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got %v, want %v", err, tt.wantErr)
+			}
+
+			if err == nil && !reflect.DeepEqual(opts.HostCfgProvenance, provenance) {
+				t.Errorf("got provenance\n%s\nwant\n%s", opts.HostCfgProvenance, provenance)
+			}
+		})
+	}
+
+	errLayer := errors.New("layer")
+
+	err = WithLayeredHostCfg(func(ed25519.PublicKey) (*host.LayeredConfig, error) {
+		return nil, errLayer
+	})(&Opts{})
+	if !errors.Is(err, errLayer) {
+		t.Errorf("got %v, want %v", err, errLayer)
+	}
+}
@@ -0,0 +1,127 @@
+package opts
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"system-transparency.org/stboot/trust"
+)
+
+func writeRoot(t *testing.T, dir, name string) *x509.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, name), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert
+}
+
+func TestWithHTTPSRootBundles(t *testing.T) {
+	dir := t.TempDir()
+	public := writeRoot(t, dir, "public.pem")
+	internal := writeRoot(t, dir, "internal.pem")
+	shared := writeRoot(t, dir, "shared.pem")
+
+	for _, tt := range []struct {
+		name      string
+		bundles   *[]trust.TrustBundle
+		wantRoots []*x509.Certificate
+		wantHosts map[string][]string
+	}{
+		{
+			name:      "default",
+			wantRoots: []*x509.Certificate{public},
+		},
+		{
+			name: "per host",
+			bundles: &[]trust.TrustBundle{
+				{Path: filepath.Join(dir, "public.pem")},
+				{Path: "internal.pem", Hosts: []string{"stboot.internal.example"}},
+			},
+			wantRoots: []*x509.Certificate{public, internal},
+			wantHosts: map[string][]string{"internal.pem": {"stboot.internal.example"}},
+		},
+		{
+			name: "several bundles",
+			bundles: &[]trust.TrustBundle{
+				{Path: "shared.pem", Hosts: []string{"stboot.internal.example"}},
+				{Path: "shared.pem", Hosts: []string{"mirror.internal.example"}},
+				{Path: "internal.pem", Hosts: []string{"stboot.internal.example"}},
+				{Path: "public.pem"},
+				{Path: "internal.pem"},
+			},
+			wantRoots: []*x509.Certificate{shared, internal, public},
+			wantHosts: map[string][]string{"shared.pem": {"stboot.internal.example", "mirror.internal.example"}},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := &Opts{TrustPolicy: trust.Policy{HTTPSRoots: tt.bundles}}
+
+			if err := WithHTTPSRootBundles(dir, filepath.Join(dir, "public.pem"))(opts); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(opts.HTTPSRoots) != len(tt.wantRoots) {
+				t.Fatalf("got %d roots, want %d", len(opts.HTTPSRoots), len(tt.wantRoots))
+			}
+
+			for i, root := range opts.HTTPSRoots {
+				if !root.Equal(tt.wantRoots[i]) {
+					t.Errorf("root %d: got %s, want %s", i, root.Subject, tt.wantRoots[i].Subject)
+				}
+			}
+
+			var got map[string][]string
+			for root, hosts := range opts.HTTPSRootHosts {
+				if got == nil {
+					got = make(map[string][]string)
+				}
+
+				got[root.Subject.CommonName] = hosts
+			}
+
+			if !reflect.DeepEqual(got, tt.wantHosts) {
+				t.Errorf("got hosts %v, want %v", got, tt.wantHosts)
+			}
+		})
+	}
+
+	opts := &Opts{TrustPolicy: trust.Policy{HTTPSRoots: &[]trust.TrustBundle{{Path: "missing.pem"}}}}
+	if err := WithHTTPSRootBundles(dir, "")(opts); !errors.Is(err, ErrMissingHTTPSRootCerts) {
+		t.Errorf("got %v, want %v", err, ErrMissingHTTPSRootCerts)
+	}
+}
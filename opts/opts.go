@@ -1,14 +1,21 @@
 package opts
 
 import (
+	"bytes"
+	"crypto/ed25519"
 	"crypto/x509"
 	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"io"
+	"path/filepath"
+	"reflect"
+	"sort"
 
 	"system-transparency.org/stboot/host"
 	"system-transparency.org/stboot/internal/certutil"
+	"system-transparency.org/stboot/internal/jsonutil"
 	"system-transparency.org/stboot/trust"
 )
 
@@ -17,6 +24,8 @@ var (
 	ErrMultipleSigningCerts  = errors.New("exactly one root certificate is expected")
 	ErrMissingHTTPSRootCerts = errors.New("missing HTTPS root certificate(s)")
 	ErrNoCertificateFound    = errors.New("no certifiates found")
+	ErrInvalidHostCfgKey     = errors.New("invalid host configuration key")
+	ErrUnsignedHostCfg       = errors.New("unsigned host configuration not allowed by trust policy")
 )
 
 // OptsVersion is the Version of Opts. It can be used for validation.
@@ -30,8 +39,15 @@ type Opts struct {
 	Version     int
 	TrustPolicy trust.Policy
 	HostCfg     host.Config
+	HostCfgKey  ed25519.PublicKey
 	SigningRoot *x509.Certificate
 	HTTPSRoots  []*x509.Certificate
+	// HTTPSRootHosts restricts roots of HTTPSRoots to the listed host names.
+	// Roots without an entry are trusted for all hosts.
+	HTTPSRootHosts map[*x509.Certificate][]string
+	// HostCfgProvenance records the source of each field of a layered host
+	// configuration. It is nil otherwise.
+	HostCfgProvenance host.ConfigProvenance
 }
 
 // NewOpts return a new Opts initialized by the provided Loaders.
@@ -64,6 +80,53 @@ func WithTrustPolicy(reader io.Reader) Loader {
 	}
 }
 
+// WithHostCfgKey loads the host configuration signing key named in the trust
+// policy via open. It must be used after WithTrustPolicy and before
+// WithHostCfg. Without host configuration authentication in the trust policy
+// it does nothing.
+func WithHostCfgKey(open func(name string) (io.ReadCloser, error)) Loader {
+	return func(opts *Opts) error {
+		auth := opts.TrustPolicy.HostConfigAuth
+		if auth == nil {
+			return nil
+		}
+
+		src, err := open(auth.Key)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+
+		pemBytes, err := io.ReadAll(src)
+		if err != nil {
+			return err
+		}
+
+		block, _ := pem.Decode(pemBytes)
+		if block == nil {
+			return fmt.Errorf("%w: no PEM data", ErrInvalidHostCfgKey)
+		}
+
+		pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidHostCfgKey, err)
+		}
+
+		key, ok := pub.(ed25519.PublicKey)
+		if !ok {
+			return fmt.Errorf("%w: want Ed25519, got %T", ErrInvalidHostCfgKey, pub)
+		}
+
+		opts.HostCfgKey = key
+
+		return nil
+	}
+}
+
+// WithHostCfg loads the host configuration. A signed host configuration
+// envelope is verified against the key loaded by WithHostCfgKey. Unsigned
+// host configurations are subject to the host configuration authentication
+// of the trust policy.
 func WithHostCfg(reader io.Reader) Loader {
 	return func(opts *Opts) error {
 		var hostCfg host.Config
@@ -72,16 +135,146 @@ func WithHostCfg(reader io.Reader) Loader {
 			return ErrNoSrcProvided
 		}
 
-		if err := decodeJSON(reader, &hostCfg); err != nil {
+		data, err := io.ReadAll(reader)
+		if err != nil {
 			return err
 		}
 
+		signed := host.IsConfigEnvelope(data)
+		if signed {
+			if data, err = verifyHostCfg(data, opts.HostCfgKey); err != nil {
+				return err
+			}
+		}
+
+		if err := decodeJSON(bytes.NewReader(data), &hostCfg); err != nil {
+			return err
+		}
+
+		if !signed {
+			if err := checkUnsignedHostCfg(data, hostCfg, opts.TrustPolicy.HostConfigAuth); err != nil {
+				return err
+			}
+		}
+
 		opts.HostCfg = hostCfg
 
 		return nil
 	}
 }
 
+// WithLayeredHostCfg loads the host configuration merged by layer, which is
+// passed the key loaded by WithHostCfgKey to verify signed layers, see
+// host.ConfigLayered. Fields from unsigned layers are subject to the host
+// configuration authentication of the trust policy.
+func WithLayeredHostCfg(layer func(key ed25519.PublicKey) (*host.LayeredConfig, error)) Loader {
+	return func(opts *Opts) error {
+		var hostCfg host.Config
+
+		layered, err := layer(opts.HostCfgKey)
+		if err != nil {
+			return err
+		}
+
+		if err := decodeJSON(bytes.NewReader(layered.Config), &hostCfg); err != nil {
+			return err
+		}
+
+		var unsigned []string
+
+		for name := range layered.Provenance {
+			if !layered.Signed[name] {
+				unsigned = append(unsigned, name)
+			}
+		}
+
+		if err := checkUnsignedFields(unsigned, hostCfg, opts.TrustPolicy.HostConfigAuth); err != nil {
+			return err
+		}
+
+		opts.HostCfg = hostCfg
+		opts.HostCfgProvenance = layered.Provenance
+
+		return nil
+	}
+}
+
+func verifyHostCfg(data []byte, key ed25519.PublicKey) ([]byte, error) {
+	env, err := host.ConfigEnvelopeFromBytes(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if key == nil {
+		return nil, fmt.Errorf("%w: trust policy names no host configuration key", ErrInvalidHostCfgKey)
+	}
+
+	if err := env.Verify(key); err != nil {
+		return nil, err
+	}
+
+	return env.Config, nil
+}
+
+// checkUnsignedHostCfg returns an error if the trust policy auth does not
+// allow the fields set in the unsigned host configuration data.
+func checkUnsignedHostCfg(data []byte, cfg host.Config, auth *trust.HostConfigAuthPolicy) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	set := make([]string, 0, len(fields))
+
+	for name, value := range fields {
+		if string(value) != "null" {
+			set = append(set, name)
+		}
+	}
+
+	return checkUnsignedFields(set, cfg, auth)
+}
+
+// checkUnsignedFields returns an error if the trust policy auth does not
+// allow the unsigned fields set in cfg.
+func checkUnsignedFields(unsigned []string, cfg host.Config, auth *trust.HostConfigAuthPolicy) error {
+	if auth == nil || auth.Mode == trust.HostConfigSignatureOptional {
+		return nil
+	}
+
+	if reflect.DeepEqual(cfg, host.ProvisionConfig()) {
+		return nil
+	}
+
+	known := make(map[string]bool)
+	for _, name := range jsonutil.Names(host.Config{}) {
+		known[name] = true
+	}
+
+	for _, name := range auth.UnsignedFields {
+		if !known[name] {
+			return fmt.Errorf("%w: unknown field %q in unsigned_fields", ErrUnsignedHostCfg, name)
+		}
+	}
+
+	sort.Strings(unsigned)
+
+	for _, name := range unsigned {
+		// Keys other than fields, such as version, carry no configuration.
+		if !known[name] || auth.AllowsUnsigned(name) {
+			continue
+		}
+
+		if auth.Mode == trust.HostConfigSignatureRequired {
+			return ErrUnsignedHostCfg
+		}
+
+		return fmt.Errorf("%w: field %q must be signed", ErrUnsignedHostCfg, name)
+	}
+
+	return nil
+}
+
 func WithSigningRootCert(reader io.Reader) Loader {
 	return func(opts *Opts) error {
 		if reader == nil {
@@ -130,11 +323,63 @@ func WithHTTPSRootCerts(reader io.Reader) Loader {
 	}
 }
 
-func decodeJSON(r io.Reader, i interface{}) error {
-	d := json.NewDecoder(r)
-	if err := d.Decode(i); err != nil {
-		return err
+// WithHTTPSRootBundles loads the HTTPS roots from the trust bundles of the
+// trust policy, or from defaultFile if there are none. Relative bundle paths
+// are resolved against dir. It must be used after WithTrustPolicy.
+func WithHTTPSRootBundles(dir, defaultFile string) Loader {
+	return func(opts *Opts) error {
+		bundles := opts.TrustPolicy.Bundles()
+		if bundles == nil {
+			bundles = []trust.TrustBundle{{Path: defaultFile}}
+		}
+
+		var (
+			roots      []*x509.Certificate
+			restricted = make(map[string][]string)
+			everywhere = make(map[string]bool)
+		)
+
+		for _, b := range bundles {
+			path := b.Path
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(dir, path)
+			}
+
+			certs, err := certutil.Load(path)
+			if err != nil {
+				return fmt.Errorf("%w: %v", ErrMissingHTTPSRootCerts, err)
+			}
+
+			for _, c := range certs {
+				if len(b.Hosts) == 0 {
+					everywhere[string(c.Raw)] = true
+				} else {
+					restricted[string(c.Raw)] = append(restricted[string(c.Raw)], b.Hosts...)
+				}
+			}
+
+			roots = append(roots, certs...)
+		}
+
+		opts.HTTPSRoots = certutil.Dedup(roots)
+		opts.HTTPSRootHosts = nil
+
+		for _, c := range opts.HTTPSRoots {
+			if hosts, ok := restricted[string(c.Raw)]; ok && !everywhere[string(c.Raw)] {
+				if opts.HTTPSRootHosts == nil {
+					opts.HTTPSRootHosts = make(map[*x509.Certificate][]string)
+				}
+
+				opts.HTTPSRootHosts[c] = hosts
+			}
+		}
+
+		return nil
 	}
+}
 
-	return nil
+// decodeJSON decodes exactly one JSON value from r into i. Unknown keys and
+// trailing data are rejected.
+func decodeJSON(r io.Reader, i interface{}) error {
+	return jsonutil.DecodeStrict(r, i)
 }
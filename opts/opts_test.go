@@ -96,6 +96,12 @@ func TestWithTrustPolicy(t *testing.T) {
 			wantLoaded: false,
 			errType:    Error(""),
 		},
+		{
+			name:       "Trailing data",
+			reader:     io.MultiReader(open(t, "testdata/trust_policy_good_all_set.json"), bytes.NewBufferString("{}")),
+			wantLoaded: false,
+			errType:    Error(""),
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -144,6 +150,12 @@ func TestWithHostCfg(t *testing.T) {
 			wantLoaded: false,
 			errType:    Error(""),
 		},
+		{
+			name:       "Trailing data",
+			reader:     io.MultiReader(open(t, "testdata/host_good_all_set.json"), bytes.NewBufferString("{}")),
+			wantLoaded: false,
+			errType:    Error(""),
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
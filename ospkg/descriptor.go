@@ -5,11 +5,15 @@
 package ospkg
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/url"
 	"os"
+	"strings"
 
+	"system-transparency.org/stboot/internal/jsonutil"
 	"system-transparency.org/stboot/sterror"
 	"system-transparency.org/stboot/stlog"
 )
@@ -20,6 +24,7 @@ const (
 	ErrOpDescFromBytes sterror.Op = "DescriptorFomBytes"
 	ErrOpDescBytes     sterror.Op = "Descriptor.Bytes"
 	ErrOpDValidate     sterror.Op = "Descriptor.Validate"
+	ErrOpDCheckArchive sterror.Op = "Descriptor.CheckArchive"
 )
 
 const (
@@ -29,9 +34,14 @@ const (
 )
 
 // Descriptor represents the descriptor JSON file of an OS package.
+//
+// PkgSHA256 optionally names the archive by the hex encoded SHA-256 of its
+// content. Archives retrieved from content-addressed stores or PkgURL are
+// rejected if they do not match.
 type Descriptor struct {
-	Version int    `json:"version"`
-	PkgURL  string `json:"os_pkg_url"`
+	Version   int    `json:"version"`
+	PkgURL    string `json:"os_pkg_url"`
+	PkgSHA256 string `json:"os_pkg_sha256,omitempty"`
 
 	Certificates [][]byte `json:"certificates"`
 	Signatures   [][]byte `json:"signatures"`
@@ -47,10 +57,21 @@ func DescriptorFromFile(src string) (*Descriptor, error) {
 	return DescriptorFromBytes(bytes)
 }
 
-// DescriptorFromBytes parses a manifest from a byte slice.
+// DescriptorFromBytes parses a manifest from a byte slice. Unknown keys,
+// trailing data and versions other than DescriptorVersion are rejected.
+// A missing version is left to Validate.
 func DescriptorFromBytes(data []byte) (*Descriptor, error) {
+	version, err := jsonutil.Version(data)
+	if err != nil {
+		return nil, sterror.E(ErrScope, ErrOpDescFromBytes, ErrParse, err.Error())
+	}
+
+	if version != 0 && version != DescriptorVersion {
+		return nil, sterror.E(ErrScope, ErrOpDescFromBytes, ErrParse, fmt.Sprintf(ErrInfoUnsupportedVer, "descriptor", version, DescriptorVersion))
+	}
+
 	var d Descriptor
-	if err := json.Unmarshal(data, &d); err != nil {
+	if err := jsonutil.UnmarshalStrict(data, &d); err != nil {
 		return nil, sterror.E(ErrScope, ErrOpDescFromBytes, ErrParse, err.Error())
 	}
 
@@ -88,5 +109,51 @@ func (d *Descriptor) Validate() error {
 		return sterror.E(ErrScope, ErrOpDValidate, ErrValidate, ErrInfoMissingScheme)
 	}
 
+	// Package hash
+	if d.PkgSHA256 != "" {
+		if _, ok := d.ArchiveHash(); !ok {
+			stlog.Debug("descriptor: invalid package hash %q", d.PkgSHA256)
+
+			return sterror.E(ErrScope, ErrOpDValidate, ErrValidate, "invalid package hash, want 64 lower case hex digits")
+		}
+	}
+
+	return nil
+}
+
+// ArchiveHash returns the SHA-256 of the archive named by d.PkgSHA256. It
+// returns false if d is not content-addressed or the hash is malformed.
+func (d *Descriptor) ArchiveHash() ([32]byte, bool) {
+	var hash [32]byte
+
+	if len(d.PkgSHA256) != hex.EncodedLen(len(hash)) || d.PkgSHA256 != strings.ToLower(d.PkgSHA256) {
+		return hash, false
+	}
+
+	if _, err := hex.Decode(hash[:], []byte(d.PkgSHA256)); err != nil {
+		return hash, false
+	}
+
+	return hash, true
+}
+
+// CheckArchive returns an error if d names the archive by its hash and
+// archive has a different SHA-256.
+func (d *Descriptor) CheckArchive(archive []byte) error {
+	if d.PkgSHA256 == "" {
+		return nil
+	}
+
+	want, ok := d.ArchiveHash()
+	if !ok {
+		return sterror.E(ErrScope, ErrOpDCheckArchive, ErrValidate, "invalid package hash")
+	}
+
+	if got := sha256.Sum256(archive); got != want {
+		stlog.Debug("descriptor: archive hash %x, want %x", got, want)
+
+		return sterror.E(ErrScope, ErrOpDCheckArchive, ErrHashMismatch, fmt.Sprintf("got %x", got))
+	}
+
 	return nil
 }
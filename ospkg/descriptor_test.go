@@ -37,3 +37,40 @@ func TestDescriptorFromBytes(t *testing.T) {
 	t.Log(d)
 	require.NoError(t, err)
 }
+
+func TestDescriptorFromBytesStrict(t *testing.T) {
+	tests := []struct {
+		name  string
+		json  string
+		valid bool
+	}{
+		{
+			name:  "Valid",
+			json:  `{"version": 1, "os_pkg_url": "https://example.org/ospkg.zip", "certificates": [], "signatures": []}`,
+			valid: true,
+		},
+		{
+			name: "Unknown field",
+			json: `{"version": 1, "os_pkg_url": "https://example.org/ospkg.zip", "certificate": []}`,
+		},
+		{
+			name: "Unsupported version",
+			json: `{"version": 2, "os_pkg_url": "https://example.org/ospkg.zip", "pkg_sha256": ""}`,
+		},
+		{
+			name: "Trailing data",
+			json: `{"version": 1}{"version": 1}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DescriptorFromBytes([]byte(tt.json))
+			if tt.valid {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, ErrParse)
+			}
+		})
+	}
+}
@@ -0,0 +1,252 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ospkg
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"time"
+
+	"system-transparency.org/stboot/sterror"
+)
+
+// Operations used for raising Errors of this package.
+const (
+	ErrOpOSPkgInfo sterror.Op = "OSPackage.Info"
+)
+
+// maxManifestSize bounds the manifest read from a package whose signatures
+// are not verified.
+const maxManifestSize = 1 << 20
+
+// Info is a structured report about an OS package, meant for debugging and
+// inspection. It can be serialized to JSON.
+type Info struct {
+	Descriptor DescriptorInfo  `json:"descriptor"`
+	Manifest   *OSManifest     `json:"manifest"`
+	Archive    ArchiveInfo     `json:"archive"`
+	Signatures []SignatureInfo `json:"signatures"`
+}
+
+// DescriptorInfo describes the descriptor of an OS package.
+type DescriptorInfo struct {
+	Version           int    `json:"version"`
+	PkgURL            string `json:"os_pkg_url"`
+	PkgSHA256         string `json:"os_pkg_sha256,omitempty"`
+	SHA256            string `json:"sha256"`
+	NumCertificates   int    `json:"num_certificates"`
+	NumSignatures     int    `json:"num_signatures"`
+	SignaturesValid   int    `json:"num_signatures_valid"`
+	SignaturesChecked bool   `json:"signatures_checked"`
+}
+
+// ArchiveInfo describes the ZIP archive of an OS package.
+type ArchiveInfo struct {
+	Size   int        `json:"size"`
+	SHA256 string     `json:"sha256"`
+	Files  []FileInfo `json:"files"`
+}
+
+// FileInfo describes a single entry of the ZIP archive. Size, CompressedSize
+// and CRC32 are taken from the ZIP headers. SHA256 requires decompressing the
+// entry and is only set if a signature of the package is valid.
+type FileInfo struct {
+	Name           string `json:"name"`
+	Size           uint64 `json:"size"`
+	CompressedSize uint64 `json:"compressed_size"`
+	CRC32          string `json:"crc32"`
+	SHA256         string `json:"sha256,omitempty"`
+}
+
+// SignatureInfo describes a single signature of the descriptor and the
+// outcome of its verification.
+type SignatureInfo struct {
+	Index       int              `json:"index"`
+	Status      SignatureStatus  `json:"status"`
+	Error       string           `json:"error,omitempty"`
+	Certificate *CertificateInfo `json:"certificate,omitempty"`
+}
+
+// CertificateInfo describes a signing certificate.
+type CertificateInfo struct {
+	Subject      string    `json:"subject"`
+	Issuer       string    `json:"issuer"`
+	SerialNumber string    `json:"serial_number"`
+	KeyType      string    `json:"key_type"`
+	NotBefore    time.Time `json:"not_before"`
+	NotAfter     time.Time `json:"not_after"`
+	SHA256       string    `json:"sha256"`
+}
+
+// Info returns a report about osp. The signatures are verified against
+// rootCert using the same rules as Verify. If rootCert is nil, the signatures
+// are reported as not verified. Archive entries are only decompressed if a
+// signature is valid, except for a manifest of limited size. Info does not
+// change the verification state of osp and should be called before
+// LinuxImage.
+func (osp *OSPackage) Info(rootCert *x509.Certificate) (*Info, error) {
+	raw, err := osp.ArchiveBytes()
+	if err != nil {
+		return nil, sterror.E(ErrScope, ErrOpOSPkgInfo, ErrParse, err.Error())
+	}
+
+	descriptorBytes, err := osp.DescriptorBytes()
+	if err != nil {
+		return nil, sterror.E(ErrScope, ErrOpOSPkgInfo, ErrParse, err.Error())
+	}
+
+	descriptorHash := osp.descriptorHash
+	if descriptorHash == [32]byte{} {
+		descriptorHash = sha256.Sum256(descriptorBytes)
+	}
+
+	archiveHash := sha256.Sum256(raw)
+
+	info := &Info{
+		Descriptor: DescriptorInfo{
+			Version:           osp.descriptor.Version,
+			PkgURL:            osp.descriptor.PkgURL,
+			PkgSHA256:         osp.descriptor.PkgSHA256,
+			SHA256:            hex.EncodeToString(descriptorHash[:]),
+			NumCertificates:   len(osp.descriptor.Certificates),
+			NumSignatures:     len(osp.descriptor.Signatures),
+			SignaturesChecked: rootCert != nil,
+		},
+		Archive: ArchiveInfo{
+			Size:   len(raw),
+			SHA256: hex.EncodeToString(archiveHash[:]),
+		},
+		Signatures: make([]SignatureInfo, 0, len(osp.descriptor.Signatures)),
+	}
+
+	for iter, res := range osp.checkSignatures(rootCert, archiveHash) {
+		sigInfo := SignatureInfo{
+			Index:  iter,
+			Status: res.status,
+		}
+
+		if res.err != nil {
+			sigInfo.Error = res.err.Error()
+		}
+
+		if res.cert != nil {
+			sigInfo.Certificate = certificateInfo(res.cert)
+		}
+
+		if res.status == SigValid {
+			info.Descriptor.SignaturesValid++
+		}
+
+		info.Signatures = append(info.Signatures, sigInfo)
+	}
+
+	// Unverified archives may hold entries crafted to exhaust resources when
+	// decompressed.
+	verified := info.Descriptor.SignaturesValid > 0
+
+	archive, err := zip.NewReader(bytes.NewReader(raw), int64(len(raw)))
+	if err != nil {
+		return nil, sterror.E(ErrScope, ErrOpOSPkgInfo, ErrFailedToUnzip, err.Error())
+	}
+
+	for _, file := range archive.File {
+		fileInfo, err := zipFileInfo(file, verified)
+		if err != nil {
+			return nil, sterror.E(ErrScope, ErrOpOSPkgInfo, ErrFailedToUnzip, err.Error())
+		}
+
+		info.Archive.Files = append(info.Archive.Files, fileInfo)
+
+		if file.Name != ManifestName || !verified && file.UncompressedSize64 > maxManifestSize {
+			continue
+		}
+
+		m, err := readZipFile(file)
+		if err != nil {
+			return nil, sterror.E(ErrScope, ErrOpOSPkgInfo, ErrFailedToUnzip, err.Error())
+		}
+
+		if info.Manifest, err = OSManifestFromBytes(m); err != nil {
+			return nil, sterror.E(ErrScope, ErrOpOSPkgInfo, ErrParse, err.Error())
+		}
+	}
+
+	return info, nil
+}
+
+// zipFileInfo describes file. Only with hash set, the entry is decompressed
+// to compute its SHA-256 hash.
+func zipFileInfo(file *zip.File, hash bool) (FileInfo, error) {
+	info := FileInfo{
+		Name:           file.Name,
+		Size:           file.UncompressedSize64,
+		CompressedSize: file.CompressedSize64,
+		CRC32:          fmt.Sprintf("%08x", file.CRC32),
+	}
+
+	if !hash || file.FileInfo().IsDir() {
+		return info, nil
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return FileInfo{}, err
+	}
+	defer src.Close()
+
+	sum := sha256.New()
+	//nolint:gosec
+	if _, err := io.Copy(sum, src); err != nil {
+		return FileInfo{}, err
+	}
+
+	info.SHA256 = hex.EncodeToString(sum.Sum(nil))
+
+	return info, nil
+}
+
+// readZipFile returns the content of file. Reading fails if the content
+// exceeds the size in the ZIP headers.
+func readZipFile(file *zip.File) ([]byte, error) {
+	src, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	return io.ReadAll(src)
+}
+
+func certificateInfo(cert *x509.Certificate) *CertificateInfo {
+	fingerprint := sha256.Sum256(cert.Raw)
+
+	return &CertificateInfo{
+		Subject:      cert.Subject.String(),
+		Issuer:       cert.Issuer.String(),
+		SerialNumber: cert.SerialNumber.String(),
+		KeyType:      keyType(cert),
+		NotBefore:    cert.NotBefore.UTC(),
+		NotAfter:     cert.NotAfter.UTC(),
+		SHA256:       hex.EncodeToString(fingerprint[:]),
+	}
+}
+
+func keyType(cert *x509.Certificate) string {
+	switch pub := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("%s-%d", cert.PublicKeyAlgorithm, pub.N.BitLen())
+	case *ecdsa.PublicKey:
+		return fmt.Sprintf("%s-%s", cert.PublicKeyAlgorithm, pub.Curve.Params().Name)
+	default:
+		return cert.PublicKeyAlgorithm.String()
+	}
+}
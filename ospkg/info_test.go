@@ -0,0 +1,179 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ospkg
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"testing"
+)
+
+func TestInfo(t *testing.T) {
+	rootPub, rootPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root := mkCert(t, rootPub, nil, rootPriv)
+
+	_, otherRootPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherRoot := mkCert(t, otherRootPriv.Public(), nil, otherRootPriv)
+
+	osp := mkOSPackage(t)
+
+	// one signature rooted in root, one in otherRoot.
+	for _, parent := range []struct {
+		cert *x509.Certificate
+		key  ed25519.PrivateKey
+	}{
+		{cert: root, key: rootPriv},
+		{cert: otherRoot, key: otherRootPriv},
+	} {
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		der, err := x509.MarshalPKCS8PrivateKey(priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		cert := mkCert(t, pub, parent.cert, parent.key)
+		if err := osp.Sign(&pem.Block{Type: "PRIVATE KEY", Bytes: der}, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	info, err := osp.Info(root)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if osp.isVerified {
+		t.Error("Info must not mark the package as verified")
+	}
+
+	raw, _ := osp.ArchiveBytes()
+	hash := sha256.Sum256(raw)
+
+	if info.Archive.SHA256 != hex.EncodeToString(hash[:]) {
+		t.Errorf("got archive hash %s, want %x", info.Archive.SHA256, hash)
+	}
+
+	if len(info.Archive.Files) != 4 {
+		t.Errorf("got %d files, want 4", len(info.Archive.Files))
+	}
+
+	for _, file := range info.Archive.Files {
+		if file.CRC32 == "" || file.SHA256 == "" && file.Name[len(file.Name)-1] != '/' {
+			t.Errorf("file %s: got %+v, want CRC32 and SHA256", file.Name, file)
+		}
+	}
+
+	if info.Manifest == nil || info.Manifest.Label != "test" {
+		t.Errorf("got manifest %+v, want label %q", info.Manifest, "test")
+	}
+
+	wantStatus := []SignatureStatus{SigValid, SigUntrustedCert}
+	if len(info.Signatures) != len(wantStatus) {
+		t.Fatalf("got %d signatures, want %d", len(info.Signatures), len(wantStatus))
+	}
+
+	for i, sig := range info.Signatures {
+		if sig.Status != wantStatus[i] {
+			t.Errorf("signature %d: got status %q, want %q", i, sig.Status, wantStatus[i])
+		}
+
+		if sig.Certificate == nil || sig.Certificate.KeyType != "Ed25519" {
+			t.Errorf("signature %d: got certificate %+v, want Ed25519 key", i, sig.Certificate)
+		}
+	}
+
+	if info.Descriptor.SignaturesValid != 1 {
+		t.Errorf("got %d valid signatures, want 1", info.Descriptor.SignaturesValid)
+	}
+
+	if _, err := json.Marshal(info); err != nil {
+		t.Errorf("cannot serialize info: %v", err)
+	}
+
+	// Without root, signatures are just listed.
+	info, err = osp.Info(nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for i, sig := range info.Signatures {
+		if sig.Status != SigUnchecked {
+			t.Errorf("signature %d: got status %q, want %q", i, sig.Status, SigUnchecked)
+		}
+	}
+
+	// Entries of an unverified package are not decompressed, except for the
+	// manifest.
+	for _, file := range info.Archive.Files {
+		if file.SHA256 != "" {
+			t.Errorf("file %s: got SHA256 %s of unverified entry", file.Name, file.SHA256)
+		}
+	}
+
+	if info.Manifest == nil || info.Manifest.Label != "test" {
+		t.Errorf("got manifest %+v, want label %q", info.Manifest, "test")
+	}
+}
+
+func TestInfoLargeManifest(t *testing.T) {
+	var buf bytes.Buffer
+
+	w := zip.NewWriter(&buf)
+
+	f, err := w.Create(ManifestName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := f.Write(bytes.Repeat([]byte(" "), maxManifestSize+1)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	descriptor, err := (&Descriptor{Version: DescriptorVersion}).Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	osp, err := NewOSPackage(buf.Bytes(), descriptor)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := osp.Info(nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if info.Manifest != nil {
+		t.Errorf("got manifest %+v of unverified package beyond %d bytes", info.Manifest, maxManifestSize)
+	}
+
+	if len(info.Archive.Files) != 1 || info.Archive.Files[0].Size != maxManifestSize+1 {
+		t.Errorf("got files %+v, want manifest of size %d", info.Archive.Files, maxManifestSize+1)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"system-transparency.org/stboot/internal/jsonutil"
 	"system-transparency.org/stboot/sterror"
 	"system-transparency.org/stboot/stlog"
 )
@@ -49,10 +50,21 @@ func NewOSManifest(label, kernelPath, initramfsPath, cmdline string) *OSManifest
 	}
 }
 
-// OSManifestFromBytes parses a manifest from a byte slice.
+// OSManifestFromBytes parses a manifest from a byte slice. Unknown keys,
+// trailing data and versions other than ManifestVersion are rejected.
+// A missing version is left to Validate.
 func OSManifestFromBytes(data []byte) (*OSManifest, error) {
+	version, err := jsonutil.Version(data)
+	if err != nil {
+		return nil, sterror.E(ErrScope, ErrOpOSMFromBytes, ErrParse, err.Error())
+	}
+
+	if version != 0 && version != ManifestVersion {
+		return nil, sterror.E(ErrScope, ErrOpOSMFromBytes, ErrParse, fmt.Sprintf(ErrInfoUnsupportedVer, "manifest", version, ManifestVersion))
+	}
+
 	var m OSManifest
-	if err := json.Unmarshal(data, &m); err != nil {
+	if err := jsonutil.UnmarshalStrict(data, &m); err != nil {
 		return nil, sterror.E(ErrScope, ErrOpOSMFromBytes, ErrParse, err.Error())
 	}
 
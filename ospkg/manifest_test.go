@@ -0,0 +1,31 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ospkg
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOSManifestFromBytes(t *testing.T) {
+	m := NewOSManifest("label", "boot/vmlinuz", "boot/initramfs", "console=ttyS0")
+
+	b, err := m.Bytes()
+	require.NoError(t, err)
+
+	got, err := OSManifestFromBytes(b)
+	require.NoError(t, err)
+	require.Equal(t, m, got)
+
+	_, err = OSManifestFromBytes([]byte(`{"version": 1, "kernel": "k", "initramfs": "i", "comdline": ""}`))
+	require.ErrorIs(t, err, ErrParse)
+	require.True(t, strings.Contains(err.Error(), `did you mean "cmdline"?`), err.Error())
+
+	_, err = OSManifestFromBytes([]byte(`{"version": 2, "kernel": "k", "initramfs": "i", "uki": "u"}`))
+	require.ErrorIs(t, err, ErrParse)
+	require.True(t, strings.Contains(err.Error(), "unsupported manifest version 2"), err.Error())
+}
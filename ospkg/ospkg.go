@@ -7,8 +7,10 @@ package ospkg
 import (
 	"archive/zip"
 	"bytes"
+	"crypto"
 	"crypto/sha256"
 	"crypto/x509"
+	"encoding/hex"
 	"encoding/pem"
 	"errors"
 	"fmt"
@@ -28,6 +30,7 @@ const (
 	ErrOpNewOSPkg             sterror.Op    = "NewOSPackage"
 	ErrOpOSPkgArchiveBytes    sterror.Op    = "OSPackage.ArchiveBytes"
 	ErrOpOSPkgDescriptorBytes sterror.Op    = "OSPackage.DescriptorBytes"
+	ErrOpOSPkgAddArchiveHash  sterror.Op    = "OSPackage.AddArchiveHash"
 	ErrOpOSPkgSign            sterror.Op    = "OSPackage.Sign"
 	ErrOpOSPkgVerify          sterror.Op    = "OSPackage.Verify"
 	ErrOpOSPkgvalidate        sterror.Op    = "OSPackage.validate"
@@ -52,6 +55,7 @@ var (
 	ErrGenerateData  = errors.New("failed to generate data")
 	ErrMissingData   = errors.New("missing data")
 	ErrOverwriteData = errors.New("failed to overwrite data")
+	ErrHashMismatch  = errors.New("archive does not match descriptor hash")
 )
 
 // Additional information which might get included into Errors.
@@ -59,6 +63,7 @@ const (
 	ErrInfoFailedToReadFrom = "failed to read from %v"
 	ErrInfoInvalidPath      = "missing %v path"
 	ErrInfoInvalidVer       = "invalid version: %d, expected %d"
+	ErrInfoUnsupportedVer   = "unsupported %s version %d, this version of stboot supports %d"
 	ErrInfoMissingScheme    = "missing scheme"
 	ErrInfoLengthOfZero     = "data %v has length of zero"
 )
@@ -149,25 +154,29 @@ func CreateOSPackage(label, pkgURL, kernel, initramfs, cmdline string) (*OSPacka
 }
 
 // NewOSPackage constructs a new OSPackage initialized with raw bytes
-// and valid internal state.
+// and valid internal state. If the descriptor names the archive by its hash,
+// archiveZIP must match before it is parsed.
 func NewOSPackage(archiveZIP, descriptorJSON []byte) (*OSPackage, error) {
-	// check archive
-	_, err := zip.NewReader(bytes.NewReader(archiveZIP), int64(len(archiveZIP)))
-	if err != nil {
-		return nil, sterror.E(ErrScope, ErrOpNewOSPkg, ErrGenerateData, err.Error())
-	}
 	// check descriptor
 	descriptor, err := DescriptorFromBytes(descriptorJSON)
 	if err != nil {
-		if err != nil {
-			return nil, sterror.E(ErrScope, ErrOpNewOSPkg, ErrGenerateData, err.Error())
-		}
+		return nil, sterror.E(ErrScope, ErrOpNewOSPkg, ErrGenerateData, err.Error())
 	}
 
 	if err = descriptor.Validate(); err != nil {
 		return nil, sterror.E(ErrScope, ErrOpNewOSPkg, ErrGenerateData, err.Error())
 	}
 
+	if err = descriptor.CheckArchive(archiveZIP); err != nil {
+		return nil, sterror.E(ErrScope, ErrOpNewOSPkg, ErrHashMismatch, err.Error())
+	}
+
+	// check archive
+	_, err = zip.NewReader(bytes.NewReader(archiveZIP), int64(len(archiveZIP)))
+	if err != nil {
+		return nil, sterror.E(ErrScope, ErrOpNewOSPkg, ErrGenerateData, err.Error())
+	}
+
 	osp := OSPackage{
 		raw:            archiveZIP,
 		descriptor:     descriptor,
@@ -246,24 +255,46 @@ func (osp *OSPackage) DescriptorBytes() ([]byte, error) {
 	return b, nil
 }
 
+// AddArchiveHash records the SHA-256 of the archive in the descriptor, so
+// the archive can be retrieved from content-addressed stores.
+func (osp *OSPackage) AddArchiveHash() error {
+	raw, err := osp.ArchiveBytes()
+	if err != nil {
+		return sterror.E(ErrScope, ErrOpOSPkgAddArchiveHash, ErrNotHashable, err.Error())
+	}
+
+	hash := sha256.Sum256(raw)
+	osp.descriptor.PkgSHA256 = hex.EncodeToString(hash[:])
+
+	return nil
+}
+
 // zip packs the content stored in osp and (over)writes osp.Raw.
+// The archive is reproducible: entries are always written in the same order,
+// with fixed permissions, compression settings and modification time, see
+// SourceDateEpochEnv.
 func (osp *OSPackage) zip() error {
+	modified, err := archiveTime()
+	if err != nil {
+		return sterror.E(ErrScope, ErrOpOSPkgzip, ErrOverwriteData, err.Error())
+	}
+
 	buf := new(bytes.Buffer)
-	zipWriter := zip.NewWriter(buf)
+	zipWriter := newZipWriter(buf)
 
 	// directories
-	if err := zipDir(zipWriter, bootfilesDir); err != nil {
+	if err := zipDir(zipWriter, bootfilesDir, modified); err != nil {
 		return sterror.E(ErrScope, ErrOpOSPkgzip, ErrOverwriteData, err.Error())
 	}
 	// kernel
 	name := osp.manifest.KernelPath
-	if err := zipFile(zipWriter, name, osp.kernel); err != nil {
+	if err := zipFile(zipWriter, name, osp.kernel, modified); err != nil {
 		return sterror.E(ErrScope, ErrOpOSPkgzip, ErrOverwriteData, err.Error())
 	}
 	// initramfs
 	if len(osp.initramfs) > 0 {
 		name = osp.manifest.InitramfsPath
-		if err := zipFile(zipWriter, name, osp.initramfs); err != nil {
+		if err := zipFile(zipWriter, name, osp.initramfs, modified); err != nil {
 			return sterror.E(ErrScope, ErrOpOSPkgzip, ErrOverwriteData, err.Error())
 		}
 	}
@@ -273,7 +304,7 @@ func (osp *OSPackage) zip() error {
 		return sterror.E(ErrScope, ErrOpOSPkgzip, ErrOverwriteData, err.Error())
 	}
 
-	if err := zipFile(zipWriter, ManifestName, mbytes); err != nil {
+	if err := zipFile(zipWriter, ManifestName, mbytes, modified); err != nil {
 		return sterror.E(ErrScope, ErrOpOSPkgzip, ErrOverwriteData, err.Error())
 	}
 
@@ -326,7 +357,27 @@ func (osp *OSPackage) unzip() error {
 
 // Sign signes osp.HashValue using osp.Signer.
 // Both, the signature and the certificate are stored into the OSPackage.
+// The private key is expected as PKCS#8 in keyBlock. See SignWith for keys
+// that must not leave their storage, e.g. an HSM.
 func (osp *OSPackage) Sign(keyBlock, certBlock *pem.Block) error {
+	priv, err := x509.ParsePKCS8PrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return sterror.E(ErrScope, ErrOpOSPkgSign, ErrSign, err.Error())
+	}
+
+	key, ok := priv.(crypto.Signer)
+	if !ok {
+		return sterror.E(ErrScope, ErrOpOSPkgSign, ErrSign, fmt.Sprintf("unsupported private key type %T", priv))
+	}
+
+	return osp.SignWith(key, certBlock)
+}
+
+// SignWith signes osp.HashValue using osp.Signer on top of key.
+// The private key material does not need to be accessible, key may be backed by
+// an HSM or an external program. The public key of key must match the certificate.
+// Both, the signature and the certificate are stored into the OSPackage.
+func (osp *OSPackage) SignWith(key crypto.Signer, certBlock *pem.Block) error {
 	hash, err := calculateHash(osp.raw)
 	if err != nil {
 		return err
@@ -334,14 +385,16 @@ func (osp *OSPackage) Sign(keyBlock, certBlock *pem.Block) error {
 
 	osp.hash = hash
 
-	priv, err := x509.ParsePKCS8PrivateKey(keyBlock.Bytes)
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
 	if err != nil {
 		return sterror.E(ErrScope, ErrOpOSPkgSign, ErrSign, err.Error())
 	}
 
-	cert, err := x509.ParseCertificate(certBlock.Bytes)
-	if err != nil {
-		return sterror.E(ErrScope, ErrOpOSPkgSign, ErrSign, err.Error())
+	pub, ok := key.Public().(interface{ Equal(x crypto.PublicKey) bool })
+	if !ok || !pub.Equal(cert.PublicKey) {
+		stlog.Debug("public key of signer does not match certificate")
+
+		return sterror.E(ErrScope, ErrOpOSPkgSign, ErrSign, "public key does not match certificate")
 	}
 
 	// check for duplicate certificates
@@ -361,11 +414,16 @@ func (osp *OSPackage) Sign(keyBlock, certBlock *pem.Block) error {
 
 	// sign with private key
 
-	sig, err := osp.signer.Sign(priv, osp.hash[:])
+	sig, err := osp.signer.Sign(key, osp.hash[:])
 	if err != nil {
 		return sterror.E(ErrScope, ErrOpOSPkgSign, ErrSign, err.Error())
 	}
 
+	// external signers are not trusted to produce valid signatures.
+	if err := osp.signer.Verify(sig, osp.hash[:], cert.PublicKey); err != nil {
+		return sterror.E(ErrScope, ErrOpOSPkgSign, ErrSign, err.Error())
+	}
+
 	certPEM := pem.EncodeToMemory(certBlock)
 	osp.descriptor.Certificates = append(osp.descriptor.Certificates, certPEM)
 	osp.descriptor.Signatures = append(osp.descriptor.Signatures, sig)
@@ -385,18 +443,74 @@ func (osp *OSPackage) Sign(keyBlock, certBlock *pem.Block) error {
 //
 //nolint:nonamedreturns
 func (osp *OSPackage) Verify(rootCert *x509.Certificate) (found, valid int, err error) {
-	found = 0
-	valid = 0
+	results := osp.checkSignatures(rootCert, osp.hash)
+
+	for iter, res := range results {
+		found++
+
+		switch res.status {
+		case SigValid:
+			valid++
+		case SigBadCert:
+			return 0, 0, sterror.E(ErrScope, ErrOpOSPkgVerify, ErrVrfy, fmt.Sprintf("could not parse cert %d: %v", iter+1, res.err))
+		case SigUntrustedCert:
+			stlog.Debug("skip signature %d: invalid certificate: %v", iter+1, res.err)
+		case SigDuplicate:
+			stlog.Debug("skip signature %d: dublicate", iter+1)
+		case SigInvalid:
+			stlog.Debug("skip signature %d: verification failed: %v", iter+1, res.err)
+		case SigUnchecked:
+		}
+	}
+
+	osp.isVerified = true
+
+	return found, valid, nil
+}
+
+// SignatureStatus is the outcome of verifying a single signature of an OS package.
+type SignatureStatus string
+
+// Possible outcomes of a signature verification.
+const (
+	SigValid         SignatureStatus = "valid"
+	SigBadCert       SignatureStatus = "malformed certificate"
+	SigUntrustedCert SignatureStatus = "untrusted certificate"
+	SigDuplicate     SignatureStatus = "duplicate certificate"
+	SigInvalid       SignatureStatus = "invalid signature"
+	SigUnchecked     SignatureStatus = "not verified"
+)
+
+type sigResult struct {
+	cert   *x509.Certificate
+	status SignatureStatus
+	err    error
+}
 
+// checkSignatures verifies each signature of the descriptor over hash.
+// If rootCert is nil, only the certificates are parsed.
+func (osp *OSPackage) checkSignatures(rootCert *x509.Certificate, hash [32]byte) []sigResult {
+	results := make([]sigResult, 0, len(osp.descriptor.Signatures))
 	certsUsed := make([]*x509.Certificate, 0, len(osp.descriptor.Signatures))
 
 	for iter, sig := range osp.descriptor.Signatures {
-		found++
+		if iter >= len(osp.descriptor.Certificates) {
+			results = append(results, sigResult{status: SigBadCert, err: errors.New("missing certificate")})
 
-		cert, err := osp.parseCert(osp.descriptor.Certificates[iter])
+			continue
+		}
 
+		cert, err := osp.parseCert(osp.descriptor.Certificates[iter])
 		if err != nil {
-			return 0, 0, sterror.E(ErrScope, ErrOpOSPkgVerify, ErrVrfy, fmt.Sprintf("could not parse cert %d: %v", iter+1, err))
+			results = append(results, sigResult{status: SigBadCert, err: err})
+
+			continue
+		}
+
+		if rootCert == nil {
+			results = append(results, sigResult{cert: cert, status: SigUnchecked})
+
+			continue
 		}
 
 		// verify certificate: only make sure that cert was signed by roots.
@@ -409,7 +523,7 @@ func (osp *OSPackage) Verify(rootCert *x509.Certificate) (found, valid int, err
 		}
 
 		if _, err = cert.Verify(opts); err != nil {
-			stlog.Debug("skip signature %d: invalid certificate: %v", iter+1, err)
+			results = append(results, sigResult{cert: cert, status: SigUntrustedCert, err: err})
 
 			continue
 		}
@@ -425,25 +539,24 @@ func (osp *OSPackage) Verify(rootCert *x509.Certificate) (found, valid int, err
 		}
 
 		if duplicate {
-			stlog.Debug("skip signature %d: dublicate", iter+1)
+			results = append(results, sigResult{cert: cert, status: SigDuplicate})
 
 			continue
 		}
 
 		certsUsed = append(certsUsed, cert)
 
-		err = osp.signer.Verify(sig, osp.hash[:], cert.PublicKey)
+		err = osp.signer.Verify(sig, hash[:], cert.PublicKey)
 		if err != nil {
-			stlog.Debug("skip signature %d: verification failed: %v", iter+1, err)
+			results = append(results, sigResult{cert: cert, status: SigInvalid, err: err})
 
 			continue
 		}
-		valid++
-	}
 
-	osp.isVerified = true
+		results = append(results, sigResult{cert: cert, status: SigValid})
+	}
 
-	return found, valid, nil
+	return results
 }
 
 // OSImage returns a LinuxImage from osp. LinuxImage implements boot.
@@ -0,0 +1,207 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ospkg
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// opaqueSigner hides the concrete private key type, like an HSM backed
+// crypto.Signer would.
+type opaqueSigner struct {
+	key crypto.Signer
+}
+
+func (o opaqueSigner) Public() crypto.PublicKey {
+	return o.key.Public()
+}
+
+func (o opaqueSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return o.key.Sign(rand, digest, opts)
+}
+
+func mkCert(t *testing.T, pub crypto.PublicKey, parent *x509.Certificate, parentKey crypto.Signer) *x509.Certificate {
+	t.Helper()
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: serial.String()},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  parent == nil,
+		BasicConstraintsValid: true,
+	}
+
+	if parent == nil {
+		parent = template
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, pub, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert
+}
+
+func mkOSPackage(t *testing.T) *OSPackage {
+	t.Helper()
+
+	dir := t.TempDir()
+	kernel := filepath.Join(dir, "kernel")
+	initramfs := filepath.Join(dir, "initramfs")
+
+	for _, f := range []string{kernel, initramfs} {
+		if err := os.WriteFile(f, []byte(filepath.Base(f)), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	osp, err := CreateOSPackage("test", "https://example.org/ospkg.zip", kernel, initramfs, "console=ttyS0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := osp.ArchiveBytes(); err != nil {
+		t.Fatal(err)
+	}
+
+	return osp
+}
+
+func TestSignWith(t *testing.T) {
+	rootPub, rootPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root := mkCert(t, rootPub, nil, rootPriv)
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert := mkCert(t, pub, root, rootPriv)
+	certBlock := &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}
+
+	osp := mkOSPackage(t)
+
+	if err := osp.SignWith(opaqueSigner{priv}, certBlock); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	found, valid, err := osp.Verify(root)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if found != 1 || valid != 1 {
+		t.Errorf("got %d found and %d valid signatures, want 1 and 1", found, valid)
+	}
+
+	if err := osp.SignWith(opaqueSigner{priv}, certBlock); err == nil {
+		t.Error("expect an error when signing twice with the same certificate")
+	}
+}
+
+func TestSignWithKeyMismatch(t *testing.T) {
+	rootPub, rootPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root := mkCert(t, rootPub, nil, rootPriv)
+
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, otherPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert := mkCert(t, pub, root, rootPriv)
+	osp := mkOSPackage(t)
+
+	err = osp.SignWith(opaqueSigner{otherPriv}, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	if err == nil {
+		t.Fatal("expect an error")
+	}
+
+	if len(osp.descriptor.Signatures) != 0 {
+		t.Error("signature must not be added on failure")
+	}
+}
+
+func TestNewOSPackageContentAddressed(t *testing.T) {
+	osp := mkOSPackage(t)
+	if err := osp.AddArchiveHash(); err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := osp.ArchiveBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	descriptor, err := osp.DescriptorBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := NewOSPackage(archive, descriptor)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if hash := got.ArchiveHash(); fmt.Sprintf("%x", hash) != got.descriptor.PkgSHA256 {
+		t.Errorf("got hash %x, want %s", hash, got.descriptor.PkgSHA256)
+	}
+
+	tampered := append([]byte(nil), archive...)
+	tampered[len(tampered)-1] ^= 1
+
+	if _, err := NewOSPackage(tampered, descriptor); !errors.Is(err, ErrHashMismatch) {
+		t.Errorf("got %v, want %v", err, ErrHashMismatch)
+	}
+
+	osp.descriptor.PkgSHA256 = strings.ToUpper(osp.descriptor.PkgSHA256)
+
+	descriptor, err = osp.DescriptorBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewOSPackage(archive, descriptor); !errors.Is(err, ErrGenerateData) {
+		t.Errorf("got %v, want %v", err, ErrGenerateData)
+	}
+}
@@ -69,16 +69,21 @@ var _ Signer = RSAPSSSigner{}
 
 // Sign signes the provided data with the key named by privKey. The returned
 // byte slice contains a PSS signature value.
+// Besides *rsa.PrivateKey, any crypto.Signer holding an RSA public key is accepted.
 // Problems are reported by an error wrapping SigningError.
 func (RSAPSSSigner) Sign(key crypto.PrivateKey, data []byte) ([]byte, error) {
-	priv, ok := key.(*rsa.PrivateKey)
+	signer, ok := key.(crypto.Signer)
 	if !ok {
 		return nil, sterror.E(ErrScope, ErrOpRSASSign, ErrInvalidKey, fmt.Sprintf(ErrInfoInvalidKey, key, "rsa.PublicKey"))
 	}
 
-	opts := &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}
+	if _, ok := signer.Public().(*rsa.PublicKey); !ok {
+		return nil, sterror.E(ErrScope, ErrOpRSASSign, ErrInvalidKey, fmt.Sprintf(ErrInfoInvalidKey, key, "rsa.PublicKey"))
+	}
+
+	opts := &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256}
 
-	ret, err := rsa.SignPSS(rand.Reader, priv, crypto.SHA256, data, opts)
+	ret, err := signer.Sign(rand.Reader, data, opts)
 	if err != nil {
 		return nil, sterror.E(ErrScope, ErrOpRSASSign, ErrSigning, err.Error())
 	}
@@ -108,14 +113,29 @@ type ED25519Signer struct{}
 var _ Signer = ED25519Signer{}
 
 // Sign signes the provided data with the key named by privKey.
+// Besides ed25519.PrivateKey, any crypto.Signer holding an Ed25519 public key is accepted.
 // Problems are reported by an error wrapping SigningError.
 func (ED25519Signer) Sign(key crypto.PrivateKey, data []byte) ([]byte, error) {
-	priv, ok := key.(ed25519.PrivateKey)
+	if priv, ok := key.(ed25519.PrivateKey); ok {
+		return ed25519.Sign(priv, data), nil
+	}
+
+	signer, ok := key.(crypto.Signer)
 	if !ok {
 		return nil, sterror.E(ErrScope, ErrOpEDSSign, ErrInvalidKey, fmt.Sprintf(ErrInfoInvalidKey, key, "ed25519.PublicKey"))
 	}
 
-	return ed25519.Sign(priv, data), nil
+	if _, ok := signer.Public().(ed25519.PublicKey); !ok {
+		return nil, sterror.E(ErrScope, ErrOpEDSSign, ErrInvalidKey, fmt.Sprintf(ErrInfoInvalidKey, key, "ed25519.PublicKey"))
+	}
+
+	// Ed25519 signs the message itself, crypto.Hash(0) is mandatory.
+	sig, err := signer.Sign(rand.Reader, data, crypto.Hash(0))
+	if err != nil {
+		return nil, sterror.E(ErrScope, ErrOpEDSSign, ErrSigning, err.Error())
+	}
+
+	return sig, nil
 }
 
 // Verify checks if sig contains a valid signature of hash.
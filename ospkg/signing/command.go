@@ -0,0 +1,86 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package signing
+
+import (
+	"crypto"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"system-transparency.org/stboot/sterror"
+)
+
+// SchemeEnv is the name of the environment variable telling an external
+// signing program which signature scheme is requested. Its value is either
+// "ed25519" or "rsa-pss-sha256".
+const SchemeEnv = "STBOOT_SIGN_SCHEME"
+
+// Command implements crypto.Signer by running an external program.
+//
+// The data to be signed is written to the program's stdin. For Ed25519 this is
+// the message itself, for RSA-PSS it is the SHA-256 digest. The program must
+// write the raw signature to stdout and exit with status 0. The requested
+// signature scheme is passed in the environment variable named by SchemeEnv.
+type Command struct {
+	// Path is the program to run, looked up in PATH if it contains no slash.
+	Path string
+	// Args are passed to the program.
+	Args []string
+	// Timeout limits the run time of the program. Zero means DefaultTimeout.
+	Timeout time.Duration
+
+	public crypto.PublicKey
+}
+
+var _ crypto.Signer = &Command{}
+
+// NewCommand returns a Command running path with args. The public key pub is
+// usually taken from the signing certificate.
+func NewCommand(pub crypto.PublicKey, path string, args ...string) *Command {
+	return &Command{
+		Path:   path,
+		Args:   args,
+		public: pub,
+	}
+}
+
+// ParseCommand returns a Command running the program and arguments given by
+// the whitespace separated fields of line.
+func ParseCommand(pub crypto.PublicKey, line string) (*Command, error) {
+	argv := strings.Fields(line)
+	if len(argv) == 0 {
+		return nil, ErrEmptyCommand
+	}
+
+	return NewCommand(pub, argv[0], argv[1:]...), nil
+}
+
+// Public implements crypto.Signer.
+func (c *Command) Public() crypto.PublicKey {
+	return c.public
+}
+
+// Sign implements crypto.Signer. The rand argument is ignored.
+func (c *Command) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	scheme, err := schemeFor(c.public, opts)
+	if err != nil {
+		return nil, sterror.E(ErrScope, ErrOpCommandSign, ErrUnsupported, err.Error())
+	}
+
+	env := append(os.Environ(), SchemeEnv+"="+scheme.String())
+
+	sig, err := run(c.Timeout, digest, env, c.Path, c.Args...)
+	if err != nil {
+		return nil, sterror.E(ErrScope, ErrOpCommandSign, ErrSign, err.Error())
+	}
+
+	if len(sig) == 0 {
+		return nil, sterror.E(ErrScope, ErrOpCommandSign, ErrSign, "empty signature")
+	}
+
+	return sig, nil
+}
@@ -0,0 +1,161 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package signing
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"testing"
+)
+
+const (
+	helperEnv    = "STBOOT_SIGNING_HELPER"
+	helperKeyEnv = "STBOOT_SIGNING_HELPER_KEY"
+)
+
+// TestHelperProcess is not a real test. It acts as the external signing
+// program in other tests: it signs stdin with the Ed25519 seed passed in
+// the environment.
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv(helperEnv) != "1" {
+		return
+	}
+
+	if os.Getenv(SchemeEnv) != "ed25519" {
+		os.Exit(2)
+	}
+
+	seed, err := hex.DecodeString(os.Getenv(helperKeyEnv))
+	if err != nil {
+		os.Exit(3)
+	}
+
+	msg, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		os.Exit(4)
+	}
+
+	os.Stdout.Write(ed25519.Sign(ed25519.NewKeyFromSeed(seed), msg)) //nolint:errcheck
+	os.Exit(0)
+}
+
+func helperCommand(t *testing.T, priv ed25519.PrivateKey) *Command {
+	t.Helper()
+
+	t.Setenv(helperEnv, "1")
+	t.Setenv(helperKeyEnv, hex.EncodeToString(priv.Seed()))
+
+	return NewCommand(priv.Public(), os.Args[0], "-test.run=TestHelperProcess")
+}
+
+func TestCommandSign(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer := helperCommand(t, priv)
+	msg := []byte("message")
+
+	sig, err := signer.Sign(nil, msg, crypto.Hash(0))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !ed25519.Verify(pub, msg, sig) {
+		t.Error("invalid signature")
+	}
+}
+
+func TestParseCommand(t *testing.T) {
+	cmd, err := ParseCommand("key", " sign-tool  --slot 1 ")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cmd.Path != "sign-tool" || len(cmd.Args) != 2 || cmd.Args[0] != "--slot" || cmd.Args[1] != "1" {
+		t.Errorf("got %s %q, want sign-tool [--slot 1]", cmd.Path, cmd.Args)
+	}
+
+	for _, line := range []string{"", " ", "\t\n"} {
+		if _, err := ParseCommand("key", line); !errors.Is(err, ErrEmptyCommand) {
+			t.Errorf("%q: got %v, want %v", line, err, ErrEmptyCommand)
+		}
+	}
+}
+
+func TestCommandSignFailure(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer := helperCommand(t, priv)
+	t.Setenv(helperKeyEnv, "not hex")
+
+	_, err = signer.Sign(nil, []byte("message"), crypto.Hash(0))
+	if !errors.Is(err, ErrSign) {
+		t.Errorf("got %v, want error wrapping %v", err, ErrSign)
+	}
+}
+
+func TestCommandUnsupported(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digest := sha256.Sum256([]byte("message"))
+
+	tests := []struct {
+		name string
+		pub  crypto.PublicKey
+		opts crypto.SignerOpts
+	}{
+		{
+			name: "Ed25519 pre-hashed",
+			pub:  edPub,
+			opts: crypto.SHA256,
+		},
+		{
+			name: "RSA PKCS#1 v1.5",
+			pub:  &rsaKey.PublicKey,
+			opts: crypto.SHA256,
+		},
+		{
+			name: "RSA-PSS SHA-512",
+			pub:  &rsaKey.PublicKey,
+			opts: &rsa.PSSOptions{Hash: crypto.SHA512},
+		},
+		{
+			name: "Unknown key",
+			pub:  "key",
+			opts: crypto.Hash(0),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer := NewCommand(tt.pub, "false")
+
+			_, err := signer.Sign(nil, digest[:], tt.opts)
+			if !errors.Is(err, ErrUnsupported) {
+				t.Errorf("got %v, want error wrapping %v", err, ErrUnsupported)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package signing
+
+import (
+	"crypto"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"system-transparency.org/stboot/sterror"
+)
+
+const (
+	// DefaultPKCS11Tool is the OpenSC utility used to talk to PKCS#11 modules.
+	DefaultPKCS11Tool = "pkcs11-tool"
+
+	pinEnv = "STBOOT_PKCS11_PIN"
+)
+
+// PKCS11 implements crypto.Signer for a private key stored in a PKCS#11 token,
+// e.g. an HSM or SoftHSM. The key never leaves the token, signing operations
+// are delegated to OpenSC's pkcs11-tool.
+type PKCS11 struct {
+	// Module is the path to the PKCS#11 shared library of the token.
+	Module string
+	// TokenLabel selects the token. If empty, the first token with a
+	// matching key is used.
+	TokenLabel string
+	// KeyID is the hex encoded CKA_ID of the private key.
+	KeyID string
+	// PIN is the user PIN of the token. It is passed to pkcs11-tool via its
+	// environment, not on the command line.
+	PIN string
+	// Tool is the pkcs11-tool binary. Empty means DefaultPKCS11Tool.
+	Tool string
+	// Timeout limits the run time of a single operation. Zero means DefaultTimeout.
+	Timeout time.Duration
+
+	public crypto.PublicKey
+}
+
+var _ crypto.Signer = &PKCS11{}
+
+// NewPKCS11 returns a PKCS11 signer for the key with the hex encoded id keyID
+// in the token labeled tokenLabel. The public key pub is usually taken from
+// the signing certificate.
+func NewPKCS11(pub crypto.PublicKey, module, tokenLabel, keyID, pin string) *PKCS11 {
+	return &PKCS11{
+		Module:     module,
+		TokenLabel: tokenLabel,
+		KeyID:      keyID,
+		PIN:        pin,
+		public:     pub,
+	}
+}
+
+// Public implements crypto.Signer.
+func (p *PKCS11) Public() crypto.PublicKey {
+	return p.public
+}
+
+// Sign implements crypto.Signer. The rand argument is ignored, randomness
+// is provided by the token.
+func (p *PKCS11) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	scheme, err := schemeFor(p.public, opts)
+	if err != nil {
+		return nil, sterror.E(ErrScope, ErrOpPKCS11Sign, ErrUnsupported, err.Error())
+	}
+
+	dir, err := os.MkdirTemp("", "stboot-pkcs11-")
+	if err != nil {
+		return nil, sterror.E(ErrScope, ErrOpPKCS11Sign, ErrSign, err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, "signature")
+
+	args := []string{"--module", p.Module, "--sign", "--id", p.KeyID, "--output-file", out}
+	if p.TokenLabel != "" {
+		args = append(args, "--token-label", p.TokenLabel)
+	}
+
+	if p.PIN != "" {
+		args = append(args, "--login", "--pin", "env:"+pinEnv)
+	}
+
+	mech, err := mechanism(scheme)
+	if err != nil {
+		return nil, sterror.E(ErrScope, ErrOpPKCS11Sign, ErrUnsupported, err.Error())
+	}
+
+	args = append(args, mech...)
+
+	tool := p.Tool
+	if tool == "" {
+		tool = DefaultPKCS11Tool
+	}
+
+	env := append(os.Environ(), pinEnv+"="+p.PIN)
+
+	if _, err := run(p.Timeout, digest, env, tool, args...); err != nil {
+		return nil, sterror.E(ErrScope, ErrOpPKCS11Sign, ErrSign, err.Error())
+	}
+
+	sig, err := os.ReadFile(out)
+	if err != nil {
+		return nil, sterror.E(ErrScope, ErrOpPKCS11Sign, ErrSign, err.Error())
+	}
+
+	if len(sig) == 0 {
+		return nil, sterror.E(ErrScope, ErrOpPKCS11Sign, ErrSign, "empty signature")
+	}
+
+	return sig, nil
+}
+
+// mechanism returns the pkcs11-tool arguments selecting the PKCS#11 mechanism
+// for s. The input is never hashed by the token.
+func mechanism(s scheme) ([]string, error) {
+	switch s {
+	case schemeEd25519:
+		return []string{"--mechanism", "EDDSA"}, nil
+	case schemeRSAPSSSHA256:
+		return []string{"--mechanism", "RSA-PKCS-PSS", "--hash-algorithm", "SHA256", "--mgf", "MGF1-SHA256"}, nil
+	default:
+		return nil, sterror.E(ErrScope, ErrOpmechanism, ErrUnsupported, fmt.Sprintf("scheme %s", s))
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package signing
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/x509"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// softHSMModules lists common install locations of the SoftHSM v2 module.
+var softHSMModules = []string{
+	"/usr/lib/softhsm/libsofthsm2.so",
+	"/usr/lib/x86_64-linux-gnu/softhsm/libsofthsm2.so",
+	"/usr/lib64/pkcs11/libsofthsm2.so",
+	"/usr/local/lib/softhsm/libsofthsm2.so",
+}
+
+// softHSM initializes a fresh SoftHSM token with an Ed25519 key pair and
+// returns the module path and the public key. The test is skipped if SoftHSM
+// or OpenSC are not installed.
+func softHSM(t *testing.T, label, pin, id string) (string, ed25519.PublicKey) {
+	t.Helper()
+
+	for _, tool := range []string{"softhsm2-util", DefaultPKCS11Tool} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not installed", tool)
+		}
+	}
+
+	var module string
+
+	for _, m := range softHSMModules {
+		if _, err := os.Stat(m); err == nil {
+			module = m
+
+			break
+		}
+	}
+
+	if module == "" {
+		t.Skip("SoftHSM module not found")
+	}
+
+	dir := t.TempDir()
+	conf := filepath.Join(dir, "softhsm2.conf")
+
+	if err := os.WriteFile(conf, []byte("directories.tokendir = "+dir+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("SOFTHSM2_CONF", conf)
+
+	cmds := [][]string{
+		{"softhsm2-util", "--init-token", "--free", "--label", label, "--so-pin", pin, "--pin", pin},
+		{DefaultPKCS11Tool, "--module", module, "--token-label", label, "--login", "--pin", pin,
+			"--keypairgen", "--key-type", "EC:edwards25519", "--id", id},
+	}
+
+	for _, c := range cmds {
+		if out, err := exec.Command(c[0], c[1:]...).CombinedOutput(); err != nil {
+			t.Fatalf("%v: %v: %s", c, err, out)
+		}
+	}
+
+	der, err := exec.Command(DefaultPKCS11Tool, "--module", module, "--token-label", label,
+		"--read-object", "--type", "pubkey", "--id", id).Output()
+	if err != nil {
+		t.Fatalf("read public key: %v", err)
+	}
+
+	pub, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		t.Skipf("cannot parse Ed25519 public key exported by %s: %v", DefaultPKCS11Tool, err)
+	}
+
+	edPub, ok := pub.(ed25519.PublicKey)
+	if !ok {
+		t.Fatalf("got %T, want ed25519.PublicKey", pub)
+	}
+
+	return module, edPub
+}
+
+func TestPKCS11Sign(t *testing.T) {
+	const (
+		label = "stboot-test"
+		pin   = "1234"
+		id    = "01"
+	)
+
+	module, pub := softHSM(t, label, pin, id)
+
+	signer := NewPKCS11(pub, module, label, id, pin)
+	if !reflect.DeepEqual(signer.Public(), crypto.PublicKey(pub)) {
+		t.Fatal("public key mismatch")
+	}
+
+	msg := []byte("message")
+
+	sig, err := signer.Sign(nil, msg, crypto.Hash(0))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !ed25519.Verify(pub, msg, sig) {
+		t.Error("invalid signature")
+	}
+}
+
+func TestPKCS11WrongPIN(t *testing.T) {
+	const (
+		label = "stboot-test"
+		pin   = "1234"
+		id    = "01"
+	)
+
+	module, pub := softHSM(t, label, pin, id)
+
+	signer := NewPKCS11(pub, module, label, id, "4321")
+
+	if _, err := signer.Sign(nil, []byte("message"), crypto.Hash(0)); err == nil {
+		t.Fatal("expect an error")
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package signing provides crypto.Signer implementations for OS package
+// signing keys that are not available in memory. They are meant to be used
+// with ospkg.OSPackage.SignWith.
+package signing
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"os/exec"
+	"time"
+
+	"system-transparency.org/stboot/sterror"
+)
+
+// Scope and operations used for raising Errors of this package.
+const (
+	ErrScope           sterror.Scope = "Signing"
+	ErrOpCommandSign   sterror.Op    = "Command.Sign"
+	ErrOpPKCS11Sign    sterror.Op    = "PKCS11.Sign"
+	ErrOprun           sterror.Op    = "run"
+	ErrOpmechanism     sterror.Op    = "mechanism"
+	ErrInfoUnsupported               = "unsupported key type %T"
+)
+
+// Errors which may be raised and wrapped in this package.
+var (
+	ErrSign         = errors.New("external signing failed")
+	ErrUnsupported  = errors.New("unsupported signing request")
+	ErrEmptyCommand = errors.New("signing command is empty")
+)
+
+// DefaultTimeout limits the run time of external signing programs.
+const DefaultTimeout = 2 * time.Minute
+
+// scheme describes the signature to be produced by a backend. Only the schemes
+// used by the signers of package ospkg are supported.
+type scheme int
+
+const (
+	schemeEd25519 scheme = iota + 1
+	schemeRSAPSSSHA256
+)
+
+// String implements fmt.Stringer.
+func (s scheme) String() string {
+	switch s {
+	case schemeEd25519:
+		return "ed25519"
+	case schemeRSAPSSSHA256:
+		return "rsa-pss-sha256"
+	default:
+		return "unknown"
+	}
+}
+
+func schemeFor(pub crypto.PublicKey, opts crypto.SignerOpts) (scheme, error) {
+	switch pub.(type) {
+	case ed25519.PublicKey:
+		if opts.HashFunc() != crypto.Hash(0) {
+			return 0, fmt.Errorf("%w: ed25519 with pre-hashed message", ErrUnsupported)
+		}
+
+		return schemeEd25519, nil
+	case *rsa.PublicKey:
+		if _, ok := opts.(*rsa.PSSOptions); !ok || opts.HashFunc() != crypto.SHA256 {
+			return 0, fmt.Errorf("%w: RSA without PSS and SHA-256", ErrUnsupported)
+		}
+
+		return schemeRSAPSSSHA256, nil
+	default:
+		return 0, fmt.Errorf("%w: "+ErrInfoUnsupported, ErrUnsupported, pub)
+	}
+}
+
+// run executes name with args, passing stdin to the program. It returns the
+// program's output on stdout.
+func run(timeout time.Duration, stdin []byte, env []string, name string, args ...string) ([]byte, error) {
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+
+	//nolint:gosec
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.Env = env
+
+	if err := cmd.Run(); err != nil {
+		return nil, sterror.E(ErrScope, ErrOprun, ErrSign, fmt.Sprintf("%s: %v: %s", name, err, bytes.TrimSpace(stderr.Bytes())))
+	}
+
+	return stdout.Bytes(), nil
+}
@@ -7,31 +7,95 @@ package ospkg
 import (
 	"archive/zip"
 	"bytes"
+	"compress/flate"
 	"fmt"
 	"io"
+	"os"
+	"strconv"
+	"time"
 
 	"system-transparency.org/stboot/sterror"
 	"system-transparency.org/stboot/stlog"
 )
 
 const (
-	ErrOpunzip = "unzipFile"
+	ErrOpunzip                  = "unzipFile"
+	ErrOparchiveTime sterror.Op = "archiveTime"
 )
 
-func zipDir(archive *zip.Writer, name string) error {
+// SourceDateEpochEnv names the environment variable holding the timestamp
+// used for all archive entries, as defined by https://reproducible-builds.org.
+// The value is the number of seconds since the Unix epoch.
+const SourceDateEpochEnv = "SOURCE_DATE_EPOCH"
+
+// Fixed properties of archive entries, so that the same input always results
+// in the same archive bytes.
+const (
+	zipFileMode        os.FileMode = 0o644
+	zipDirMode                     = os.ModeDir | 0o755
+	zipCompressionLvl              = flate.BestCompression
+	zipDefaultEpochSec             = 315532800 // 1980-01-01T00:00:00Z, the earliest time zip can store.
+)
+
+// archiveTime returns the modification time set on all archive entries.
+// It is taken from SOURCE_DATE_EPOCH if set, otherwise a fixed default is used.
+// Times before 1980 cannot be represented in zip archives and are clamped.
+func archiveTime() (time.Time, error) {
+	sec := int64(zipDefaultEpochSec)
+
+	if env, ok := os.LookupEnv(SourceDateEpochEnv); ok {
+		v, err := strconv.ParseInt(env, 10, 64)
+		if err != nil {
+			return time.Time{}, sterror.E(ErrScope, ErrOparchiveTime, ErrGenerateData,
+				fmt.Sprintf("invalid %s: %v", SourceDateEpochEnv, err))
+		}
+
+		if v > sec {
+			sec = v
+		}
+	}
+
+	return time.Unix(sec, 0).UTC(), nil
+}
+
+// newZipWriter returns a zip.Writer with fixed compression settings.
+func newZipWriter(w io.Writer) *zip.Writer {
+	zipWriter := zip.NewWriter(w)
+	zipWriter.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+		return flate.NewWriter(out, zipCompressionLvl)
+	})
+
+	return zipWriter
+}
+
+func zipHeader(name string, mode os.FileMode, modified time.Time) *zip.FileHeader {
+	header := &zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: modified,
+	}
+	header.SetMode(mode)
+
+	return header
+}
+
+func zipDir(archive *zip.Writer, name string, modified time.Time) error {
 	if name[len(name)-1:] != "/" {
 		name += "/"
 	}
 
-	if _, err := archive.Create(name); err != nil {
+	header := zipHeader(name, zipDirMode, modified)
+	header.Method = zip.Store
+
+	if _, err := archive.CreateHeader(header); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func zipFile(archive *zip.Writer, name string, src []byte) error {
-	f, err := archive.Create(name)
+func zipFile(archive *zip.Writer, name string, src []byte, modified time.Time) error {
+	f, err := archive.CreateHeader(zipHeader(name, zipFileMode, modified))
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,105 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ospkg
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestReproducibleArchive(t *testing.T) {
+	tests := []struct {
+		name  string
+		epoch string
+		want  time.Time
+	}{
+		{
+			name:  "Default timestamp",
+			epoch: "",
+			want:  time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "SOURCE_DATE_EPOCH",
+			epoch: "1672531200",
+			want:  time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "SOURCE_DATE_EPOCH before 1980",
+			epoch: "0",
+			want:  time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Set the variable in either case, so that the environment of the
+			// test run does not matter. t.Setenv restores it afterwards.
+			t.Setenv(SourceDateEpochEnv, tt.epoch)
+
+			if tt.epoch == "" {
+				if err := os.Unsetenv(SourceDateEpochEnv); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			first, err := mkOSPackage(t).ArchiveBytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			second, err := mkOSPackage(t).ArchiveBytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(first, second) {
+				t.Fatal("archives of independent builds differ")
+			}
+
+			archive, err := zip.NewReader(bytes.NewReader(first), int64(len(first)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			wantNames := []string{"boot/", "boot/kernel", "boot/initramfs", ManifestName}
+			if len(archive.File) != len(wantNames) {
+				t.Fatalf("got %d entries, want %d", len(archive.File), len(wantNames))
+			}
+
+			for i, f := range archive.File {
+				if f.Name != wantNames[i] {
+					t.Errorf("entry %d: got %q, want %q", i, f.Name, wantNames[i])
+				}
+
+				if !f.Modified.Equal(tt.want) {
+					t.Errorf("%s: got modification time %v, want %v", f.Name, f.Modified, tt.want)
+				}
+
+				wantMode := zipFileMode
+				if f.FileInfo().IsDir() {
+					wantMode = zipDirMode
+				}
+
+				if f.Mode() != wantMode {
+					t.Errorf("%s: got mode %v, want %v", f.Name, f.Mode(), wantMode)
+				}
+			}
+		})
+	}
+}
+
+func TestArchiveTimeInvalid(t *testing.T) {
+	osp := mkOSPackage(t)
+	osp.raw = nil
+
+	t.Setenv(SourceDateEpochEnv, "yesterday")
+
+	if _, err := osp.ArchiveBytes(); err == nil {
+		t.Fatal("expect an error")
+	}
+}
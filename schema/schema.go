@@ -0,0 +1,90 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package schema provides JSON Schemas of the configuration and OS package
+// formats read by stboot. They describe what stboot accepts when parsing
+// strictly: unknown keys are not allowed.
+package schema
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Format names a JSON format read by stboot.
+type Format string
+
+// Formats with a JSON Schema.
+const (
+	Descriptor  Format = "descriptor"
+	Manifest    Format = "manifest"
+	HostConfig  Format = "host_config"
+	TrustPolicy Format = "trust_policy"
+)
+
+var (
+	ErrUnknownFormat  = errors.New("unknown format")
+	ErrUnknownVersion = errors.New("unknown version")
+)
+
+//go:embed *.json
+var files embed.FS
+
+// versions lists the schema versions per format, oldest first.
+var versions = map[Format][]int{
+	Descriptor:  {1},
+	Manifest:    {1},
+	HostConfig:  {0},
+	TrustPolicy: {1, 2},
+}
+
+// Formats returns all formats with a JSON Schema.
+func Formats() []Format {
+	ret := make([]Format, 0, len(versions))
+	for f := range versions {
+		ret = append(ret, f)
+	}
+
+	sort.Slice(ret, func(i, j int) bool { return ret[i] < ret[j] })
+
+	return ret
+}
+
+// Versions returns the supported versions of format f, oldest first.
+func Versions(f Format) ([]int, error) {
+	v, ok := versions[f]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, f)
+	}
+
+	return append([]int(nil), v...), nil
+}
+
+// Latest returns the JSON Schema of the newest version of format f.
+func Latest(f Format) ([]byte, error) {
+	v, err := Versions(f)
+	if err != nil {
+		return nil, err
+	}
+
+	return Get(f, v[len(v)-1])
+}
+
+// Get returns the JSON Schema of version of format f.
+func Get(f Format, version int) ([]byte, error) {
+	v, err := Versions(f)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, known := range v {
+		if known == version {
+			return files.ReadFile(fmt.Sprintf("%s.v%d.json", f, version))
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s version %d", ErrUnknownVersion, f, version)
+}
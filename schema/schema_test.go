@@ -0,0 +1,107 @@
+// Copyright 2023 the System Transparency Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"testing"
+
+	"system-transparency.org/stboot/host"
+	"system-transparency.org/stboot/internal/jsonutil"
+	"system-transparency.org/stboot/ospkg"
+)
+
+// TestProperties makes sure the latest schemas match the Go types.
+func TestProperties(t *testing.T) {
+	types := map[Format]struct {
+		names    []string
+		required []string
+	}{
+		Descriptor: {names: jsonutil.Names(ospkg.Descriptor{}), required: jsonutil.Required(ospkg.Descriptor{})},
+		Manifest:   {names: jsonutil.Names(ospkg.OSManifest{}), required: jsonutil.Required(ospkg.OSManifest{})},
+		HostConfig: {
+			names:    append(jsonutil.Names(host.Config{}), "version", "provisioning_urls", "timestamp", "network_interface"),
+			required: jsonutil.Required(host.Config{}),
+		},
+		TrustPolicy: {
+			names:    []string{"version", "ospkg_signature_threshold", "ospkg_fetch_methods", "measurement", "host_config_auth", "time_sync", "tls_pins", "https_roots"},
+			required: []string{"version", "ospkg_signature_threshold", "ospkg_fetch_methods"},
+		},
+	}
+
+	if len(types) != len(Formats()) {
+		t.Fatalf("got %d formats, want %d", len(Formats()), len(types))
+	}
+
+	for format, typ := range types {
+		data, err := Latest(format)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+
+		var s struct {
+			Properties           map[string]json.RawMessage `json:"properties"`
+			Required             []string                   `json:"required"`
+			AdditionalProperties *bool                      `json:"additionalProperties"`
+		}
+
+		if err := json.Unmarshal(data, &s); err != nil {
+			t.Fatalf("%s: invalid schema: %v", format, err)
+		}
+
+		if s.AdditionalProperties == nil || *s.AdditionalProperties {
+			t.Errorf("%s: additional properties must be disallowed", format)
+		}
+
+		props := make([]string, 0, len(s.Properties))
+		for p := range s.Properties {
+			props = append(props, p)
+		}
+
+		if !equal(props, typ.names) {
+			t.Errorf("%s: got properties %v, want %v", format, props, typ.names)
+		}
+
+		if !equal(s.Required, typ.required) {
+			t.Errorf("%s: got required %v, want %v", format, s.Required, typ.required)
+		}
+	}
+}
+
+func TestGet(t *testing.T) {
+	if _, err := Get(Descriptor, 1); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if _, err := Get(Descriptor, 100); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("got %v, want %v", err, ErrUnknownVersion)
+	}
+
+	if _, err := Get("foo", 1); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("got %v, want %v", err, ErrUnknownFormat)
+	}
+}
+
+func equal(a, b []string) bool {
+	a = append([]string(nil), a...)
+	b = append([]string(nil), b...)
+
+	sort.Strings(a)
+	sort.Strings(b)
+
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
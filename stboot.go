@@ -7,10 +7,12 @@ package main
 import (
 	"bytes"
 	"context"
+	"crypto/ed25519"
 	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"flag"
+	"fmt"
 	"io"
 	"log"
 	"net/url"
@@ -27,12 +29,16 @@ import (
 	"system-transparency.org/stboot/opts"
 	"system-transparency.org/stboot/ospkg"
 	"system-transparency.org/stboot/stlog"
+	"system-transparency.org/stboot/trust"
 )
 
 const (
 	logLevelHelp = "Log level: e 'errors' w 'warn', i 'info', d 'debug'."
 	dryRunHelp   = "Stop before kexec-ing into the loaded OS kernel"
 	deadlineHelp = "Timeout in minutes for download operations (default: 20)"
+	layeredHelp  = "Merge all host configuration sources, later sources override single fields"
+	hostCfgHelp  = "Comma separated host configuration sources in probing order: initramfs, efivar, cmdline, smbios, fw_cfg, label"
+	netParamHelp = "Pass the network configuration on to the OS kernel as ip= command line parameters"
 )
 
 // Files at initramfs.
@@ -40,7 +46,8 @@ const (
 	trustPolicyFile = "/etc/trust_policy/trust_policy.json"
 	signingRootFile = "/etc/trust_policy/ospkg_signing_root.pem"
 
-	// For HTTPS roots only Let's Encrypt ISRG Root X1 is used.
+	// Let's Encrypt ISRG Root X1 is the HTTPS root, unless the trust policy
+	// names trust bundles.
 	httpsRootsFile = "/etc/ssl/certs/isrgrootx1.pem"
 
 	// OS package files (optional).
@@ -79,10 +86,16 @@ func (e Error) Error() string {
 	return string(e)
 }
 
+// openTrustPolicyFile opens a file stored next to the trust policy.
+func openTrustPolicyFile(name string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(filepath.Dir(trustPolicyFile), name))
+}
+
 type ospkgSample struct {
 	name       string
 	descriptor io.ReadCloser
 	archive    io.ReadCloser
+	netState   *network.State
 }
 
 //nolint:funlen,maintidx,gocyclo,cyclop,gocognit,gomnd
@@ -92,6 +105,9 @@ func main() {
 	logLevel := flag.String("loglevel", "info", logLevelHelp)
 	dryRun := flag.Bool("dryrun", false, dryRunHelp)
 	deadline := flag.Int("deadline", 20, deadlineHelp)
+	hostCfgLayered := flag.Bool("hostcfg-layered", false, layeredHelp)
+	hostCfgSources := flag.String("hostcfg", "initramfs,efivar", hostCfgHelp)
+	netParams := flag.Bool("net-params", false, netParamHelp)
 
 	flag.Parse()
 
@@ -123,36 +139,51 @@ func main() {
 		host.Recover()
 	}
 
-	httpsRootsSrc, err := os.Open(httpsRootsFile)
-	if err != nil {
-		stlog.Error("HTTPS root certificates: %v", err)
-		host.Recover()
-	}
-
 	trustPolicySrc, err := os.Open(trustPolicyFile)
 	if err != nil {
 		stlog.Error("security configuration: %v", err)
 		host.Recover()
 	}
 
-	hostCfgSrc, err := host.ConfigAutodetect()
+	sources, err := host.ParseConfigSources(*hostCfgSources)
 	if err != nil {
-		stlog.Error("host configuration autodetect: %v", err)
+		stlog.Error("host configuration sources: %v", err)
 		host.Recover()
 	}
 
+	var hostCfgLoader opts.Loader
+
+	if *hostCfgLayered {
+		hostCfgLoader = opts.WithLayeredHostCfg(func(key ed25519.PublicKey) (*host.LayeredConfig, error) {
+			return host.ConfigLayered(key, sources...)
+		})
+	} else {
+		hostCfgSrc, err := host.ConfigAutodetect(sources...)
+		if err != nil {
+			stlog.Error("host configuration autodetect: %v", err)
+			host.Recover()
+		}
+
+		hostCfgLoader = opts.WithHostCfg(hostCfgSrc)
+	}
+
 	stOptions, err := opts.NewOpts(
 		opts.WithTrustPolicy(trustPolicySrc),
-		opts.WithHostCfg(hostCfgSrc),
+		opts.WithHostCfgKey(openTrustPolicyFile),
+		hostCfgLoader,
 		opts.WithSigningRootCert(signingRootSrc),
-		opts.WithHTTPSRootCerts(httpsRootsSrc))
+		opts.WithHTTPSRootBundles(filepath.Dir(trustPolicyFile), httpsRootsFile))
 	if err != nil {
 		stlog.Error("load opts: %v", err)
 		host.Recover()
 	}
 
+	addSecrets(&stOptions.HostCfg)
+
 	if name := *stOptions.HostCfg.OSPkgPointer; name == host.HostConfigProvisionOSPKGName {
-		stOptions.TrustPolicy.FetchMethod = ospkg.FetchFromInitramfs
+		if stOptions.TrustPolicy.Version != trust.PolicyVersion2 {
+			stOptions.TrustPolicy.FetchMethod = ospkg.FetchFromInitramfs
+		}
 	}
 
 	optsStr, err := json.MarshalIndent(stOptions, "", "  ")
@@ -162,98 +193,50 @@ func main() {
 		stlog.Debug("Opts: %s", optsStr)
 	}
 
-	if stOptions.TrustPolicy.FetchMethod == ospkg.FetchFromNetwork {
-		err := network.SetupNetworkInterface(&stOptions.HostCfg)
-		if err != nil {
-			stlog.Error("failed to setup network interfaces: %v", err)
-			host.Recover()
-		}
-	}
-
 	//////////////////
 	// Load OS package
 	//////////////////
 
-	var sample *ospkgSample
-
-	switch stOptions.TrustPolicy.FetchMethod {
-	case ospkg.FetchFromNetwork:
-		stlog.Info("Loading OS package via network")
-
-		if len(stOptions.HTTPSRoots) == 0 {
-			stlog.Error("httpsRoots must not be empty")
-			host.Recover()
-		}
-
-		client := network.NewHTTPClient(stOptions.HTTPSRoots, false)
-
-		stlog.Debug("OS package pointer: %s", *stOptions.HostCfg.OSPkgPointer)
-
-		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(*deadline)*time.Minute)
-		defer cancel()
-
-		sample, err = fetchOspkgNetwork(ctx, client, &stOptions.HostCfg)
-		if err != nil {
-			stlog.Error("fetching OS package via network failed: %v", err)
-			host.Recover()
-		}
-	case ospkg.FetchFromInitramfs:
-		stlog.Info("Loading OS package from initramfs")
-
-		sample, err = fetchOspkgInitramfs(&stOptions.HostCfg)
-		if err != nil {
-			stlog.Error("fetching OS package from initramfs failed: %v", err)
-			host.Recover()
-		}
-	default:
-		stlog.Error("unknown OS package fetch method %q", stOptions.TrustPolicy.FetchMethod)
+	methods, err := fetchMethods(&stOptions.TrustPolicy, &stOptions.HostCfg)
+	if err != nil {
+		stlog.Error("%v", err)
 		host.Recover()
 	}
 
-	//////////////////////
-	// Process OS packages
-	//////////////////////
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(*deadline)*time.Minute)
+	defer cancel()
 
-	stlog.Info("Processing OS package %q", sample.name)
+	var (
+		sample *ospkgSample
+		osp    *ospkg.OSPackage
+	)
 
-	aBytes, err := io.ReadAll(sample.archive)
-	if err != nil {
-		stlog.Error("Read archive: %v", err)
-		host.Recover()
-	}
+	// A method only succeeds with an OS package that passes verification.
+	for _, method := range methods {
+		sample, err = fetchOspkg(ctx, method, stOptions)
+		if err == nil {
+			stlog.Info("Processing OS package %q", sample.name)
 
-	dBytes, err := io.ReadAll(sample.descriptor)
-	if err != nil {
-		stlog.Error("Read archive: %v", err)
-		host.Recover()
-	}
-
-	osp, err := ospkg.NewOSPackage(aBytes, dBytes)
-	if err != nil {
-		stlog.Error("Create OS package: %v", err)
-		host.Recover()
-	}
+			osp, err = verifyOspkg(sample, stOptions)
+			if err == nil {
+				break
+			}
+		}
 
-	////////////////////
-	// Verify OS package
-	////////////////////
+		stlog.Warn("OS package via %s failed: %v", method.Method, err)
 
-	//nolint:godox
-	// TODO: write ospkg.info method for debug output
+		if sample != nil {
+			teardownNetwork(sample.netState)
+		}
 
-	numSig, valid, err := osp.Verify(stOptions.SigningRoot)
-	if err != nil {
-		stlog.Error("Verifying OS package: %v", err)
-		host.Recover()
+		sample, osp = nil, nil
 	}
 
-	threshold := stOptions.TrustPolicy.SignatureThreshold
-	if valid < threshold {
-		stlog.Error("Not enough valid signatures: %d found, %d valid, %d required", numSig, valid, threshold)
+	if osp == nil {
+		stlog.Error("no valid OS package for any allowed method")
 		host.Recover()
 	}
 
-	stlog.Debug("Signatures: %d found, %d valid, %d required", numSig, valid, threshold)
 	stlog.Info("OS package passed verification")
 	stlog.Info(check)
 
@@ -278,9 +261,17 @@ func main() {
 	///////////////////////
 	stlog.Info("Try TPM measurements")
 
+	measurementRequired := stOptions.TrustPolicy.MeasurementRequired()
+
 	mes := host.NewMeasurements()
 
-	// PCR[12] = Details: OS package zip and manifest
+	if measurementRequired {
+		if err := mes.CheckTPM(stOptions.TrustPolicy.Measurement.MinTPMVersion); err != nil {
+			measurementFailed(true, "TPM required by trust policy: %v", err)
+		}
+	}
+
+	// PCR[12] = Details: OS package zip and manifest, host config provenance
 	// PCR[13] = Authority: Security config, Signing root, HTTPS root
 	// PCR[14] = Identity: UX identiy string and data channel's public key
 
@@ -289,32 +280,44 @@ func main() {
 
 	ospkgDescriptorBytes, err := osp.DescriptorBytes()
 	if err != nil {
-		stlog.Warn("cannot serialize manifest for measurement: %v", err)
+		measurementFailed(measurementRequired, "cannot serialize manifest for measurement: %v", err)
 	}
 
 	securityConfigBytes, err := json.Marshal(stOptions.TrustPolicy)
 	if err != nil {
-		stlog.Warn("cannot serialize security config for measurement: %v", err)
+		measurementFailed(measurementRequired, "cannot serialize security config for measurement: %v", err)
 	}
 
 	err = mes.Add(host.DetailPcr, host.OspkgArchive, ospkgArchiveHash, []byte(sample.name))
 	if err != nil {
-		stlog.Warn("cannot measure archive: %v", err)
+		measurementFailed(measurementRequired, "cannot measure archive: %v", err)
 	}
 
 	err = mes.Add(host.DetailPcr, host.OspkgManifest, ospkgDescriptorHash, ospkgDescriptorBytes)
 	if err != nil {
-		stlog.Warn("cannot measure manifest: %v", err)
+		measurementFailed(measurementRequired, "cannot measure manifest: %v", err)
+	}
+
+	if provenance := stOptions.HostCfgProvenance; provenance != nil {
+		provenanceBytes, err := json.Marshal(provenance)
+		if err != nil {
+			measurementFailed(measurementRequired, "cannot serialize host config provenance for measurement: %v", err)
+		}
+
+		err = mes.Add(host.DetailPcr, host.HostConfigProvenance, sha256.Sum256(provenanceBytes), provenanceBytes)
+		if err != nil {
+			measurementFailed(measurementRequired, "cannot measure host config provenance: %v", err)
+		}
 	}
 
 	err = mes.Add(host.AuthorityPcr, host.SecurityConfig, sha256.Sum256(securityConfigBytes), securityConfigBytes)
 	if err != nil {
-		stlog.Warn("cannot measure security config: %v", err)
+		measurementFailed(measurementRequired, "cannot measure security config: %v", err)
 	}
 
 	err = mes.Add(host.AuthorityPcr, host.SigningRoot, sha256.Sum256(stOptions.SigningRoot.Raw), stOptions.SigningRoot.Raw)
 	if err != nil {
-		stlog.Warn("cannot measure signing root certificate: %v", err)
+		measurementFailed(measurementRequired, "cannot measure signing root certificate: %v", err)
 	}
 
 	buf := bytes.NewBuffer(nil)
@@ -324,26 +327,26 @@ func main() {
 
 	err = mes.Add(host.AuthorityPcr, host.HTTPSRoot, sha256.Sum256(buf.Bytes()), buf.Bytes())
 	if err != nil {
-		stlog.Warn("cannot measure signing root certificate: %v", err)
+		measurementFailed(measurementRequired, "cannot measure HTTPS root certificates: %v", err)
 	}
 
 	// retrieve and measure identity.
 	uxIdentity, err := mes.Identity()
 	if err != nil {
-		stlog.Warn("cannot fetch identity from TPM: %s", err)
+		measurementFailed(measurementRequired, "cannot fetch identity from TPM: %v", err)
 
 		uxIdentity = ""
 	}
 
 	err = mes.Add(host.IdentityPcr, host.UxIdentity, sha256.Sum256([]byte(uxIdentity)), []byte(uxIdentity))
 	if err != nil {
-		stlog.Warn("cannot measure identity: %s", err)
+		measurementFailed(measurementRequired, "cannot measure identity: %s", err)
 	}
 
 	// marshal event log and close TPM socket.
 	eventlog, err := mes.Finalize()
 	if err != nil {
-		stlog.Warn("cannot finalize measurements: %v", err)
+		measurementFailed(measurementRequired, "cannot finalize measurements: %v", err)
 	}
 
 	stlog.Info("Human-readable device identity: %s\n", uxIdentity)
@@ -365,6 +368,13 @@ func main() {
 			stlog.Warn("cannot set event log metadata: %s", err)
 		}
 
+		if sample.netState != nil {
+			err = setNetworkMetadata(meta, sample.netState)
+			if err != nil {
+				stlog.Warn("cannot set network metadata: %s", err)
+			}
+		}
+
 		err = meta.Close()
 		if err != nil {
 			stlog.Warn("cannot close metadata: %s", err)
@@ -373,6 +383,13 @@ func main() {
 		linuxImg.Cmdline += " " + meta.Cmdline
 	}
 
+	if *netParams && sample.netState != nil {
+		if params := sample.netState.KernelParams(); params != "" {
+			stlog.Debug("Network parameters: %s", params)
+			linuxImg.Cmdline += " " + params
+		}
+	}
+
 	//////////
 	// Boot OS
 	//////////
@@ -399,6 +416,196 @@ func main() {
 	host.Recover()
 }
 
+// measurementFailed reports a failed measurement. If measurements are
+// required by the trust policy, it does not return but enters recovery.
+func measurementFailed(required bool, format string, args ...interface{}) {
+	if required {
+		stlog.Error(format, args...)
+		host.Recover()
+	}
+
+	stlog.Warn(format, args...)
+}
+
+// fetchMethods returns the fetch methods to try in order. The provisioning
+// OS package is always loaded from the initramfs: version 1 policies are
+// overridden beforehand, version 2 policies must allow it.
+func fetchMethods(policy *trust.Policy, hostCfg *host.Config) ([]trust.FetchMethodPolicy, error) {
+	if hostCfg.OSPkgPointer == nil || *hostCfg.OSPkgPointer != host.HostConfigProvisionOSPKGName {
+		return policy.Methods(), nil
+	}
+
+	for _, method := range policy.Methods() {
+		if method.Method == ospkg.FetchFromInitramfs {
+			return []trust.FetchMethodPolicy{method}, nil
+		}
+	}
+
+	return nil, errProvisioningNotAllowed
+}
+
+// setNetworkMetadata records the network configuration as JSON for the OS.
+func setNetworkMetadata(meta *metadata.Metadata, state *network.State) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	return meta.Set(metadata.NetworkConfig, data)
+}
+
+// useTrustedTime obtains the time from the Roughtime servers of policy, sets
+// the system clock and makes client check certificates against it.
+func useTrustedTime(ctx context.Context, client *network.HTTPClient, policy *trust.TimeSyncPolicy) error {
+	stlog.Info("Obtaining time from Roughtime servers")
+
+	now, err := network.SyncTime(ctx, policy)
+	if err != nil {
+		return err
+	}
+
+	stlog.Info("Trusted time: %s ± %s", now.Now().UTC().Format(time.RFC3339), now.Radius)
+
+	if err := now.SetSystemClock(); err != nil {
+		stlog.Warn("cannot set system clock: %v", err)
+	}
+
+	return client.UseTrustedTime(now)
+}
+
+// addSecrets keeps the credentials of hostCfg out of all further log
+// messages, including the debug dump of the options.
+func addSecrets(hostCfg *host.Config) {
+	if hostCfg == nil {
+		return
+	}
+
+	if hostCfg.Auth != nil {
+		stlog.AddSecret(*hostCfg.Auth)
+	}
+
+	if hostCfg.Proxy != nil {
+		for _, secret := range hostCfg.Proxy.Secrets() {
+			stlog.AddSecret(secret)
+		}
+	}
+}
+
+// teardownNetwork removes the interfaces created for state.
+func teardownNetwork(state *network.State) {
+	if err := network.TeardownNetwork(state); err != nil {
+		stlog.Warn("network teardown: %v", err)
+	}
+}
+
+// fetchOspkg loads an OS package using method. The method's timeout applies
+// in addition to the deadline of ctx.
+//
+//nolint:nonamedreturns
+func fetchOspkg(ctx context.Context, method trust.FetchMethodPolicy, stOptions *opts.Opts) (_ *ospkgSample, fetchErr error) {
+	if timeout := method.Timeout(); timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	switch method.Method {
+	case ospkg.FetchFromNetwork:
+		stlog.Info("Loading OS package via network")
+
+		if len(stOptions.HTTPSRoots) == 0 {
+			return nil, errNoHTTPSRoots
+		}
+
+		netState, err := network.SetupNetwork(&stOptions.HostCfg)
+		if err != nil {
+			return nil, err
+		}
+
+		// Leave no interfaces behind for the next method.
+		defer func() {
+			if fetchErr != nil {
+				teardownNetwork(netState)
+			}
+		}()
+
+		client := network.NewHTTPClient(stOptions.HTTPSRoots, false)
+		if err := client.RestrictRoots(stOptions.HTTPSRootHosts); err != nil {
+			return nil, err
+		}
+
+		if method.HTTPSOnly {
+			client.RequireHTTPS()
+		}
+
+		if clientCert := stOptions.HostCfg.ClientCert; clientCert != nil {
+			cert, err := clientCert.Load()
+			if err != nil {
+				return nil, err
+			}
+
+			stlog.Info("Using TLS client certificate %q", cert.Leaf.Subject)
+
+			if err := client.UseClientCert(cert); err != nil {
+				return nil, err
+			}
+		}
+
+		if timeSync := stOptions.TrustPolicy.TimeSync; timeSync != nil {
+			if err := useTrustedTime(ctx, &client, timeSync); err != nil {
+				if timeSync.Required {
+					return nil, err
+				}
+
+				stlog.Warn("Using system clock: %v", err)
+			}
+		}
+
+		pins := stOptions.TrustPolicy.Pins()
+		for _, pin := range pins {
+			stlog.Info("Pinning %d public keys of %s", len(pin.SPKISHA256), pin.Host)
+		}
+
+		if err := client.UsePins(pins); err != nil {
+			return nil, err
+		}
+
+		if proxy := stOptions.HostCfg.Proxy; proxy != nil {
+			stlog.Info("Using proxy %s", proxy.URL.Redacted())
+
+			if err := client.UseProxy(proxy); err != nil {
+				return nil, err
+			}
+		}
+
+		if authHeader := stOptions.HostCfg.AuthHeader; authHeader != nil {
+			stlog.Info("Sending authentication in header %s", authHeader.HeaderName())
+
+			if err := client.UseAuthHeader(&stOptions.HostCfg); err != nil {
+				return nil, err
+			}
+		}
+
+		stlog.Debug("OS package pointer: %s", *stOptions.HostCfg.OSPkgPointer)
+
+		sample, err := fetchOspkgNetwork(ctx, client, &stOptions.HostCfg)
+		if err != nil {
+			return nil, err
+		}
+
+		sample.netState = netState
+
+		return sample, nil
+	case ospkg.FetchFromInitramfs:
+		stlog.Info("Loading OS package from initramfs")
+
+		return fetchOspkgInitramfs(&stOptions.HostCfg)
+	default:
+		return nil, errUnknownFetchMethod
+	}
+}
+
 // get an ospkg from the initramfs.
 func fetchOspkgInitramfs(hostCfg *host.Config) (*ospkgSample, error) {
 	return _fetchOspkgInitramfs(hostCfg, "ospkg")
@@ -456,7 +663,55 @@ func ospkgFiles(cfg *host.Config) (descriptor, archive string) {
 	return name + ".json", name + ".zip"
 }
 
-const errDownload = Error("download failed")
+// verifyOspkg reads the OS package of sample and verifies its signatures
+// against the signing root and the threshold of the trust policy.
+func verifyOspkg(sample *ospkgSample, stOptions *opts.Opts) (*ospkg.OSPackage, error) {
+	aBytes, err := io.ReadAll(sample.archive)
+	if err != nil {
+		return nil, fmt.Errorf("read archive: %w", err)
+	}
+
+	dBytes, err := io.ReadAll(sample.descriptor)
+	if err != nil {
+		return nil, fmt.Errorf("read descriptor: %w", err)
+	}
+
+	osp, err := ospkg.NewOSPackage(aBytes, dBytes)
+	if err != nil {
+		return nil, fmt.Errorf("create OS package: %w", err)
+	}
+
+	if stlog.Level() == stlog.DebugLevel {
+		if info, err := osp.Info(stOptions.SigningRoot); err != nil {
+			stlog.Debug("OS package info: %v", err)
+		} else if infoStr, err := json.MarshalIndent(info, "", "  "); err == nil {
+			stlog.Debug("OS package info: %s", infoStr)
+		}
+	}
+
+	numSig, valid, err := osp.Verify(stOptions.SigningRoot)
+	if err != nil {
+		return nil, fmt.Errorf("verify OS package: %w", err)
+	}
+
+	threshold := stOptions.TrustPolicy.SignatureThreshold
+	if valid < threshold {
+		return nil, fmt.Errorf("%w: %d found, %d valid, %d required", errSignatureThreshold, numSig, valid, threshold)
+	}
+
+	stlog.Debug("Signatures: %d found, %d valid, %d required", numSig, valid, threshold)
+
+	return osp, nil
+}
+
+const (
+	errProvisioningNotAllowed = Error("provisioning requires the initramfs fetch method, which the trust policy does not allow")
+	errNoHTTPSRoots           = Error("httpsRoots must not be empty")
+	errUnknownFetchMethod     = Error("unknown OS package fetch method")
+	errDownload               = Error("download failed")
+	errNoArchiveSource        = Error("neither OS package URL nor content-addressed store available")
+	errSignatureThreshold     = Error("not enough valid signatures")
+)
 
 // get an ospkg via the network.
 func fetchOspkgNetwork(ctx context.Context, client network.HTTPClient, hostCfg *host.Config) (*ospkgSample, error) {
@@ -486,17 +741,7 @@ func fetchOspkgNetwork(ctx context.Context, client network.HTTPClient, hostCfg *
 			continue
 		}
 
-		stlog.Debug("Parsing OS package URL form descriptor")
-
-		filename, pkgURL, ok := validatePkgURL(descriptor.PkgURL)
-
-		if !ok {
-			continue
-		}
-
-		stlog.Debug("Downloading %s", pkgURL.String())
-
-		pkgbytes, err := client.Download(ctx, pkgURL)
+		filename, pkgbytes, err := fetchArchive(ctx, client, descriptor, hostCfg)
 		if err != nil {
 			stlog.Debug("Skip %s: %v", url.String(), err)
 
@@ -522,6 +767,77 @@ func fetchOspkgNetwork(ctx context.Context, client network.HTTPClient, hostCfg *
 	return nil, errDownload
 }
 
+// fetchArchive downloads the archive belonging to descriptor. Content-addressed
+// archives are tried from the stores of the host configuration first and
+// then from the package URL. Downloads not matching the hash are discarded
+// before being parsed.
+func fetchArchive(ctx context.Context, client network.HTTPClient, descriptor *ospkg.Descriptor, hostCfg *host.Config) (string, []byte, error) {
+	type source struct {
+		name string
+		url  *url.URL
+	}
+
+	var sources []source
+
+	if descriptor.PkgSHA256 != "" {
+		for _, u := range ospkgStoreURLs(hostCfg, descriptor.PkgSHA256) {
+			sources = append(sources, source{name: descriptor.PkgSHA256 + ospkg.OSPackageExt, url: u})
+		}
+	}
+
+	if filename, pkgURL, ok := validatePkgURL(descriptor.PkgURL); ok {
+		sources = append(sources, source{name: filename, url: pkgURL})
+	}
+
+	if len(sources) == 0 {
+		return "", nil, errNoArchiveSource
+	}
+
+	for _, src := range sources {
+		stlog.Debug("Downloading %s", src.url.String())
+
+		pkgbytes, err := client.Download(ctx, src.url)
+		if err != nil {
+			stlog.Debug("Skip %s: %v", src.url.String(), err)
+
+			continue
+		}
+
+		if err := descriptor.CheckArchive(pkgbytes); err != nil {
+			stlog.Warn("Skip %s: %v", src.url.String(), err)
+
+			continue
+		}
+
+		return src.name, pkgbytes, nil
+	}
+
+	return "", nil, errDownload
+}
+
+// ospkgStoreURLs returns the URLs of the archive with the hex encoded
+// SHA-256 hash at the content-addressed stores of cfg.
+func ospkgStoreURLs(cfg *host.Config, hash string) []*url.URL {
+	urls := make([]*url.URL, 0)
+
+	if cfg.OSPkgStores == nil {
+		return urls
+	}
+
+	for _, store := range *cfg.OSPkgStores {
+		addr, err := url.Parse(strings.TrimSuffix(store, "/") + "/" + hash)
+		if err != nil {
+			stlog.Warn("skip store %q: %v", store, err)
+
+			continue
+		}
+
+		urls = append(urls, addr)
+	}
+
+	return urls
+}
+
 func ospkgURLs(cfg *host.Config) []url.URL {
 	urls := make([]url.URL, 0)
 
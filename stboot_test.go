@@ -7,22 +7,34 @@ package main
 import (
 	"bytes"
 	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
 	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/pem"
 	"fmt"
 	"io"
+	"log"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"system-transparency.org/stboot/host"
 	"system-transparency.org/stboot/host/network"
+	"system-transparency.org/stboot/opts"
 	"system-transparency.org/stboot/ospkg"
 	"system-transparency.org/stboot/stlog"
+	"system-transparency.org/stboot/trust"
 )
 
 func TestFetchOspkgNetwork(t *testing.T) {
@@ -86,6 +98,96 @@ func TestFetchOspkgNetwork(t *testing.T) {
 	}
 }
 
+func TestFetchOspkgNetworkContentAddressed(t *testing.T) {
+	archive := []byte("archive")
+	hash := sha256.Sum256(archive)
+
+	var desc = ospkg.Descriptor{
+		Version:      1,
+		PkgURL:       "{{SERVER}}/mutable.zip",
+		PkgSHA256:    hex.EncodeToString(hash[:]),
+		Certificates: [][]byte{{}},
+		Signatures:   [][]byte{{}},
+	}
+
+	if !testing.Verbose() {
+		stlog.SetLevel(stlog.ErrorLevel)
+	}
+
+	var svr *httptest.Server
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/descriptor.json", func(w http.ResponseWriter, r *http.Request) {
+		desc.PkgURL = strings.ReplaceAll(desc.PkgURL, "{{SERVER}}", svr.URL)
+		json.NewEncoder(w).Encode(desc) //nolint:errcheck
+	})
+	mux.HandleFunc("/evil/"+desc.PkgSHA256, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "tampered")
+	})
+	mux.HandleFunc("/good/"+desc.PkgSHA256, func(w http.ResponseWriter, r *http.Request) {
+		w.Write(archive) //nolint:errcheck
+	})
+	mux.HandleFunc("/mutable.zip", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "mutable")
+	})
+
+	svr = httptest.NewServer(mux)
+	defer svr.Close()
+
+	client := network.NewHTTPClient(nil, false)
+	client.Retries = 1
+	client.RetryWait = 0
+
+	tests := []struct {
+		name     string
+		stores   []string
+		wantName string
+		wantErr  bool
+	}{
+		{
+			name:     "Skip tampered store",
+			stores:   []string{svr.URL + "/missing", svr.URL + "/evil", svr.URL + "/good/"},
+			wantName: desc.PkgSHA256 + ".zip",
+		},
+		{
+			name:    "Reject mutable URL",
+			stores:  []string{svr.URL + "/evil"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			osPkgPtr := svr.URL + "/descriptor.json"
+			stores := tt.stores
+			cfg := &host.Config{OSPkgPointer: &osPkgPtr, OSPkgStores: &stores}
+
+			sample, err := fetchOspkgNetwork(context.Background(), client, cfg)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expect an error")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if sample.name != tt.wantName {
+				t.Errorf("got name %q, want %q", sample.name, tt.wantName)
+			}
+
+			a, _ := io.ReadAll(sample.archive)
+			if !bytes.Equal(a, archive) {
+				t.Errorf("got archive %q, want %q", a, archive)
+			}
+		})
+	}
+}
+
 func TestFetchOspkgInitramfs(t *testing.T) {
 	var descriptor = ospkg.Descriptor{
 		Version:      1,
@@ -240,3 +342,238 @@ func TestOSpkgFiles(t *testing.T) {
 		})
 	}
 }
+
+func TestFetchMethods(t *testing.T) {
+	provision := host.HostConfigProvisionOSPKGName
+	other := "https://server.example/ospkg.json"
+
+	netMethod := trust.FetchMethodPolicy{Method: ospkg.FetchFromNetwork, TimeoutSeconds: 10}
+	initramfsMethod := trust.FetchMethodPolicy{Method: ospkg.FetchFromInitramfs}
+
+	tests := []struct {
+		name    string
+		policy  trust.Policy
+		pointer string
+		want    []trust.FetchMethodPolicy
+		wantErr bool
+	}{
+		{
+			name:    "Version 1",
+			policy:  trust.Policy{SignatureThreshold: 1, FetchMethod: ospkg.FetchFromNetwork},
+			pointer: other,
+			want:    []trust.FetchMethodPolicy{{Method: ospkg.FetchFromNetwork}},
+		},
+		{
+			name:    "Version 2 in order",
+			policy:  trust.Policy{Version: 2, SignatureThreshold: 1, FetchMethods: &[]trust.FetchMethodPolicy{netMethod, initramfsMethod}},
+			pointer: other,
+			want:    []trust.FetchMethodPolicy{netMethod, initramfsMethod},
+		},
+		{
+			name:    "Version 2 provisioning",
+			policy:  trust.Policy{Version: 2, SignatureThreshold: 1, FetchMethods: &[]trust.FetchMethodPolicy{netMethod, initramfsMethod}},
+			pointer: provision,
+			want:    []trust.FetchMethodPolicy{initramfsMethod},
+		},
+		{
+			name:    "Version 2 provisioning not allowed",
+			policy:  trust.Policy{Version: 2, SignatureThreshold: 1, FetchMethods: &[]trust.FetchMethodPolicy{netMethod}},
+			pointer: provision,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pointer := tt.pointer
+			got, err := fetchMethods(&tt.policy, &host.Config{OSPkgPointer: &pointer})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expect an error")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// signedOspkg returns the archive and descriptor of an OS package signed by a
+// certificate issued by the returned root.
+func signedOspkg(t *testing.T) ([]byte, []byte, *x509.Certificate) {
+	t.Helper()
+
+	mkCert := func(name string, pub ed25519.PublicKey, parent *x509.Certificate, parentKey ed25519.PrivateKey) *x509.Certificate {
+		template := &x509.Certificate{
+			SerialNumber:          big.NewInt(1),
+			Subject:               pkix.Name{CommonName: name},
+			NotBefore:             time.Now().Add(-time.Hour),
+			NotAfter:              time.Now().Add(time.Hour),
+			IsCA:                  parent == nil,
+			BasicConstraintsValid: true,
+		}
+
+		if parent == nil {
+			parent = template
+		}
+
+		der, err := x509.CreateCertificate(rand.Reader, template, parent, pub, parentKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return cert
+	}
+
+	rootPub, rootPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root := mkCert("root", rootPub, nil, rootPriv)
+	cert := mkCert("signer", pub, root, rootPriv)
+
+	dir := t.TempDir()
+	kernel := filepath.Join(dir, "kernel")
+	initramfs := filepath.Join(dir, "initramfs")
+
+	for _, f := range []string{kernel, initramfs} {
+		if err := os.WriteFile(f, []byte(filepath.Base(f)), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	osp, err := ospkg.CreateOSPackage("test", "https://example.org/ospkg.zip", kernel, initramfs, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	aBytes, err := osp.ArchiveBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := osp.SignWith(priv, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}); err != nil {
+		t.Fatal(err)
+	}
+
+	dBytes, err := osp.DescriptorBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return aBytes, dBytes, root
+}
+
+func TestVerifyOspkg(t *testing.T) {
+	aBytes, dBytes, root := signedOspkg(t)
+	_, _, otherRoot := signedOspkg(t)
+
+	for _, tt := range []struct {
+		name      string
+		archive   []byte
+		root      *x509.Certificate
+		threshold int
+		valid     bool
+	}{
+		{name: "valid", archive: aBytes, root: root, threshold: 1, valid: true},
+		{name: "threshold", archive: aBytes, root: root, threshold: 2},
+		{name: "other root", archive: aBytes, root: otherRoot, threshold: 1},
+		{name: "corrupt archive", archive: append([]byte("x"), aBytes...), root: root, threshold: 1},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			sample := &ospkgSample{
+				name:       "test",
+				archive:    io.NopCloser(bytes.NewReader(tt.archive)),
+				descriptor: io.NopCloser(bytes.NewReader(dBytes)),
+			}
+
+			stOptions := &opts.Opts{
+				TrustPolicy: trust.Policy{SignatureThreshold: tt.threshold},
+				SigningRoot: tt.root,
+			}
+
+			osp, err := verifyOspkg(sample, stOptions)
+			if tt.valid && (err != nil || osp == nil) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !tt.valid && err == nil {
+				t.Error("expect an error")
+			}
+		})
+	}
+}
+
+func TestAddSecrets(t *testing.T) {
+	const password = "pr0xy&p<ss word"
+
+	buf := bytes.Buffer{}
+	log.SetOutput(&buf)
+	stlog.SetLevel(stlog.DebugLevel)
+
+	t.Cleanup(func() {
+		log.SetOutput(os.Stderr)
+		stlog.SetLevel(stlog.InfoLevel)
+	})
+
+	proxyURL, err := url.Parse("http://proxy.example.org:3128")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proxyURL.User = url.UserPassword("user", password)
+
+	stOptions := &opts.Opts{
+		HostCfg: host.Config{
+			Proxy: &host.Proxy{
+				URL:      proxyURL,
+				Username: "stboot",
+				Password: password,
+			},
+		},
+	}
+
+	addSecrets(&stOptions.HostCfg)
+
+	optsStr, err := json.MarshalIndent(stOptions, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stlog.Debug("Opts: %s", optsStr)
+	stlog.Debug("Proxy: %s", stOptions.HostCfg.Proxy.ProxyURL())
+
+	got := buf.String()
+	if !strings.Contains(got, "proxy.example.org") {
+		t.Fatalf("log %q misses the proxy", got)
+	}
+
+	for _, secret := range stOptions.HostCfg.Proxy.Secrets() {
+		if strings.Contains(got, secret) {
+			t.Errorf("log %q contains the password as %q", got, secret)
+		}
+	}
+
+	if strings.Contains(got, "pr0xy") {
+		t.Errorf("log %q contains the password", got)
+	}
+}
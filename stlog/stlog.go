@@ -8,6 +8,8 @@ package stlog
 import (
 	"fmt"
 	"log"
+	"strings"
+	"sync"
 	"sync/atomic"
 )
 
@@ -27,9 +29,22 @@ const (
 	DebugLevel
 )
 
+// redacted replaces secrets in log messages.
+const redacted = "[REDACTED]"
+
+// MinSecretLen is the length below which secrets are not redacted, since
+// replacing them would mangle unrelated parts of log messages.
+const MinSecretLen = 6
+
 //nolint:gochecknoglobals
 var currentLogLevel int32
 
+//nolint:gochecknoglobals
+var (
+	secretsMu sync.RWMutex
+	secrets   []string
+)
+
 //nolint:gochecknoinits
 func init() {
 	currentLogLevel = int32(DebugLevel)
@@ -52,11 +67,43 @@ func Level() LogLevel {
 	return LogLevel(atomic.LoadInt32(&currentLogLevel))
 }
 
+// AddSecret makes all further log messages show secret as [REDACTED].
+// Secrets shorter than MinSecretLen are not redacted, with a warning.
+func AddSecret(secret string) {
+	if secret == "" {
+		return
+	}
+
+	if len(secret) < MinSecretLen {
+		Warn("secret of %d characters is too short to be redacted from logs", len(secret))
+
+		return
+	}
+
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+
+	secrets = append(secrets, secret)
+}
+
+func message(tag, format string, v ...interface{}) string {
+	msg := fmt.Sprintf(format, v...)
+
+	secretsMu.RLock()
+	defer secretsMu.RUnlock()
+
+	for _, s := range secrets {
+		msg = strings.ReplaceAll(msg, s, redacted)
+	}
+
+	return tag + msg
+}
+
 // Error prints error messages to the currently active logger when permitted
 // by the log level. Input can be formatted according to fmt.Printf.
 func Error(format string, v ...interface{}) {
 	if Level() >= ErrorLevel {
-		log.Print(errorTag + fmt.Sprintf(format, v...))
+		log.Print(message(errorTag, format, v...))
 	}
 }
 
@@ -64,7 +111,7 @@ func Error(format string, v ...interface{}) {
 // by the log level. Input can be formatted according to fmt.Printf.
 func Warn(format string, v ...interface{}) {
 	if Level() >= WarnLevel {
-		log.Print(warnTag + fmt.Sprintf(format, v...))
+		log.Print(message(warnTag, format, v...))
 	}
 }
 
@@ -72,7 +119,7 @@ func Warn(format string, v ...interface{}) {
 // by the log level. Input can be formatted according to fmt.Printf.
 func Info(format string, v ...interface{}) {
 	if Level() >= InfoLevel {
-		log.Print(infoTag + fmt.Sprintf(format, v...))
+		log.Print(message(infoTag, format, v...))
 	}
 }
 
@@ -80,6 +127,6 @@ func Info(format string, v ...interface{}) {
 // by the log level. Input can be formatted according to fmt.Printf.
 func Debug(format string, v ...interface{}) {
 	if Level() >= DebugLevel {
-		log.Print(debugTag + fmt.Sprintf(format, v...))
+		log.Print(message(debugTag, format, v...))
 	}
 }
@@ -157,3 +157,42 @@ func TestStandardLoggerLevel(t *testing.T) {
 		})
 	}
 }
+
+func TestAddSecret(t *testing.T) {
+	buf := bytes.Buffer{}
+	log.SetOutput(&buf)
+	SetLevel(DebugLevel)
+
+	AddSecret("")
+	AddSecret("s3cr3t")
+
+	Debug("GET https://server.com/%s/os.json", "s3cr3t")
+
+	got := buf.String()
+	if strings.Contains(got, "s3cr3t") {
+		t.Errorf("log message %q contains the secret", got)
+	}
+
+	if !strings.Contains(got, "https://server.com/"+redacted+"/os.json") {
+		t.Errorf("log message %q misses %s", got, redacted)
+	}
+}
+
+func TestAddSecretShort(t *testing.T) {
+	buf := bytes.Buffer{}
+	log.SetOutput(&buf)
+	SetLevel(DebugLevel)
+
+	AddSecret("os")
+
+	if !strings.Contains(buf.String(), warnTag) {
+		t.Errorf("got %q, want a warning about the short secret", buf.String())
+	}
+
+	buf.Reset()
+	Debug("GET https://server.com/os.json")
+
+	if got := buf.String(); !strings.Contains(got, "https://server.com/os.json") {
+		t.Errorf("log message %q is mangled by a short secret", got)
+	}
+}
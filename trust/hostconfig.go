@@ -0,0 +1,135 @@
+package trust
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+)
+
+// HostConfigAuthMode controls whether the host configuration must be signed.
+type HostConfigAuthMode int
+
+// Supported host configuration authentication modes.
+const (
+	// HostConfigSignatureOptional verifies signed host configurations but
+	// still accepts unsigned ones.
+	HostConfigSignatureOptional HostConfigAuthMode = iota + 1
+	// HostConfigSignatureRequired rejects unsigned host configurations. The
+	// host configuration generated in provision mode is exempt.
+	HostConfigSignatureRequired
+	// HostConfigRestricted accepts unsigned host configurations only if all
+	// set fields are listed in UnsignedFields.
+	HostConfigRestricted
+)
+
+// HostConfigAuthPolicy holds the host configuration related part of the trust
+// policy. Key is the file name of the PEM encoded Ed25519 public key used to
+// verify signed host configurations. It is looked up next to the trust policy.
+type HostConfigAuthPolicy struct {
+	Mode           HostConfigAuthMode `json:"mode"`
+	Key            string             `json:"key"`
+	UnsignedFields []string           `json:"unsigned_fields,omitempty"`
+}
+
+// AllowsUnsigned returns true if the field named by its JSON key may be set by
+// an unsigned host configuration.
+func (h *HostConfigAuthPolicy) AllowsUnsigned(field string) bool {
+	switch h.Mode {
+	case HostConfigSignatureOptional:
+		return true
+	case HostConfigRestricted:
+		for _, f := range h.UnsignedFields {
+			if f == field {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (m HostConfigAuthMode) toStr() (string, bool) {
+	var toStr = map[HostConfigAuthMode]string{
+		HostConfigSignatureOptional: "optional",
+		HostConfigSignatureRequired: "required",
+		HostConfigRestricted:        "restricted",
+	}
+
+	str, ok := toStr[m]
+
+	return str, ok
+}
+
+// String implements fmt.Stringer.
+func (m HostConfigAuthMode) String() string {
+	str, ok := m.toStr()
+	if !ok {
+		return "invalid host config auth mode"
+	}
+
+	return str
+}
+
+// IsValid returns true if m is a defined HostConfigAuthMode value.
+func (m HostConfigAuthMode) IsValid() bool {
+	_, ok := m.toStr()
+
+	return ok
+}
+
+// MarshalJSON implements json.Marshaler.
+func (m HostConfigAuthMode) MarshalJSON() ([]byte, error) {
+	str, ok := m.toStr()
+	if !ok {
+		return nil, &json.MarshalerError{
+			Type: reflect.TypeOf(m),
+			Err:  errors.New("invalid host config auth mode"),
+		}
+	}
+
+	return json.Marshal(str)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (m *HostConfigAuthMode) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+
+	for mode := HostConfigSignatureOptional; mode <= HostConfigRestricted; mode++ {
+		if s, _ := mode.toStr(); s == str {
+			*m = mode
+
+			return nil
+		}
+	}
+
+	return &json.UnmarshalTypeError{
+		Value: fmt.Sprintf("string %q", str),
+		Type:  reflect.TypeOf(m),
+	}
+}
+
+func (p *Policy) checkHostConfigAuth() error {
+	auth := p.HostConfigAuth
+	if auth == nil {
+		return nil
+	}
+
+	if !auth.Mode.IsValid() {
+		return fmt.Errorf("invalid host config auth mode %d", auth.Mode)
+	}
+
+	if auth.Key == "" || auth.Key == "." || auth.Key == ".." || auth.Key != filepath.Base(auth.Key) {
+		return fmt.Errorf("host config key must be a plain file name, got %q", auth.Key)
+	}
+
+	if auth.Mode != HostConfigRestricted && len(auth.UnsignedFields) > 0 {
+		return fmt.Errorf("unsigned_fields requires mode %q", HostConfigRestricted)
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package trust
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestPolicyHostConfigAuth(t *testing.T) {
+	validtests := []struct {
+		name    string
+		json    string
+		field   string
+		allowed bool
+	}{
+		{
+			name: "Optional",
+			json: `{
+				"ospkg_signature_threshold": 1,
+				"ospkg_fetch_method": "network",
+				"host_config_auth": {"mode": "optional", "key": "host_config_key.pem"}
+			}`,
+			field:   "ospkg_pointer",
+			allowed: true,
+		},
+		{
+			name: "Required",
+			json: `{
+				"ospkg_signature_threshold": 1,
+				"ospkg_fetch_method": "network",
+				"host_config_auth": {"mode": "required", "key": "host_config_key.pem"}
+			}`,
+			field:   "network_mode",
+			allowed: false,
+		},
+		{
+			name: "Restricted",
+			json: `{
+				"ospkg_signature_threshold": 1,
+				"ospkg_fetch_method": "network",
+				"host_config_auth": {
+					"mode": "restricted",
+					"key": "host_config_key.pem",
+					"unsigned_fields": ["network_mode", "host_ip", "gateway", "dns"]
+				}
+			}`,
+			field:   "host_ip",
+			allowed: true,
+		},
+	}
+
+	invalidtests := []struct {
+		name string
+		json string
+	}{
+		{
+			name: "Key missing",
+			json: `{
+				"ospkg_signature_threshold": 1,
+				"ospkg_fetch_method": "network",
+				"host_config_auth": {"mode": "required"}
+			}`,
+		},
+		{
+			name: "Key path",
+			json: `{
+				"ospkg_signature_threshold": 1,
+				"ospkg_fetch_method": "network",
+				"host_config_auth": {"mode": "required", "key": "../host_config_key.pem"}
+			}`,
+		},
+		{
+			name: "Unsigned fields without restricted mode",
+			json: `{
+				"ospkg_signature_threshold": 1,
+				"ospkg_fetch_method": "network",
+				"host_config_auth": {"mode": "required", "key": "k.pem", "unsigned_fields": ["dns"]}
+			}`,
+		},
+	}
+
+	for _, tt := range validtests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Policy
+			if err := json.Unmarshal([]byte(tt.json), &got); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if allowed := got.HostConfigAuth.AllowsUnsigned(tt.field); allowed != tt.allowed {
+				t.Errorf("%s allowed unsigned: got %t, want %t", tt.field, allowed, tt.allowed)
+			}
+		})
+	}
+
+	for _, tt := range invalidtests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Policy
+			if err := json.Unmarshal([]byte(tt.json), &got); !errors.Is(err, ErrInvalidPolicy) {
+				t.Errorf("got %v, want %v", err, ErrInvalidPolicy)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package trust
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// TrustBundle is a file or directory of HTTPS root certificates, see
+// certutil.Decode for the formats. A relative Path is resolved against the
+// directory of the trust policy. If Hosts is empty, the roots are trusted for
+// all OS package servers, otherwise only for the listed DNS names.
+type TrustBundle struct {
+	Path  string   `json:"path"`
+	Hosts []string `json:"hosts,omitempty"`
+}
+
+// Bundles returns the trust bundles of HTTPS roots, or nil if the default
+// roots shall be used.
+func (p *Policy) Bundles() []TrustBundle {
+	if p.HTTPSRoots == nil {
+		return nil
+	}
+
+	return append([]TrustBundle(nil), *p.HTTPSRoots...)
+}
+
+func (p *Policy) checkHTTPSRoots() error {
+	if p.HTTPSRoots == nil {
+		return nil
+	}
+
+	if len(*p.HTTPSRoots) == 0 {
+		return fmt.Errorf("https roots: at least one trust bundle must be set")
+	}
+
+	for _, b := range *p.HTTPSRoots {
+		if b.Path == "" {
+			return fmt.Errorf("https roots: path must be set")
+		}
+
+		for _, host := range b.Hosts {
+			switch {
+			case host == "":
+				return fmt.Errorf("https roots %q: empty host", b.Path)
+			case net.ParseIP(host) != nil:
+				return fmt.Errorf("https roots %q: host %q must be a DNS name", b.Path, host)
+			case strings.Contains(host, ":"):
+				return fmt.Errorf("https roots %q: host %q must not have a port", b.Path, host)
+			}
+		}
+	}
+
+	return nil
+}
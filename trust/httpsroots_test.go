@@ -0,0 +1,74 @@
+package trust
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestPolicyHTTPSRoots(t *testing.T) {
+	policy := func(roots string) string {
+		return `{
+			"ospkg_signature_threshold": 1,
+			"ospkg_fetch_method": "network",
+			"https_roots": ` + roots + `
+		}`
+	}
+
+	validtests := []struct {
+		name  string
+		roots string
+		n     int
+	}{
+		{name: "File", roots: `[{"path": "isrgrootx1.pem"}]`, n: 1},
+		{
+			name: "Per host",
+			roots: `[
+				{"path": "/etc/ssl/certs"},
+				{"path": "internal-ca.p7b", "hosts": ["stboot.internal.example", "mirror.internal.example"]}
+			]`,
+			n: 2,
+		},
+	}
+
+	invalidtests := []struct {
+		name  string
+		roots string
+	}{
+		{name: "Empty", roots: `[]`},
+		{name: "No path", roots: `[{"path": ""}]`},
+		{name: "Empty host", roots: `[{"path": "ca.pem", "hosts": [""]}]`},
+		{name: "IP address", roots: `[{"path": "ca.pem", "hosts": ["192.0.2.1"]}]`},
+		{name: "Port", roots: `[{"path": "ca.pem", "hosts": ["stboot.internal.example:443"]}]`},
+	}
+
+	for _, tt := range validtests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Policy
+			if err := json.Unmarshal([]byte(policy(tt.roots)), &got); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if n := len(got.Bundles()); n != tt.n {
+				t.Errorf("got %d trust bundles, want %d", n, tt.n)
+			}
+		})
+	}
+
+	for _, tt := range invalidtests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Policy
+			if err := json.Unmarshal([]byte(policy(tt.roots)), &got); !errors.Is(err, ErrInvalidPolicy) {
+				t.Errorf("got %v, want %v", err, ErrInvalidPolicy)
+			}
+		})
+	}
+
+	var none Policy
+	if err := json.Unmarshal([]byte(`{"ospkg_signature_threshold": 1, "ospkg_fetch_method": "network"}`), &none); err != nil {
+		t.Fatal(err)
+	}
+
+	if none.Bundles() != nil {
+		t.Errorf("got %v, want default roots", none.Bundles())
+	}
+}
@@ -0,0 +1,119 @@
+package trust
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// MeasurementMode controls how stboot deals with failing TPM measurements.
+type MeasurementMode int
+
+// Supported measurement modes.
+const (
+	// MeasureBestEffort only warns about a missing TPM or failing
+	// measurements. It is meant for development.
+	MeasureBestEffort MeasurementMode = iota + 1
+	// MeasureRequired fails into recovery if the TPM is missing, does not
+	// meet the minimum version, or any measurement fails.
+	MeasureRequired
+)
+
+// TPMVersion20 is the only supported minimum TPM version, since event log and
+// identity are only implemented for TPM 2.0.
+const TPMVersion20 = "2.0"
+
+// MeasurementPolicy holds the measurement related part of the trust policy.
+// MinTPMVersion is only considered with MeasureRequired, an empty value
+// accepts any TPM.
+type MeasurementPolicy struct {
+	Mode          MeasurementMode `json:"mode"`
+	MinTPMVersion string          `json:"min_tpm_version,omitempty"`
+}
+
+func (m MeasurementMode) toStr() (string, bool) {
+	var toStr = map[MeasurementMode]string{
+		MeasureBestEffort: "best_effort",
+		MeasureRequired:   "required",
+	}
+
+	str, ok := toStr[m]
+
+	return str, ok
+}
+
+// String implements fmt.Stringer.
+func (m MeasurementMode) String() string {
+	str, ok := m.toStr()
+	if !ok {
+		return "invalid measurement mode"
+	}
+
+	return str
+}
+
+// IsValid returns true if m is a defined MeasurementMode value.
+func (m MeasurementMode) IsValid() bool {
+	_, ok := m.toStr()
+
+	return ok
+}
+
+// MarshalJSON implements json.Marshaler.
+func (m MeasurementMode) MarshalJSON() ([]byte, error) {
+	str, ok := m.toStr()
+	if !ok {
+		return nil, &json.MarshalerError{
+			Type: reflect.TypeOf(m),
+			Err:  errors.New("invalid measurement mode"),
+		}
+	}
+
+	return json.Marshal(str)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (m *MeasurementMode) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+
+	for mode := MeasureBestEffort; mode <= MeasureRequired; mode++ {
+		if s, _ := mode.toStr(); s == str {
+			*m = mode
+
+			return nil
+		}
+	}
+
+	return &json.UnmarshalTypeError{
+		Value: fmt.Sprintf("string %q", str),
+		Type:  reflect.TypeOf(m),
+	}
+}
+
+// MeasurementRequired returns true if the policy makes TPM measurements
+// mandatory. Policies without measurement setting are best effort.
+func (p *Policy) MeasurementRequired() bool {
+	return p.Measurement != nil && p.Measurement.Mode == MeasureRequired
+}
+
+func (p *Policy) checkMeasurement() error {
+	if p.Measurement == nil {
+		return nil
+	}
+
+	if !p.Measurement.Mode.IsValid() {
+		return fmt.Errorf("invalid measurement mode %d", p.Measurement.Mode)
+	}
+
+	switch p.Measurement.MinTPMVersion {
+	case "", TPMVersion20:
+		return nil
+	default:
+		return fmt.Errorf("invalid minimum TPM version %q, want %q",
+			p.Measurement.MinTPMVersion, TPMVersion20)
+	}
+}
@@ -0,0 +1,123 @@
+package trust
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestPolicyMeasurement(t *testing.T) {
+	validtests := []struct {
+		name         string
+		json         string
+		wantRequired bool
+	}{
+		{
+			name: "Default best effort",
+			json: `{
+				"ospkg_signature_threshold": 1,
+				"ospkg_fetch_method": "network"
+			}`,
+			wantRequired: false,
+		},
+		{
+			name: "Best effort",
+			json: `{
+				"ospkg_signature_threshold": 1,
+				"ospkg_fetch_method": "network",
+				"measurement": {"mode": "best_effort"}
+			}`,
+			wantRequired: false,
+		},
+		{
+			name: "Required",
+			json: `{
+				"version": 2,
+				"ospkg_signature_threshold": 1,
+				"ospkg_fetch_methods": [{"method": "network"}],
+				"measurement": {"mode": "required", "min_tpm_version": "2.0"}
+			}`,
+			wantRequired: true,
+		},
+	}
+
+	invalidtests := []struct {
+		name string
+		json string
+	}{
+		{
+			name: "Unknown mode",
+			json: `{
+				"ospkg_signature_threshold": 1,
+				"ospkg_fetch_method": "network",
+				"measurement": {"mode": "sometimes"}
+			}`,
+		},
+		{
+			name: "Missing mode",
+			json: `{
+				"ospkg_signature_threshold": 1,
+				"ospkg_fetch_method": "network",
+				"measurement": {"min_tpm_version": "2.0"}
+			}`,
+		},
+		{
+			name: "Unknown TPM version",
+			json: `{
+				"ospkg_signature_threshold": 1,
+				"ospkg_fetch_method": "network",
+				"measurement": {"mode": "required", "min_tpm_version": "2"}
+			}`,
+		},
+		{
+			name: "TPM 1.2",
+			json: `{
+				"ospkg_signature_threshold": 1,
+				"ospkg_fetch_method": "network",
+				"measurement": {"mode": "required", "min_tpm_version": "1.2"}
+			}`,
+		},
+	}
+
+	for _, tt := range validtests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Policy
+			if err := json.Unmarshal([]byte(tt.json), &got); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.MeasurementRequired() != tt.wantRequired {
+				t.Errorf("got required %v, want %v", got.MeasurementRequired(), tt.wantRequired)
+			}
+		})
+	}
+
+	for _, tt := range invalidtests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Policy
+			err := json.Unmarshal([]byte(tt.json), &got)
+			if err == nil {
+				t.Fatal("expect an error")
+			}
+			var errType *json.UnmarshalTypeError
+			if !errors.As(err, &errType) && !errors.Is(err, ErrInvalidPolicy) {
+				t.Errorf("expect ErrInvalidPolicy or %T, got %T: %v", errType, err, err)
+			}
+		})
+	}
+}
+
+func TestMeasurementModeMarshal(t *testing.T) {
+	got, err := json.Marshal(MeasurementPolicy{Mode: MeasureRequired, MinTPMVersion: TPMVersion20})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"mode":"required","min_tpm_version":"2.0"}`
+	if string(got) != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	if _, err := json.Marshal(MeasurementMode(0)); err == nil {
+		t.Error("expect an error for the zero value")
+	}
+}
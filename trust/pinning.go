@@ -0,0 +1,55 @@
+package trust
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// TLSPin pins the public keys of an OS package server. Host is the DNS name
+// of the server, the pin applies to all its ports. SPKISHA256 holds base64
+// encoded SHA-256 hashes of DER encoded SubjectPublicKeyInfos.
+//
+// By default, the certificate chain is validated against the HTTPS roots and
+// one of its certificates must have a pinned key. With PinOnly, chain
+// validation is skipped and the server certificate itself must have a pinned
+// key, e.g. for private deployments with self-signed certificates.
+type TLSPin struct {
+	Host       string   `json:"host"`
+	SPKISHA256 [][]byte `json:"spki_sha256"`
+	PinOnly    bool     `json:"pin_only,omitempty"`
+}
+
+func (p *Policy) checkTLSPins() error {
+	seen := make(map[string]bool)
+
+	for _, pin := range p.Pins() {
+		host := strings.ToLower(pin.Host)
+
+		switch {
+		case host == "":
+			return fmt.Errorf("tls pin: host must be set")
+		case net.ParseIP(host) != nil:
+			return fmt.Errorf("tls pin %q: host must be a DNS name", pin.Host)
+		case strings.Contains(host, ":"):
+			return fmt.Errorf("tls pin %q: host must not have a port", pin.Host)
+		case seen[host]:
+			return fmt.Errorf("tls pin %q: duplicate host", pin.Host)
+		}
+
+		seen[host] = true
+
+		if len(pin.SPKISHA256) == 0 {
+			return fmt.Errorf("tls pin %q: at least one hash must be set", pin.Host)
+		}
+
+		for _, hash := range pin.SPKISHA256 {
+			if len(hash) != sha256.Size {
+				return fmt.Errorf("tls pin %q: hash must be %d bytes, got %d", pin.Host, sha256.Size, len(hash))
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package trust
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestPolicyTLSPins(t *testing.T) {
+	// Base64 of 32 zero bytes.
+	const hash = "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="
+
+	policy := func(pins string) string {
+		return `{
+			"ospkg_signature_threshold": 1,
+			"ospkg_fetch_method": "network",
+			"tls_pins": ` + pins + `
+		}`
+	}
+
+	validtests := []struct {
+		name string
+		pins string
+		n    int
+	}{
+		{name: "None", pins: `[]`, n: 0},
+		{name: "Chain", pins: `[{"host": "stboot.example.org", "spki_sha256": ["` + hash + `"]}]`, n: 1},
+		{
+			name: "Pin only",
+			pins: `[
+				{"host": "stboot.example.org", "spki_sha256": ["` + hash + `", "` + hash + `"]},
+				{"host": "mirror.example.org", "spki_sha256": ["` + hash + `"], "pin_only": true}
+			]`,
+			n: 2,
+		},
+	}
+
+	invalidtests := []struct {
+		name string
+		pins string
+	}{
+		{name: "No host", pins: `[{"host": "", "spki_sha256": ["` + hash + `"]}]`},
+		{name: "IP address", pins: `[{"host": "192.0.2.1", "spki_sha256": ["` + hash + `"]}]`},
+		{name: "Port", pins: `[{"host": "stboot.example.org:443", "spki_sha256": ["` + hash + `"]}]`},
+		{name: "No hash", pins: `[{"host": "stboot.example.org", "spki_sha256": []}]`},
+		{name: "Short hash", pins: `[{"host": "stboot.example.org", "spki_sha256": ["AAAA"]}]`},
+		{
+			name: "Duplicate host",
+			pins: `[
+				{"host": "stboot.example.org", "spki_sha256": ["` + hash + `"]},
+				{"host": "STBOOT.example.org", "spki_sha256": ["` + hash + `"]}
+			]`,
+		},
+	}
+
+	for _, tt := range validtests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Policy
+			if err := json.Unmarshal([]byte(policy(tt.pins)), &got); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if n := len(got.Pins()); n != tt.n {
+				t.Errorf("got %d pins, want %d", n, tt.n)
+			}
+		})
+	}
+
+	for _, tt := range invalidtests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Policy
+			if err := json.Unmarshal([]byte(policy(tt.pins)), &got); !errors.Is(err, ErrInvalidPolicy) {
+				t.Errorf("got %v, want %v", err, ErrInvalidPolicy)
+			}
+		})
+	}
+}
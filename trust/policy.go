@@ -1,19 +1,53 @@
 package trust
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
 
+	"system-transparency.org/stboot/internal/jsonutil"
 	"system-transparency.org/stboot/ospkg"
 )
 
-var ErrInvalidPolicy = errors.New("invalid policy")
+var (
+	ErrInvalidPolicy            = errors.New("invalid policy")
+	ErrUnsupportedPolicyVersion = errors.New("unsupported trust policy version")
+)
+
+// Supported versions of the trust policy. Version 1 policies name a single
+// fetch method and usually carry no version key. Version 2 policies hold an
+// ordered list of fetch methods.
+const (
+	PolicyVersion1 int = 1
+	PolicyVersion2 int = 2
+)
 
 // Policy holds security configuration.
 type Policy struct {
-	SignatureThreshold int               `json:"ospkg_signature_threshold"`
-	FetchMethod        ospkg.FetchMethod `json:"ospkg_fetch_method"`
+	Version            int                   `json:"version,omitempty"`
+	SignatureThreshold int                   `json:"ospkg_signature_threshold"`
+	FetchMethod        ospkg.FetchMethod     `json:"ospkg_fetch_method,omitempty"`
+	FetchMethods       *[]FetchMethodPolicy  `json:"ospkg_fetch_methods,omitempty"`
+	Measurement        *MeasurementPolicy    `json:"measurement,omitempty"`
+	HostConfigAuth     *HostConfigAuthPolicy `json:"host_config_auth,omitempty"`
+	TimeSync           *TimeSyncPolicy       `json:"time_sync,omitempty"`
+	TLSPins            *[]TLSPin             `json:"tls_pins,omitempty"`
+	HTTPSRoots         *[]TrustBundle        `json:"https_roots,omitempty"`
+}
+
+// FetchMethodPolicy is an allowed fetch method and its constraints.
+// TimeoutSeconds limits the time spent on the method, the overall deadline
+// still applies. HTTPSOnly rejects plain HTTP URLs and redirects, it is only
+// valid for the network fetch method.
+type FetchMethodPolicy struct {
+	Method         ospkg.FetchMethod `json:"method"`
+	TimeoutSeconds int               `json:"timeout_seconds,omitempty"`
+	HTTPSOnly      bool              `json:"https_only,omitempty"`
+}
+
+// Timeout returns the time limit of the method, or zero if there is none.
+func (f FetchMethodPolicy) Timeout() time.Duration {
+	return time.Duration(f.TimeoutSeconds) * time.Second
 }
 
 // NewPolicy creates a Policy from template.
@@ -24,31 +58,131 @@ func NewPolicy(template Policy) (Policy, error) {
 		return ret, fmt.Errorf("%w: %v", ErrInvalidPolicy, err)
 	}
 
+	ret.Version = template.Version
 	ret.SignatureThreshold = template.SignatureThreshold
 	ret.FetchMethod = template.FetchMethod
 
+	if template.FetchMethods != nil {
+		methods := append([]FetchMethodPolicy(nil), *template.FetchMethods...)
+		ret.FetchMethods = &methods
+	}
+
+	if template.Measurement != nil {
+		measurement := *template.Measurement
+		ret.Measurement = &measurement
+	}
+
+	if template.HostConfigAuth != nil {
+		auth := *template.HostConfigAuth
+		auth.UnsignedFields = append([]string(nil), auth.UnsignedFields...)
+		ret.HostConfigAuth = &auth
+	}
+
+	if template.TimeSync != nil {
+		timeSync := *template.TimeSync
+		timeSync.Servers = append([]RoughtimeServer(nil), timeSync.Servers...)
+		ret.TimeSync = &timeSync
+	}
+
+	if template.TLSPins != nil {
+		pins := make([]TLSPin, 0, len(*template.TLSPins))
+		for _, pin := range *template.TLSPins {
+			pin.SPKISHA256 = append([][]byte(nil), pin.SPKISHA256...)
+			pins = append(pins, pin)
+		}
+
+		ret.TLSPins = &pins
+	}
+
+	if template.HTTPSRoots != nil {
+		bundles := make([]TrustBundle, 0, len(*template.HTTPSRoots))
+		for _, b := range *template.HTTPSRoots {
+			b.Hosts = append([]string(nil), b.Hosts...)
+			bundles = append(bundles, b)
+		}
+
+		ret.HTTPSRoots = &bundles
+	}
+
 	return ret, nil
 }
 
+// Methods returns the allowed fetch methods in the order they shall be tried.
+func (p *Policy) Methods() []FetchMethodPolicy {
+	if p.FetchMethods == nil {
+		return []FetchMethodPolicy{{Method: p.FetchMethod}}
+	}
+
+	return append([]FetchMethodPolicy(nil), *p.FetchMethods...)
+}
+
+// Pins returns the TLS pins of OS package servers.
+func (p *Policy) Pins() []TLSPin {
+	if p.TLSPins == nil {
+		return nil
+	}
+
+	return append([]TLSPin(nil), *p.TLSPins...)
+}
+
+// Allows returns true if method is one of the allowed fetch methods.
+func (p *Policy) Allows(method ospkg.FetchMethod) bool {
+	for _, m := range p.Methods() {
+		if m.Method == method {
+			return true
+		}
+	}
+
+	return false
+}
+
 // policy is used as an alias in Policy.UnmarshalJSON.
 type policy struct {
-	SignatureThreshold int               `json:"ospkg_signature_threshold"`
-	FetchMethod        ospkg.FetchMethod `json:"ospkg_fetch_method"`
+	Version            int                   `json:"version,omitempty"`
+	SignatureThreshold int                   `json:"ospkg_signature_threshold"`
+	FetchMethod        ospkg.FetchMethod     `json:"ospkg_fetch_method,omitempty"`
+	FetchMethods       *[]FetchMethodPolicy  `json:"ospkg_fetch_methods,omitempty"`
+	Measurement        *MeasurementPolicy    `json:"measurement,omitempty"`
+	HostConfigAuth     *HostConfigAuthPolicy `json:"host_config_auth,omitempty"`
+	TimeSync           *TimeSyncPolicy       `json:"time_sync,omitempty"`
+	TLSPins            *[]TLSPin             `json:"tls_pins,omitempty"`
+	HTTPSRoots         *[]TrustBundle        `json:"https_roots,omitempty"`
 }
 
 // UnmarshalJSON implements json.Unmarshaler. It initializes p from a JSON data
 // byte stream.
 // If unmarshaling fails, a json.UnmarshalTypeError is returned.
-// In case of further inter-field invalidities or other rules, that are not met,
-// the reurned error wrapps ErrInvalidPolicy.
+// An unknown version is reported by an error wrapping ErrUnsupportedPolicyVersion.
+// In case of unknown keys, trailing data, further inter-field invalidities
+// or other rules, that are not met, the reurned error wrapps ErrInvalidPolicy.
 func (p *Policy) UnmarshalJSON(data []byte) error {
+	version, err := jsonutil.Version(data)
+	if err != nil {
+		return err
+	}
+
+	if err := checkVersion(version); err != nil {
+		return err
+	}
+
 	alias := policy{}
-	if err := json.Unmarshal(data, &alias); err != nil {
+	if err := jsonutil.UnmarshalStrict(data, &alias); err != nil {
+		if errors.Is(err, jsonutil.ErrUnknownField) || errors.Is(err, jsonutil.ErrTrailingData) {
+			return fmt.Errorf("%w: %v", ErrInvalidPolicy, err)
+		}
+
 		return err
 	}
 
+	p.Version = alias.Version
 	p.SignatureThreshold = alias.SignatureThreshold
 	p.FetchMethod = alias.FetchMethod
+	p.FetchMethods = alias.FetchMethods
+	p.Measurement = alias.Measurement
+	p.HostConfigAuth = alias.HostConfigAuth
+	p.TimeSync = alias.TimeSync
+	p.TLSPins = alias.TLSPins
+	p.HTTPSRoots = alias.HTTPSRoots
 
 	if err := p.validate(); err != nil {
 		*p = Policy{}
@@ -61,8 +195,15 @@ func (p *Policy) UnmarshalJSON(data []byte) error {
 
 func (p *Policy) validate() error {
 	var validationSet = []func() error{
+		p.checkVersion,
 		p.checkOSPKGSignatureThreshold,
 		p.checkBootMode,
+		p.checkFetchMethods,
+		p.checkMeasurement,
+		p.checkHostConfigAuth,
+		p.checkTimeSync,
+		p.checkTLSPins,
+		p.checkHTTPSRoots,
 	}
 
 	for _, f := range validationSet {
@@ -74,6 +215,20 @@ func (p *Policy) validate() error {
 	return nil
 }
 
+func checkVersion(version int) error {
+	switch version {
+	case 0, PolicyVersion1, PolicyVersion2:
+		return nil
+	default:
+		return fmt.Errorf("%w %d, supported versions are %d and %d",
+			ErrUnsupportedPolicyVersion, version, PolicyVersion1, PolicyVersion2)
+	}
+}
+
+func (p *Policy) checkVersion() error {
+	return checkVersion(p.Version)
+}
+
 func (p *Policy) checkOSPKGSignatureThreshold() error {
 	if p.SignatureThreshold < 1 {
 		return errors.New("os package signature threshold must be > 0")
@@ -83,9 +238,55 @@ func (p *Policy) checkOSPKGSignatureThreshold() error {
 }
 
 func (p *Policy) checkBootMode() error {
+	if p.Version == PolicyVersion2 {
+		if p.FetchMethod != 0 {
+			return errors.New("ospkg_fetch_method is replaced by ospkg_fetch_methods in version 2")
+		}
+
+		return nil
+	}
+
+	if p.FetchMethods != nil {
+		return errors.New("ospkg_fetch_methods requires version 2")
+	}
+
 	if !p.FetchMethod.IsValid() {
 		return fmt.Errorf("invalid boot mode %d", p.FetchMethod)
 	}
 
 	return nil
 }
+
+func (p *Policy) checkFetchMethods() error {
+	if p.Version != PolicyVersion2 {
+		return nil
+	}
+
+	if p.FetchMethods == nil || len(*p.FetchMethods) == 0 {
+		return errors.New("at least one fetch method must be set")
+	}
+
+	seen := make(map[ospkg.FetchMethod]bool)
+
+	for _, m := range *p.FetchMethods {
+		if !m.Method.IsValid() {
+			return fmt.Errorf("invalid fetch method %d", m.Method)
+		}
+
+		if seen[m.Method] {
+			return fmt.Errorf("duplicate fetch method %q", m.Method)
+		}
+
+		seen[m.Method] = true
+
+		if m.TimeoutSeconds < 0 {
+			return fmt.Errorf("fetch method %q: timeout must not be negative", m.Method)
+		}
+
+		if m.HTTPSOnly && m.Method != ospkg.FetchFromNetwork {
+			return fmt.Errorf("fetch method %q: https_only is only valid for %q", m.Method, ospkg.FetchFromNetwork)
+		}
+	}
+
+	return nil
+}
@@ -113,6 +113,12 @@ func TestPolicyUnmarshalJSON(t *testing.T) {
 				FetchMethod:        ospkg.FetchFromNetwork,
 			},
 		},
+	}
+
+	invalidtests := []struct {
+		name string
+		json string
+	}{
 		{
 			name: "Unknown field",
 			json: `{
@@ -120,17 +126,14 @@ func TestPolicyUnmarshalJSON(t *testing.T) {
 				"ospkg_fetch_method": "network",
 				"unknown": "foo"
 			}`,
-			want: Policy{
-				SignatureThreshold: 1,
-				FetchMethod:        ospkg.FetchFromNetwork,
-			},
 		},
-	}
-
-	invalidtests := []struct {
-		name string
-		json string
-	}{
+		{
+			name: "Misspelled field",
+			json: `{
+				"ospkg_signature_treshold": 1,
+				"ospkg_fetch_method": "network"
+			}`,
+		},
 		{
 			name: "SignaturesThreshold missing",
 			json: `{
@@ -217,3 +220,156 @@ func TestPolicyUnmarshalJSON(t *testing.T) {
 		})
 	}
 }
+
+func TestPolicyVersion2(t *testing.T) {
+	validtests := []struct {
+		name string
+		json string
+		want []FetchMethodPolicy
+	}{
+		{
+			name: "Version 1 without version key",
+			json: `{
+				"ospkg_signature_threshold": 1,
+				"ospkg_fetch_method": "initramfs"
+			}`,
+			want: []FetchMethodPolicy{{Method: ospkg.FetchFromInitramfs}},
+		},
+		{
+			name: "Version 1",
+			json: `{
+				"version": 1,
+				"ospkg_signature_threshold": 1,
+				"ospkg_fetch_method": "network"
+			}`,
+			want: []FetchMethodPolicy{{Method: ospkg.FetchFromNetwork}},
+		},
+		{
+			name: "Ordered fetch methods",
+			json: `{
+				"version": 2,
+				"ospkg_signature_threshold": 1,
+				"ospkg_fetch_methods": [
+					{"method": "network", "timeout_seconds": 60, "https_only": true},
+					{"method": "initramfs"}
+				]
+			}`,
+			want: []FetchMethodPolicy{
+				{Method: ospkg.FetchFromNetwork, TimeoutSeconds: 60, HTTPSOnly: true},
+				{Method: ospkg.FetchFromInitramfs},
+			},
+		},
+	}
+
+	invalidtests := []struct {
+		name    string
+		json    string
+		wantErr error
+	}{
+		{
+			name: "Unknown version",
+			json: `{
+				"version": 3,
+				"ospkg_signature_threshold": 1,
+				"ospkg_fetch_rules": []
+			}`,
+			wantErr: ErrUnsupportedPolicyVersion,
+		},
+		{
+			name: "Fetch method list in version 1",
+			json: `{
+				"ospkg_signature_threshold": 1,
+				"ospkg_fetch_method": "network",
+				"ospkg_fetch_methods": [{"method": "network"}]
+			}`,
+			wantErr: ErrInvalidPolicy,
+		},
+		{
+			name: "Single fetch method in version 2",
+			json: `{
+				"version": 2,
+				"ospkg_signature_threshold": 1,
+				"ospkg_fetch_method": "network",
+				"ospkg_fetch_methods": [{"method": "network"}]
+			}`,
+			wantErr: ErrInvalidPolicy,
+		},
+		{
+			name: "Empty fetch methods",
+			json: `{
+				"version": 2,
+				"ospkg_signature_threshold": 1,
+				"ospkg_fetch_methods": []
+			}`,
+			wantErr: ErrInvalidPolicy,
+		},
+		{
+			name: "Duplicate fetch method",
+			json: `{
+				"version": 2,
+				"ospkg_signature_threshold": 1,
+				"ospkg_fetch_methods": [{"method": "network"}, {"method": "network"}]
+			}`,
+			wantErr: ErrInvalidPolicy,
+		},
+		{
+			name: "HTTPS only for initramfs",
+			json: `{
+				"version": 2,
+				"ospkg_signature_threshold": 1,
+				"ospkg_fetch_methods": [{"method": "initramfs", "https_only": true}]
+			}`,
+			wantErr: ErrInvalidPolicy,
+		},
+		{
+			name: "Negative timeout",
+			json: `{
+				"version": 2,
+				"ospkg_signature_threshold": 1,
+				"ospkg_fetch_methods": [{"method": "network", "timeout_seconds": -1}]
+			}`,
+			wantErr: ErrInvalidPolicy,
+		},
+	}
+
+	for _, tt := range validtests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Policy
+			if err := json.Unmarshal([]byte(tt.json), &got); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got.Methods(), tt.want) {
+				t.Errorf("got %+v, want %+v", got.Methods(), tt.want)
+			}
+		})
+	}
+
+	for _, tt := range invalidtests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Policy
+			err := json.Unmarshal([]byte(tt.json), &got)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("got %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestPolicyMarshalVersion1 makes sure the serialization of version 1
+// policies, which is measured, does not change.
+func TestPolicyMarshalVersion1(t *testing.T) {
+	p := Policy{
+		SignatureThreshold: 2,
+		FetchMethod:        ospkg.FetchFromNetwork,
+	}
+
+	got, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"ospkg_signature_threshold":2,"ospkg_fetch_method":"network"}`
+	if string(got) != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
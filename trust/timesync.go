@@ -0,0 +1,62 @@
+package trust
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"net"
+)
+
+// TimeSyncPolicy holds the Roughtime servers queried for the current time
+// before the OS package is fetched via network. Quorum is the number of
+// servers that must agree on the time, it defaults to one. With Required,
+// failing to obtain the time fails the network fetch method, otherwise the
+// system clock is used as is.
+type TimeSyncPolicy struct {
+	Servers  []RoughtimeServer `json:"roughtime_servers"`
+	Quorum   int               `json:"quorum,omitempty"`
+	Required bool              `json:"required,omitempty"`
+}
+
+// RoughtimeServer is a Roughtime server. Address is the host and UDP port,
+// PublicKey the base64 encoded Ed25519 long-term key of the server.
+type RoughtimeServer struct {
+	Address   string `json:"address"`
+	PublicKey []byte `json:"public_key"`
+}
+
+// MinAgreeing returns the number of servers that must agree on the time.
+func (t *TimeSyncPolicy) MinAgreeing() int {
+	if t.Quorum == 0 {
+		return 1
+	}
+
+	return t.Quorum
+}
+
+func (p *Policy) checkTimeSync() error {
+	ts := p.TimeSync
+	if ts == nil {
+		return nil
+	}
+
+	if len(ts.Servers) == 0 {
+		return fmt.Errorf("time sync: at least one Roughtime server must be set")
+	}
+
+	for _, s := range ts.Servers {
+		if _, _, err := net.SplitHostPort(s.Address); err != nil {
+			return fmt.Errorf("time sync: server address %q: %v", s.Address, err)
+		}
+
+		if len(s.PublicKey) != ed25519.PublicKeySize {
+			return fmt.Errorf("time sync: server %q: public key must be %d bytes, got %d",
+				s.Address, ed25519.PublicKeySize, len(s.PublicKey))
+		}
+	}
+
+	if ts.Quorum < 0 || ts.Quorum > len(ts.Servers) {
+		return fmt.Errorf("time sync: quorum must be between 1 and the number of servers, got %d", ts.Quorum)
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package trust
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestPolicyTimeSync(t *testing.T) {
+	// Base64 of 32 zero bytes.
+	const key = "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="
+
+	validtests := []struct {
+		name   string
+		json   string
+		quorum int
+	}{
+		{
+			name: "Default quorum",
+			json: `{
+				"ospkg_signature_threshold": 1,
+				"ospkg_fetch_method": "network",
+				"time_sync": {"roughtime_servers": [{"address": "roughtime.example.org:2002", "public_key": "` + key + `"}]}
+			}`,
+			quorum: 1,
+		},
+		{
+			name: "Quorum",
+			json: `{
+				"ospkg_signature_threshold": 1,
+				"ospkg_fetch_method": "network",
+				"time_sync": {
+					"roughtime_servers": [
+						{"address": "roughtime.example.org:2002", "public_key": "` + key + `"},
+						{"address": "192.0.2.1:2002", "public_key": "` + key + `"}
+					],
+					"quorum": 2,
+					"required": true
+				}
+			}`,
+			quorum: 2,
+		},
+	}
+
+	invalidtests := []struct {
+		name string
+		json string
+	}{
+		{
+			name: "No servers",
+			json: `{
+				"ospkg_signature_threshold": 1,
+				"ospkg_fetch_method": "network",
+				"time_sync": {"roughtime_servers": []}
+			}`,
+		},
+		{
+			name: "Missing port",
+			json: `{
+				"ospkg_signature_threshold": 1,
+				"ospkg_fetch_method": "network",
+				"time_sync": {"roughtime_servers": [{"address": "roughtime.example.org", "public_key": "` + key + `"}]}
+			}`,
+		},
+		{
+			name: "Short key",
+			json: `{
+				"ospkg_signature_threshold": 1,
+				"ospkg_fetch_method": "network",
+				"time_sync": {"roughtime_servers": [{"address": "roughtime.example.org:2002", "public_key": "AAAA"}]}
+			}`,
+		},
+		{
+			name: "Quorum too large",
+			json: `{
+				"ospkg_signature_threshold": 1,
+				"ospkg_fetch_method": "network",
+				"time_sync": {
+					"roughtime_servers": [{"address": "roughtime.example.org:2002", "public_key": "` + key + `"}],
+					"quorum": 2
+				}
+			}`,
+		},
+	}
+
+	for _, tt := range validtests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Policy
+			if err := json.Unmarshal([]byte(tt.json), &got); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if quorum := got.TimeSync.MinAgreeing(); quorum != tt.quorum {
+				t.Errorf("got quorum %d, want %d", quorum, tt.quorum)
+			}
+		})
+	}
+
+	for _, tt := range invalidtests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Policy
+			if err := json.Unmarshal([]byte(tt.json), &got); !errors.Is(err, ErrInvalidPolicy) {
+				t.Errorf("got %v, want %v", err, ErrInvalidPolicy)
+			}
+		})
+	}
+}